@@ -0,0 +1,135 @@
+package api
+
+import (
+	"client"
+	"context"
+	"errors"
+	"sort"
+)
+
+// Returns the error of a failed batch, tables were checked before it was sent
+func batchError(err error) *apiError {
+	if errors.Is(err, client.ErrInvalidRequest) {
+		return validationError("%s", err)
+	}
+	return internalError("%s", err)
+}
+
+/* BatchGetItem reads the keys of every table with one request per coordinator, keys that fail are returned as UnprocessedKeys. */
+func (s *Server) batchGetItem(ctx context.Context, req *BatchGetItemInput) (interface{}, *apiError) {
+	names := make([]string, 0, len(req.RequestItems))
+	for name := range req.RequestItems {
+		names = append(names, name)
+	}
+	sort.Strings(names) // keys are checked and sent in a stable order
+
+	var keys []client.BatchKey
+	var keyItems []Item // request key of every key, plain values are returned with it
+	for _, name := range names {
+		schema, err := s.schema(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, keyItem := range req.RequestItems[name].Keys {
+			key, err := schema.keyOf(keyItem)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, client.BatchKey{Table: name, Key: key})
+			keyItems = append(keyItems, keyItem)
+		}
+	}
+
+	result, err := s.client.BatchGet(ctx, keys)
+	if err != nil {
+		return nil, batchError(err)
+	}
+
+	out := BatchGetItemOutput{Responses: make(map[string][]Item), UnprocessedKeys: make(map[string]KeysAndAttributes)}
+	for _, name := range names {
+		out.Responses[name] = []Item{}
+	}
+	unprocessed := make(map[client.BatchKey]struct{}, len(result.Unprocessed))
+	for _, key := range result.Unprocessed {
+		unprocessed[key] = struct{}{}
+	}
+	for i, key := range keys {
+		if _, failed := unprocessed[key]; failed {
+			pending := out.UnprocessedKeys[key.Table]
+			pending.Keys = append(pending.Keys, keyItems[i])
+			out.UnprocessedKeys[key.Table] = pending
+		} else if value, exists := result.Items[key]; exists && value.Attrs != nil {
+			out.Responses[key.Table] = append(out.Responses[key.Table], fromItem(value.Attrs))
+		} else if exists {
+			out.Responses[key.Table] = append(out.Responses[key.Table], decodeItem(value.Data, keyItems[i]))
+		}
+	}
+	return out, nil
+}
+
+/* BatchWriteItem applies the puts and deletes of every table with one request per coordinator, writes that fail are returned as UnprocessedItems. */
+func (s *Server) batchWriteItem(ctx context.Context, req *BatchWriteItemInput) (interface{}, *apiError) {
+	names := make([]string, 0, len(req.RequestItems))
+	for name := range req.RequestItems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var writes []client.BatchWrite
+	var requests []WriteRequest // request of every write, returned if it is unprocessed
+	for _, name := range names {
+		schema, err := s.schema(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, request := range req.RequestItems[name] {
+			write, err := schema.batchWrite(name, request)
+			if err != nil {
+				return nil, err
+			}
+			writes = append(writes, write)
+			requests = append(requests, request)
+		}
+	}
+
+	result, err := s.client.BatchWrite(ctx, writes)
+	if err != nil {
+		return nil, batchError(err)
+	}
+
+	out := BatchWriteItemOutput{UnprocessedItems: make(map[string][]WriteRequest)}
+	unprocessed := make(map[client.BatchKey]struct{}, len(result.Unprocessed))
+	for _, write := range result.Unprocessed {
+		unprocessed[write.BatchKey] = struct{}{}
+	}
+	for i, write := range writes {
+		if _, failed := unprocessed[write.BatchKey]; failed {
+			out.UnprocessedItems[write.Table] = append(out.UnprocessedItems[write.Table], requests[i])
+		}
+	}
+	return out, nil
+}
+
+// Returns the write of request on the table name
+func (s *tableSchema) batchWrite(name string, request WriteRequest) (client.BatchWrite, *apiError) {
+	switch {
+	case request.PutRequest != nil && request.DeleteRequest == nil:
+		item := request.PutRequest.Item
+		key, err := s.keyOf(s.keyItem(item))
+		if err != nil {
+			return client.BatchWrite{}, err
+		}
+		typed, err := toItem(item)
+		if err != nil {
+			return client.BatchWrite{}, err
+		}
+		return client.BatchWrite{BatchKey: client.BatchKey{Table: name, Key: key}, Item: typed}, nil
+	case request.DeleteRequest != nil && request.PutRequest == nil:
+		key, err := s.keyOf(request.DeleteRequest.Key)
+		if err != nil {
+			return client.BatchWrite{}, err
+		}
+		return client.BatchWrite{BatchKey: client.BatchKey{Table: name, Key: key}, Delete: true}, nil
+	}
+	return client.BatchWrite{}, validationError("a WriteRequest must hold exactly one of PutRequest and DeleteRequest")
+}
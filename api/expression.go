@@ -0,0 +1,292 @@
+package api
+
+import (
+	"base"
+	"client"
+	"constants"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var clauseRegex = regexp.MustCompile(`(?i)\b(SET|REMOVE|ADD|DELETE)\b`)
+var nameRegex = regexp.MustCompile(`^#?[A-Za-z0-9_]+$`)
+var existsRegex = regexp.MustCompile(`^(attribute_not_exists|attribute_exists)\s*\(\s*([^)\s]+)\s*\)$`)
+var andRegex = regexp.MustCompile(`(?i)\s+AND\s+`)
+var compareRegex = regexp.MustCompile(`^(\S+?)\s*(<=|>=|=|<|>)\s*(\S+)$`)
+var betweenRegex = regexp.MustCompile(`(?i)^(\S+)\s+BETWEEN\s+(\S+)\s+AND\s+(\S+)$`)
+var beginsWithRegex = regexp.MustCompile(`^begins_with\s*\(\s*([^,\s]+)\s*,\s*([^)\s]+)\s*\)$`)
+
+type updateAction struct {
+	op    string // SET, REMOVE or ADD
+	name  string
+	value AttributeValue
+}
+
+/*
+Parses an UpdateExpression of the form "SET a = :x, #b = :y REMOVE c ADD d :n".
+Only top-level attribute names and plain value placeholders are supported.
+*/
+func parseUpdateExpression(expr string, names map[string]string, values map[string]AttributeValue) ([]updateAction, *apiError) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, validationError("UpdateExpression must not be empty")
+	}
+
+	locs := clauseRegex.FindAllStringIndex(expr, -1)
+	if len(locs) == 0 || strings.TrimSpace(expr[:locs[0][0]]) != "" {
+		return nil, validationError("invalid UpdateExpression %q", expr)
+	}
+
+	var actions []updateAction
+	for i, loc := range locs {
+		end := len(expr)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		clause := strings.ToUpper(expr[loc[0]:loc[1]])
+		body := expr[loc[1]:end]
+
+		for _, part := range strings.Split(body, ",") {
+			part = strings.TrimSpace(part)
+			var action updateAction
+			var err *apiError
+
+			switch clause {
+			case "SET":
+				action, err = parseSet(part, names, values)
+			case "REMOVE":
+				action.op = "REMOVE"
+				action.name, err = resolveName(part, names)
+			case "ADD":
+				action, err = parseAdd(part, names, values)
+			default:
+				err = validationError("%s is not supported in UpdateExpression", clause)
+			}
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+func parseSet(part string, names map[string]string, values map[string]AttributeValue) (updateAction, *apiError) {
+	sides := strings.SplitN(part, "=", 2)
+	if len(sides) != 2 {
+		return updateAction{}, validationError("invalid SET action %q", part)
+	}
+	name, err := resolveName(strings.TrimSpace(sides[0]), names)
+	if err != nil {
+		return updateAction{}, err
+	}
+
+	placeholder := strings.TrimSpace(sides[1])
+	value, exists := values[placeholder]
+	if !strings.HasPrefix(placeholder, ":") || !exists {
+		return updateAction{}, validationError("SET %s must be assigned a value from ExpressionAttributeValues, got %q", name, placeholder)
+	}
+	return updateAction{op: "SET", name: name, value: value}, nil
+}
+
+func parseAdd(part string, names map[string]string, values map[string]AttributeValue) (updateAction, *apiError) {
+	fields := strings.Fields(part)
+	if len(fields) != 2 {
+		return updateAction{}, validationError("invalid ADD action %q", part)
+	}
+	name, err := resolveName(fields[0], names)
+	if err != nil {
+		return updateAction{}, err
+	}
+
+	value, exists := values[fields[1]]
+	if !strings.HasPrefix(fields[1], ":") || !exists {
+		return updateAction{}, validationError("ADD %s must add a value from ExpressionAttributeValues, got %q", name, fields[1])
+	}
+	return updateAction{op: "ADD", name: name, value: value}, nil
+}
+
+func resolveName(token string, names map[string]string) (string, *apiError) {
+	if !nameRegex.MatchString(token) {
+		return "", validationError("invalid attribute name %q, only top-level attributes are supported", token)
+	}
+	if !strings.HasPrefix(token, "#") {
+		return token, nil
+	}
+	name, exists := names[token]
+	if !exists {
+		return "", validationError("%s is not defined in ExpressionAttributeNames", token)
+	}
+	return name, nil
+}
+
+/* Translates the action into the update applied by the coordinator, ADD only supports integer N values */
+func (a *updateAction) toUpdate(key Item) (base.UpdateAction, *apiError) {
+	if _, isKey := key[a.name]; isKey {
+		return base.UpdateAction{}, validationError("cannot update key attribute %q", a.name)
+	}
+
+	switch a.op {
+	case "SET":
+		value, err := toAttributeValue(a.value)
+		if err != nil {
+			return base.UpdateAction{}, validationError("SET %s: %s", a.name, err.message)
+		}
+		return client.Set(a.name, value), nil
+	case "ADD":
+		var number string
+		if err := json.Unmarshal(a.value["N"], &number); err == nil && len(a.value) == 1 {
+			if delta, err := strconv.ParseInt(number, 10, 64); err == nil {
+				return client.Add(a.name, delta), nil
+			}
+		}
+		return base.UpdateAction{}, validationError("ADD %s must add an integer N value", a.name)
+	default:
+		return client.Remove(a.name), nil
+	}
+}
+
+// VersionAttribute names the version of an item in a ConditionExpression, through ExpressionAttributeNames only
+const VersionAttribute = "$version"
+
+/*
+Parses a ConditionExpression, only "attribute_not_exists(key)" and "attribute_exists(key)" on a key attribute
+and "#v = :v" with #v naming VersionAttribute are supported. They hold if no item, or an item, is stored under
+the key, or if the version of the stored item is the S value :v as returned by GetItem and PutItem, and are
+checked by the coordinator. Returns the constants.COND_* of the condition and the version it expects.
+*/
+func parseConditionExpression(expr string, names map[string]string, values map[string]AttributeValue, keyAttributes []string) (int, []int, *apiError) {
+	expr = strings.TrimSpace(expr)
+	if matches := compareRegex.FindStringSubmatch(expr); matches != nil {
+		return parseVersionCondition(matches[1], matches[2], matches[3], names, values)
+	}
+	matches := existsRegex.FindStringSubmatch(expr)
+	if matches == nil {
+		return constants.COND_NONE, nil, validationError("ConditionExpression %q is not supported, only attribute_not_exists(%s), attribute_exists(%s) and #v = :v with #v naming %s are", expr, keyAttributes[0], keyAttributes[0], VersionAttribute)
+	}
+	name, err := resolveName(matches[2], names)
+	if err != nil {
+		return constants.COND_NONE, nil, err
+	}
+	for _, keyAttribute := range keyAttributes {
+		if name == keyAttribute {
+			if matches[1] == "attribute_exists" {
+				return constants.COND_EXISTS, nil, nil
+			}
+			return constants.COND_NOT_EXISTS, nil, nil
+		}
+	}
+	return constants.COND_NONE, nil, validationError("%s is only supported on the key attributes %q", matches[1], keyAttributes)
+}
+
+func parseVersionCondition(operand string, op string, placeholder string, names map[string]string, values map[string]AttributeValue) (int, []int, *apiError) {
+	name, exists := names[operand]
+	if !strings.HasPrefix(operand, "#") || !exists || name != VersionAttribute {
+		return constants.COND_NONE, nil, validationError("comparison %s %s %s is not supported, only #v = :v with #v naming %s is", operand, op, placeholder, VersionAttribute)
+	}
+	if op != "=" {
+		return constants.COND_NONE, nil, validationError("%s can only be compared with =, got %s", VersionAttribute, op)
+	}
+
+	value, exists := values[placeholder]
+	var version string
+	if !strings.HasPrefix(placeholder, ":") || !exists || len(value) != 1 || json.Unmarshal(value["S"], &version) != nil {
+		return constants.COND_NONE, nil, validationError("%s must be compared with an S value from ExpressionAttributeValues, got %q", VersionAttribute, placeholder)
+	}
+	clock, err := parseVersion(version)
+	if err != nil {
+		return constants.COND_NONE, nil, err
+	}
+	return constants.COND_VERSION_EQUALS, clock, nil
+}
+
+// Returns the version of an item as the dotted counters of its vector clock, e.g. "1.0.2"
+func formatVersion(version []int) string {
+	counters := make([]string, len(version))
+	for i, counter := range version {
+		counters[i] = strconv.Itoa(counter)
+	}
+	return strings.Join(counters, ".")
+}
+
+func parseVersion(version string) ([]int, *apiError) {
+	var clock []int
+	for _, field := range strings.Split(version, ".") {
+		counter, err := strconv.Atoi(field)
+		if err != nil || counter < 0 {
+			return nil, validationError("invalid version %q, expected the dotted counters returned by GetItem", version)
+		}
+		clock = append(clock, counter)
+	}
+	return clock, nil
+}
+
+/* Key condition of a Query, the partition key value and an optional sort key condition */
+type keyCondition struct {
+	partition AttributeValue
+	sortKey   *base.SortKeyCondition
+}
+
+/*
+Parses a Query KeyConditionExpression of the form "id = :id [AND sortKeyCondition]", where the sort key
+condition is one of "sk op :v" with op in =, <, <=, >, >=, "sk BETWEEN :a AND :b" or "begins_with(sk, :p)".
+*/
+func parseKeyConditionExpression(expr string, names map[string]string, values map[string]AttributeValue, keyAttribute string, sortKeyAttribute string) (keyCondition, *apiError) {
+	var cond keyCondition
+	parts := andRegex.Split(strings.TrimSpace(expr), -1)
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		if i+1 < len(parts) && betweenRegex.MatchString(part+" AND "+parts[i+1]) {
+			part += " AND " + parts[i+1] // the AND of BETWEEN binds its bounds
+			i++
+		}
+
+		var name, op string
+		var operands []string
+		if matches := betweenRegex.FindStringSubmatch(part); matches != nil {
+			name, op, operands = matches[1], constants.QUERY_BETWEEN, matches[2:]
+		} else if matches := beginsWithRegex.FindStringSubmatch(part); matches != nil {
+			name, op, operands = matches[1], constants.QUERY_BEGINS_WITH, matches[2:]
+		} else if matches := compareRegex.FindStringSubmatch(part); matches != nil {
+			name, op, operands = matches[1], matches[2], matches[3:]
+		} else {
+			return cond, validationError("invalid KeyConditionExpression %q", expr)
+		}
+
+		name, err := resolveName(name, names)
+		if err != nil {
+			return cond, err
+		}
+		var keyValues []AttributeValue
+		for _, placeholder := range operands {
+			value, exists := values[placeholder]
+			if !strings.HasPrefix(placeholder, ":") || !exists {
+				return cond, validationError("%s must be compared to a value from ExpressionAttributeValues, got %q", name, placeholder)
+			}
+			keyValues = append(keyValues, value)
+		}
+
+		switch {
+		case name == keyAttribute && op == constants.QUERY_EQ && cond.partition == nil:
+			cond.partition = keyValues[0]
+		case name == sortKeyAttribute && cond.sortKey == nil:
+			cond.sortKey = &base.SortKeyCondition{Op: op}
+			if cond.sortKey.Value, err = keyString(name, keyValues[0]); err != nil {
+				return cond, err
+			}
+			if op == constants.QUERY_BETWEEN {
+				if cond.sortKey.Upper, err = keyString(name, keyValues[1]); err != nil {
+					return cond, err
+				}
+			}
+		default:
+			return cond, validationError("KeyConditionExpression %q must be an equality on %q, optionally AND a condition on %q", expr, keyAttribute, sortKeyAttribute)
+		}
+	}
+
+	if cond.partition == nil {
+		return cond, validationError("KeyConditionExpression %q must be an equality on %q", expr, keyAttribute)
+	}
+	return cond, nil
+}
@@ -0,0 +1,565 @@
+package api
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// X-Amz-Target prefix used by the AWS SDKs for the DynamoDB JSON protocol
+const targetPrefix = "DynamoDB_20120810."
+
+// attribute holding the key of every item of the default keyspace, which has no table schema to declare it
+const DefaultKeyAttribute = "id"
+
+/*
+Server is an HTTP front-end speaking a subset of the DynamoDB JSON protocol.
+Requests are routed on the X-Amz-Target header and translated into the
+CLIENT_REQ_READ / CLIENT_REQ_WRITE / CLIENT_REQ_DELETE flow of the nodes.
+Requests without a TableName use the default keyspace, keyed by KeyAttribute and SortKeyAttribute.
+*/
+type Server struct {
+	client *client.Client
+
+	KeyAttribute     string // partition key, items are routed by its value
+	SortKeyAttribute string // optional sort key, items of a partition are stored together ordered by it
+}
+
+/* Key attributes and items of the table named by a request */
+type tableSchema struct {
+	table            *client.Table
+	KeyAttribute     string
+	SortKeyAttribute string
+	indexes          []base.Index
+}
+
+// Returns the schema of the table name, the default keyspace for ""
+func (s *Server) schema(name string) (*tableSchema, *apiError) {
+	if name == "" {
+		return &tableSchema{table: s.client.Table(""), KeyAttribute: s.KeyAttribute, SortKeyAttribute: s.SortKeyAttribute}, nil
+	}
+	t, err := s.client.DescribeTable(name)
+	if err != nil {
+		return nil, resourceNotFound(name)
+	}
+	return &tableSchema{table: s.client.Table(name), KeyAttribute: t.KeyAttribute, SortKeyAttribute: t.SortKeyAttribute, indexes: t.Indexes}, nil
+}
+
+func NewServer(phy_nodes []*base.Node, c *config.Config) *Server {
+	return &Server{client: client.New(phy_nodes, c), KeyAttribute: DefaultKeyAttribute}
+}
+
+// SetNodes points the server at a new set of nodes, e.g. after the CLI wipes the system
+func (s *Server) SetNodes(phy_nodes []*base.Node) {
+	s.client.SetNodes(phy_nodes)
+}
+
+// SetConfig replaces the settings of the server, e.g. after the CLI updates them on a live cluster
+func (s *Server) SetConfig(c config.Config) {
+	s.client.SetConfig(c)
+}
+
+type apiError struct {
+	status  int
+	errType string
+	message string
+	reasons []CancellationReason // for TransactionCanceledException, the reason of every item in request order
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.errType, e.message)
+}
+
+func validationError(format string, args ...interface{}) *apiError {
+	return &apiError{status: http.StatusBadRequest, errType: "com.amazonaws.dynamodb.v20120810#ValidationException", message: fmt.Sprintf(format, args...)}
+}
+
+func conditionalCheckFailed() *apiError {
+	return &apiError{status: http.StatusBadRequest, errType: "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException", message: "The conditional request failed"}
+}
+
+func resourceNotFound(table string) *apiError {
+	return &apiError{status: http.StatusBadRequest, errType: "com.amazonaws.dynamodb.v20120810#ResourceNotFoundException", message: fmt.Sprintf("Requested resource not found: Table: %s not found", table)}
+}
+
+func transactionConflict(err error) *apiError {
+	return &apiError{status: http.StatusBadRequest, errType: "com.amazonaws.dynamodb.v20120810#TransactionConflictException", message: err.Error()}
+}
+
+func resourceInUse(table string) *apiError {
+	return &apiError{status: http.StatusBadRequest, errType: "com.amazonaws.dynamodb.v20120810#ResourceInUseException", message: fmt.Sprintf("Table already exists: %s", table)}
+}
+
+// Returns the error of a failed request, the table may have been deleted since the request started
+func requestError(table string, err error) *apiError {
+	if errors.Is(err, client.ErrTableNotFound) {
+		return resourceNotFound(table)
+	}
+	if errors.Is(err, client.ErrTransactionConflict) {
+		return transactionConflict(err)
+	}
+	return internalError("%s", err)
+}
+
+func internalError(format string, args ...interface{}) *apiError {
+	return &apiError{status: http.StatusInternalServerError, errType: "com.amazonaws.dynamodb.v20120810#InternalServerError", message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, &apiError{status: http.StatusMethodNotAllowed, errType: "com.amazon.coral.service#UnknownOperationException", message: "only POST is supported"})
+		return
+	}
+
+	target := r.Header.Get("X-Amz-Target")
+	if !strings.HasPrefix(target, targetPrefix) {
+		writeError(w, &apiError{status: http.StatusBadRequest, errType: "com.amazon.coral.service#UnknownOperationException", message: fmt.Sprintf("unknown target %q", target)})
+		return
+	}
+
+	var resp interface{}
+	var err *apiError
+	decoder := json.NewDecoder(r.Body)
+
+	switch strings.TrimPrefix(target, targetPrefix) {
+	case "GetItem":
+		var req GetItemInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.getItem(r.Context(), &req)
+		}
+	case "PutItem":
+		var req PutItemInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.putItem(r.Context(), &req)
+		}
+	case "DeleteItem":
+		var req DeleteItemInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.deleteItem(r.Context(), &req)
+		}
+	case "UpdateItem":
+		var req UpdateItemInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.updateItem(r.Context(), &req)
+		}
+	case "Query":
+		var req QueryInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.query(r.Context(), &req)
+		}
+	case "Scan":
+		var req ScanInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.scan(r.Context(), &req)
+		}
+	case "BatchGetItem":
+		var req BatchGetItemInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.batchGetItem(r.Context(), &req)
+		}
+	case "BatchWriteItem":
+		var req BatchWriteItemInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.batchWriteItem(r.Context(), &req)
+		}
+	case "TransactWriteItems":
+		var req TransactWriteItemsInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.transactWriteItems(r.Context(), &req)
+		}
+	case "CreateTable":
+		var req CreateTableInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.createTable(&req)
+		}
+	case "DeleteTable":
+		var req DeleteTableInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.deleteTable(&req)
+		}
+	case "DescribeTable":
+		var req DescribeTableInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.describeTable(&req)
+		}
+	case "ListTables":
+		var req ListTablesInput
+		if err = decode(decoder, &req); err == nil {
+			resp, err = s.listTables(&req)
+		}
+	default:
+		err = &apiError{status: http.StatusBadRequest, errType: "com.amazon.coral.service#UnknownOperationException", message: fmt.Sprintf("unknown target %q", target)}
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func decode(decoder *json.Decoder, req interface{}) *apiError {
+	if err := decoder.Decode(req); err != nil {
+		return &apiError{status: http.StatusBadRequest, errType: "com.amazon.coral.service#SerializationException", message: err.Error()}
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, err *apiError) {
+	if err.status >= http.StatusInternalServerError {
+		fmt.Printf("api: %s\n", err.Error())
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(err.status)
+	body := map[string]interface{}{"__type": err.errType, "message": err.message}
+	if err.reasons != nil {
+		body["CancellationReasons"] = err.reasons
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// Reads the item stored under key, a missing key gives a nil item
+// Returns the item stored under key and its version, nil if there is none
+func (s *tableSchema) read(ctx context.Context, key base.Key, keyAttrs Item) (Item, client.Version, *apiError) {
+	value, err := s.table.Read(ctx, key)
+	if errors.Is(err, client.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, requestError(s.table.Name(), err)
+	}
+	if value.Attrs != nil {
+		return fromItem(value.Attrs), value.Version, nil
+	}
+	return decodeItem(value.Data, keyAttrs), value.Version, nil
+}
+
+// Writes item under key if condition holds on the item stored under it, returns the version written
+func (s *tableSchema) write(ctx context.Context, key base.Key, item Item, condition int, version []int) (client.Version, *apiError) {
+	typed, apiErr := toItem(item)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	written, err := s.table.PutItemIf(ctx, key, typed, condition, version)
+	if errors.Is(err, client.ErrConditionFailed) {
+		return nil, conditionalCheckFailed()
+	}
+	if err != nil {
+		return nil, requestError(s.table.Name(), err)
+	}
+	return written, nil
+}
+
+// Returns the condition of the ConditionExpression expr, COND_NONE if it is empty
+func (s *tableSchema) condition(expr string, names map[string]string, values map[string]AttributeValue) (int, []int, *apiError) {
+	if expr == "" {
+		return constants.COND_NONE, nil, nil
+	}
+	return parseConditionExpression(expr, names, values, s.keyAttributes())
+}
+
+func (s *Server) getItem(ctx context.Context, req *GetItemInput) (interface{}, *apiError) {
+	schema, err := s.schema(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := schema.keyOf(req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	item, version, err := schema.read(ctx, key, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return GetItemOutput{}, nil
+	}
+	return GetItemOutput{Item: item, Version: formatVersion(version)}, nil
+}
+
+func (s *Server) putItem(ctx context.Context, req *PutItemInput) (interface{}, *apiError) {
+	if err := checkUnsupported(req.ReturnValues); err != nil {
+		return nil, err
+	}
+	schema, err := s.schema(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	condition, version, err := schema.condition(req.ConditionExpression, req.ExpressionAttributeNames, req.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Item) == 0 {
+		return nil, validationError("Item must not be empty")
+	}
+	key, err := schema.keyOf(schema.keyItem(req.Item))
+	if err != nil {
+		return nil, err
+	}
+
+	written, err := schema.write(ctx, key, req.Item, condition, version)
+	if err != nil {
+		return nil, err
+	}
+	return PutItemOutput{Version: formatVersion(written)}, nil
+}
+
+func (s *Server) deleteItem(ctx context.Context, req *DeleteItemInput) (interface{}, *apiError) {
+	if err := checkUnsupported(req.ReturnValues); err != nil {
+		return nil, err
+	}
+	schema, err := s.schema(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := schema.keyOf(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	condition, version, err := schema.condition(req.ConditionExpression, req.ExpressionAttributeNames, req.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	deleteErr := schema.table.DeleteItemIf(ctx, key, condition, version)
+	if errors.Is(deleteErr, client.ErrConditionFailed) {
+		return nil, conditionalCheckFailed()
+	}
+	if deleteErr != nil {
+		return nil, requestError(req.TableName, deleteErr)
+	}
+	return struct{}{}, nil
+}
+
+/* UpdateItem is applied by the coordinator of the key, so concurrent updates and ADDs are not lost. */
+func (s *Server) updateItem(ctx context.Context, req *UpdateItemInput) (interface{}, *apiError) {
+	switch req.ReturnValues {
+	case "", "NONE", "ALL_OLD", "ALL_NEW":
+	default:
+		return nil, validationError("ReturnValues %s is not supported", req.ReturnValues)
+	}
+	schema, err := s.schema(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := schema.keyOf(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	updates, err := updateActions(req.Key, req.UpdateExpression, req.ExpressionAttributeNames, req.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	condition, version, err := schema.condition(req.ConditionExpression, req.ExpressionAttributeNames, req.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	var old Item
+	if req.ReturnValues == "ALL_OLD" { // read separately, the item may change before the update is applied
+		if old, _, err = schema.read(ctx, key, req.Key); err != nil {
+			return nil, err
+		}
+	}
+
+	attrs, updateErr := schema.table.UpdateIf(ctx, key, condition, version, updates...)
+	if errors.Is(updateErr, client.ErrConditionFailed) {
+		return nil, conditionalCheckFailed()
+	}
+	if errors.Is(updateErr, client.ErrInvalidRequest) {
+		return nil, validationError("%s", updateErr)
+	}
+	if updateErr != nil {
+		return nil, requestError(req.TableName, updateErr)
+	}
+
+	switch req.ReturnValues {
+	case "ALL_OLD":
+		return UpdateItemOutput{Attributes: old}, nil
+	case "ALL_NEW":
+		return UpdateItemOutput{Attributes: fromItem(attrs)}, nil
+	default:
+		return UpdateItemOutput{}, nil
+	}
+}
+
+// Returns the actions of the UpdateExpression expr on the item under keyAttrs
+func updateActions(keyAttrs Item, expr string, names map[string]string, values map[string]AttributeValue) ([]base.UpdateAction, *apiError) {
+	actions, err := parseUpdateExpression(expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key attributes are part of every item, including one created by the update
+	keyItem, err := toItem(keyAttrs)
+	if err != nil {
+		return nil, err
+	}
+	var updates []base.UpdateAction
+	for name, value := range keyItem {
+		updates = append(updates, client.Set(name, value))
+	}
+	for _, action := range actions {
+		update, err := action.toUpdate(keyAttrs)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
+
+/* Query reads the items of a partition from R replicas, sort keys are ordered as strings whatever their type. */
+func (s *Server) query(ctx context.Context, req *QueryInput) (interface{}, *apiError) {
+	schema, err := s.schema(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	keyAttribute, sortKeyAttribute := schema.KeyAttribute, schema.SortKeyAttribute
+	if req.IndexName != "" {
+		index := schema.index(req.IndexName)
+		if index == nil {
+			return nil, validationError("table %q has no index %q", req.TableName, req.IndexName)
+		}
+		keyAttribute, sortKeyAttribute = index.PartitionAttribute, index.SortAttribute
+	} else if schema.SortKeyAttribute == "" {
+		return nil, validationError("Query requires a sort key attribute")
+	}
+	if req.Limit < 0 {
+		return nil, validationError("Limit must not be negative")
+	}
+	cond, err := parseKeyConditionExpression(req.KeyConditionExpression, req.ExpressionAttributeNames, req.ExpressionAttributeValues, keyAttribute, sortKeyAttribute)
+	if err != nil {
+		return nil, err
+	}
+	partition, err := keyString(keyAttribute, cond.partition)
+	if err != nil {
+		return nil, err
+	}
+
+	q := base.Query{Condition: cond.sortKey, Reverse: req.ScanIndexForward != nil && !*req.ScanIndexForward, Limit: req.Limit, Index: req.IndexName}
+	if req.ExclusiveStartKey != nil && req.IndexName != "" {
+		if q.StartKey, err = schema.indexStartKey(req.ExclusiveStartKey, partition, keyAttribute, sortKeyAttribute); err != nil {
+			return nil, err
+		}
+	} else if req.ExclusiveStartKey != nil {
+		start, err := schema.keyOf(req.ExclusiveStartKey)
+		if err != nil {
+			return nil, err
+		}
+		if start.Partition != partition {
+			return nil, validationError("ExclusiveStartKey must be in the queried partition")
+		}
+		q.StartKey = start.Sort
+	}
+
+	page, queryErr := schema.table.Query(ctx, partition, q)
+	if errors.Is(queryErr, client.ErrInvalidRequest) {
+		return nil, validationError("%s", queryErr)
+	}
+	if queryErr != nil {
+		return nil, requestError(req.TableName, queryErr)
+	}
+
+	out := QueryOutput{Items: []Item{}, Count: len(page.Items), ScannedCount: len(page.Items)}
+	for _, queryItem := range page.Items {
+		keyAttrs := Item{schema.KeyAttribute: cond.partition, schema.SortKeyAttribute: stringValue(queryItem.SortKey)}
+		item := decodeItem(queryItem.Data, keyAttrs)
+		if queryItem.Attrs != nil {
+			item = fromItem(queryItem.Attrs)
+		}
+		out.Items = append(out.Items, item)
+	}
+	if page.LastKey != "" && req.IndexName != "" {
+		// the key of an index entry is the index key followed by the key of its item
+		last := out.Items[len(out.Items)-1]
+		out.LastEvaluatedKey = schema.keyItem(last)
+		for _, name := range []string{keyAttribute, sortKeyAttribute} {
+			if name != "" {
+				out.LastEvaluatedKey[name] = last[name]
+			}
+		}
+	} else if page.LastKey != "" {
+		last := out.Items[len(out.Items)-1]
+		out.LastEvaluatedKey = Item{schema.KeyAttribute: cond.partition, schema.SortKeyAttribute: last[schema.SortKeyAttribute]}
+		if last[schema.SortKeyAttribute] == nil {
+			out.LastEvaluatedKey[schema.SortKeyAttribute] = stringValue(page.LastKey)
+		}
+	}
+	return out, nil
+}
+
+/* Scan reads a page of a segment of the table, items are returned in the order of their key hashes. */
+func (s *Server) scan(ctx context.Context, req *ScanInput) (interface{}, *apiError) {
+	schema, err := s.schema(req.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if req.Limit < 0 {
+		return nil, validationError("Limit must not be negative")
+	}
+	if (req.Segment == nil) != (req.TotalSegments == nil) {
+		return nil, validationError("Segment and TotalSegments must be given together")
+	}
+
+	scan := base.Scan{Limit: req.Limit}
+	if req.TotalSegments != nil {
+		if *req.TotalSegments < 1 || *req.Segment < 0 || *req.Segment >= *req.TotalSegments {
+			return nil, validationError("Segment %d must be in [0, TotalSegments) and TotalSegments %d positive", *req.Segment, *req.TotalSegments)
+		}
+		scan.Segment, scan.TotalSegments = *req.Segment, *req.TotalSegments
+	}
+	if req.ExclusiveStartKey != nil {
+		start, err := schema.keyOf(req.ExclusiveStartKey)
+		if err != nil {
+			return nil, err
+		}
+		scan.StartKey = &start
+	}
+
+	page, scanErr := schema.table.Scan(ctx, scan)
+	if errors.Is(scanErr, client.ErrInvalidRequest) {
+		return nil, validationError("%s", scanErr)
+	}
+	if scanErr != nil {
+		return nil, requestError(req.TableName, scanErr)
+	}
+
+	out := ScanOutput{Items: []Item{}, Count: len(page.Items), ScannedCount: len(page.Items)}
+	var keyAttrs Item
+	for _, scanItem := range page.Items {
+		keyAttrs = Item{schema.KeyAttribute: stringValue(scanItem.Key)}
+		if scanItem.SortKey != "" && schema.SortKeyAttribute != "" {
+			keyAttrs[schema.SortKeyAttribute] = stringValue(scanItem.SortKey)
+		}
+		item := decodeItem(scanItem.Data, keyAttrs)
+		if scanItem.Attrs != nil {
+			item = fromItem(scanItem.Attrs)
+		}
+		out.Items = append(out.Items, item)
+	}
+	if page.LastKey != nil {
+		// the key attributes keep the types they were written with
+		out.LastEvaluatedKey = keyAttrs
+		for name, value := range schema.keyItem(out.Items[len(out.Items)-1]) {
+			out.LastEvaluatedKey[name] = value
+		}
+	}
+	return out, nil
+}
+
+func checkUnsupported(returnValues string) *apiError {
+	if returnValues != "" && returnValues != "NONE" {
+		return validationError("ReturnValues %s is not supported", returnValues)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"base"
+	"client"
+	"errors"
+	"sort"
+)
+
+/* CreateTable adds a table keyed by the HASH and optional RANGE attributes of KeySchema, tables are ACTIVE once created. */
+func (s *Server) createTable(req *CreateTableInput) (interface{}, *apiError) {
+	t := base.Table{Name: req.TableName, N: req.N, R: req.R, W: req.W, ConflictResolution: req.ConflictResolution}
+	types := make(map[string]string, len(req.AttributeDefinitions))
+	for _, def := range req.AttributeDefinitions {
+		types[def.AttributeName] = def.AttributeType
+	}
+	var err *apiError
+	if t.KeyAttribute, t.SortKeyAttribute, err = parseKeySchema("KeySchema", req.KeySchema, types); err != nil {
+		return nil, err
+	}
+
+	for _, gsi := range req.GlobalSecondaryIndexes {
+		idx := base.Index{Name: gsi.IndexName}
+		if idx.PartitionAttribute, idx.SortAttribute, err = parseKeySchema("KeySchema of index "+gsi.IndexName, gsi.KeySchema, types); err != nil {
+			return nil, err
+		}
+		if gsi.Projection != nil && gsi.Projection.ProjectionType != "ALL" {
+			return nil, validationError("index %q: only the ALL projection is supported", gsi.IndexName)
+		}
+		t.Indexes = append(t.Indexes, idx)
+	}
+
+	if spec := req.StreamSpecification; spec != nil && spec.StreamEnabled {
+		if spec.StreamViewType != "" && spec.StreamViewType != "NEW_AND_OLD_IMAGES" {
+			return nil, validationError("only the NEW_AND_OLD_IMAGES stream view type is supported")
+		}
+		t.Stream = true
+	}
+
+	if ttl := req.TimeToLiveSpecification; ttl != nil && ttl.Enabled {
+		if ttl.AttributeName == "" {
+			return nil, validationError("TimeToLiveSpecification must name an attribute")
+		}
+		t.TTLAttribute = ttl.AttributeName
+	}
+
+	created, createErr := s.client.CreateTable(t)
+	if errors.Is(createErr, client.ErrTableExists) {
+		return nil, resourceInUse(req.TableName)
+	}
+	if createErr != nil {
+		return nil, validationError("%s", createErr)
+	}
+	return CreateTableOutput{TableDescription: describeTable(created, "ACTIVE")}, nil
+}
+
+/* DeleteTable removes a table, its items are dropped from every node before it returns. */
+func (s *Server) deleteTable(req *DeleteTableInput) (interface{}, *apiError) {
+	deleted, err := s.client.DeleteTable(req.TableName)
+	if err != nil {
+		return nil, resourceNotFound(req.TableName)
+	}
+	return DeleteTableOutput{TableDescription: describeTable(deleted, "DELETING")}, nil
+}
+
+/*
+Returns the HASH and optional RANGE attributes of a key schema, which must be defined in types as S, N or B.
+what names the schema in errors.
+*/
+func parseKeySchema(what string, schema []KeySchemaElement, types map[string]string) (string, string, *apiError) {
+	var hash, rangeName string
+	for _, elem := range schema {
+		switch {
+		case elem.KeyType == "HASH" && hash == "":
+			hash = elem.AttributeName
+		case elem.KeyType == "RANGE" && rangeName == "":
+			rangeName = elem.AttributeName
+		default:
+			return "", "", validationError("%s must hold one HASH and at most one RANGE attribute", what)
+		}
+	}
+	if hash == "" {
+		return "", "", validationError("%s must hold a HASH attribute", what)
+	}
+
+	for _, name := range []string{hash, rangeName} {
+		if name == "" {
+			continue
+		}
+		switch types[name] {
+		case "S", "N", "B":
+		default:
+			return "", "", validationError("key attribute %q must be defined in AttributeDefinitions with type S, N or B", name)
+		}
+	}
+	return hash, rangeName, nil
+}
+
+/* DescribeTable returns the settings of a table, with the pending updates and lag of its indexes. */
+func (s *Server) describeTable(req *DescribeTableInput) (interface{}, *apiError) {
+	t, err := s.client.DescribeTable(req.TableName)
+	if err != nil {
+		return nil, resourceNotFound(req.TableName)
+	}
+	desc := describeTable(t, "ACTIVE")
+	for i := range desc.GlobalSecondaryIndexes {
+		index := &desc.GlobalSecondaryIndexes[i]
+		if status, err := s.client.DescribeIndex(t.Name, index.IndexName); err == nil {
+			index.PendingUpdates, index.LagMillis = status.Pending, status.Lag.Milliseconds()
+		}
+	}
+	return DescribeTableOutput{Table: desc}, nil
+}
+
+/* ListTables returns the table names in order, a page at a time if Limit is set. */
+func (s *Server) listTables(req *ListTablesInput) (interface{}, *apiError) {
+	if req.Limit < 0 || req.Limit > 100 {
+		return nil, validationError("Limit must be in [1, 100]")
+	}
+	names := s.client.ListTables()
+	names = names[sort.SearchStrings(names, req.ExclusiveStartTableName):]
+	if len(names) > 0 && names[0] == req.ExclusiveStartTableName {
+		names = names[1:]
+	}
+
+	out := ListTablesOutput{TableNames: names}
+	if req.Limit > 0 && len(names) > req.Limit {
+		out.TableNames = names[:req.Limit]
+		out.LastEvaluatedTableName = out.TableNames[req.Limit-1]
+	}
+	return out, nil
+}
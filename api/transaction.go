@@ -0,0 +1,113 @@
+package api
+
+import (
+	"base"
+	"client"
+	"constants"
+	"context"
+	"errors"
+	"net/http"
+)
+
+func transactionCanceled(err *base.TransactionCanceledError) *apiError {
+	reasons := make([]CancellationReason, len(err.Reasons))
+	for i, reason := range err.Reasons {
+		switch {
+		case reason == nil:
+			reasons[i] = CancellationReason{Code: "None"}
+		case errors.Is(reason, client.ErrConditionFailed):
+			reasons[i] = CancellationReason{Code: "ConditionalCheckFailed", Message: "The conditional request failed"}
+		case errors.Is(reason, client.ErrTransactionConflict):
+			reasons[i] = CancellationReason{Code: "TransactionConflict", Message: reason.Error()}
+		case errors.Is(reason, client.ErrInvalidRequest):
+			reasons[i] = CancellationReason{Code: "ValidationError", Message: reason.Error()}
+		default: // the owners of the key did not answer, the transaction may be retried
+			reasons[i] = CancellationReason{Code: "ThrottlingError", Message: reason.Error()}
+		}
+	}
+	return &apiError{status: http.StatusBadRequest, errType: "com.amazonaws.dynamodb.v20120810#TransactionCanceledException", message: err.Error(), reasons: reasons}
+}
+
+/* TransactWriteItems applies its puts, deletes, updates and condition checks all or nothing with a two-phase commit. */
+func (s *Server) transactWriteItems(ctx context.Context, req *TransactWriteItemsInput) (interface{}, *apiError) {
+	items := make([]client.TransactItem, len(req.TransactItems))
+	for i, request := range req.TransactItems {
+		item, err := s.transactItem(request)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+
+	err := s.client.TransactWrite(ctx, items)
+	var canceled *base.TransactionCanceledError
+	if errors.As(err, &canceled) {
+		return nil, transactionCanceled(canceled)
+	}
+	if err != nil {
+		return nil, batchError(err)
+	}
+	return TransactWriteItemsOutput{}, nil
+}
+
+// Returns the item of request, a condition is required by ConditionCheck and optional otherwise
+func (s *Server) transactItem(request TransactWriteItem) (client.TransactItem, *apiError) {
+	var item client.TransactItem
+	var schema *tableSchema
+	var keyAttrs Item
+	var condition string
+	var names map[string]string
+	var values map[string]AttributeValue
+	var err *apiError
+	kinds := 0
+
+	if put := request.Put; put != nil {
+		kinds++
+		if len(put.Item) == 0 {
+			return item, validationError("Item must not be empty")
+		}
+		if schema, err = s.schema(put.TableName); err != nil {
+			return item, err
+		}
+		if item.Item, err = toItem(put.Item); err != nil {
+			return item, err
+		}
+		item.Table, item.Op, keyAttrs, condition, names, values = put.TableName, constants.CLIENT_REQ_WRITE, schema.keyItem(put.Item), put.ConditionExpression, put.ExpressionAttributeNames, put.ExpressionAttributeValues
+	}
+	if del := request.Delete; del != nil {
+		kinds++
+		item.Table, item.Op, keyAttrs, condition, names, values = del.TableName, constants.CLIENT_REQ_DELETE, del.Key, del.ConditionExpression, del.ExpressionAttributeNames, del.ExpressionAttributeValues
+	}
+	if update := request.Update; update != nil {
+		kinds++
+		if item.Update, err = updateActions(update.Key, update.UpdateExpression, update.ExpressionAttributeNames, update.ExpressionAttributeValues); err != nil {
+			return item, err
+		}
+		item.Table, item.Op, keyAttrs, condition, names, values = update.TableName, constants.CLIENT_REQ_UPDATE, update.Key, update.ConditionExpression, update.ExpressionAttributeNames, update.ExpressionAttributeValues
+	}
+	if check := request.ConditionCheck; check != nil {
+		kinds++
+		if check.ConditionExpression == "" {
+			return item, validationError("ConditionCheck requires a ConditionExpression")
+		}
+		item.Table, item.Op, keyAttrs, condition, names, values = check.TableName, constants.CLIENT_REQ_CHECK, check.Key, check.ConditionExpression, check.ExpressionAttributeNames, check.ExpressionAttributeValues
+	}
+	if kinds != 1 {
+		return item, validationError("a TransactWriteItem must hold exactly one of Put, Delete, Update and ConditionCheck")
+	}
+
+	if schema == nil {
+		if schema, err = s.schema(item.Table); err != nil {
+			return item, err
+		}
+	}
+	if item.Key, err = schema.keyOf(keyAttrs); err != nil {
+		return item, err
+	}
+	if condition != "" {
+		if item.Condition, item.Version, err = parseConditionExpression(condition, names, values, schema.keyAttributes()); err != nil {
+			return item, err
+		}
+	}
+	return item, nil
+}
@@ -0,0 +1,461 @@
+package api
+
+import (
+	"base"
+	"encoding/json"
+)
+
+// AttributeValue keeps DynamoDB's typed encoding, e.g. {"S": "hello"} or {"N": "42"}
+type AttributeValue map[string]json.RawMessage
+
+type Item map[string]AttributeValue
+
+type GetItemInput struct {
+	TableName      string
+	Key            Item
+	ConsistentRead bool
+}
+
+type GetItemOutput struct {
+	Item    Item   `json:",omitempty"`
+	Version string `json:",omitempty"` // expected by a ConditionExpression on VersionAttribute
+}
+
+type PutItemInput struct {
+	TableName                 string
+	Item                      Item
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]AttributeValue
+	ReturnValues              string
+}
+
+type PutItemOutput struct {
+	Version string `json:",omitempty"` // of the item written
+}
+
+type DeleteItemInput struct {
+	TableName                 string
+	Key                       Item
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]AttributeValue
+	ReturnValues              string
+}
+
+type UpdateItemInput struct {
+	TableName                 string
+	Key                       Item
+	UpdateExpression          string
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]AttributeValue
+	ReturnValues              string
+}
+
+type QueryInput struct {
+	TableName                 string
+	IndexName                 string // global secondary index queried, "" for the table
+	KeyConditionExpression    string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]AttributeValue
+	ScanIndexForward          *bool // defaults to true, ascending sort key order
+	Limit                     int
+	ExclusiveStartKey         Item
+	ConsistentRead            bool
+}
+
+type QueryOutput struct {
+	Items            []Item
+	Count            int
+	ScannedCount     int
+	LastEvaluatedKey Item `json:",omitempty"`
+}
+
+type ScanInput struct {
+	TableName         string
+	Limit             int
+	Segment           *int
+	TotalSegments     *int
+	ExclusiveStartKey Item
+	ConsistentRead    bool
+}
+
+type ScanOutput struct {
+	Items            []Item
+	Count            int
+	ScannedCount     int
+	LastEvaluatedKey Item `json:",omitempty"`
+}
+
+type UpdateItemOutput struct {
+	Attributes Item `json:",omitempty"`
+}
+
+type KeysAndAttributes struct {
+	Keys           []Item
+	ConsistentRead bool
+}
+
+type BatchGetItemInput struct {
+	RequestItems map[string]KeysAndAttributes // keys to read by table name
+}
+
+type BatchGetItemOutput struct {
+	Responses       map[string][]Item
+	UnprocessedKeys map[string]KeysAndAttributes
+}
+
+type PutRequest struct {
+	Item Item
+}
+
+type DeleteRequest struct {
+	Key Item
+}
+
+/* WriteRequest holds exactly one of PutRequest and DeleteRequest */
+type WriteRequest struct {
+	PutRequest    *PutRequest    `json:",omitempty"`
+	DeleteRequest *DeleteRequest `json:",omitempty"`
+}
+
+type BatchWriteItemInput struct {
+	RequestItems map[string][]WriteRequest // writes by table name
+}
+
+type BatchWriteItemOutput struct {
+	UnprocessedItems map[string][]WriteRequest
+}
+
+type TransactPut struct {
+	TableName                 string
+	Item                      Item
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]AttributeValue
+}
+
+type TransactDelete struct {
+	TableName                 string
+	Key                       Item
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]AttributeValue
+}
+
+type TransactUpdate struct {
+	TableName                 string
+	Key                       Item
+	UpdateExpression          string
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]AttributeValue
+}
+
+// ConditionCheck checks ConditionExpression on the item under Key without writing it
+type ConditionCheck struct {
+	TableName                 string
+	Key                       Item
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]AttributeValue
+}
+
+/* TransactWriteItem holds exactly one of Put, Delete, Update and ConditionCheck */
+type TransactWriteItem struct {
+	Put            *TransactPut    `json:",omitempty"`
+	Delete         *TransactDelete `json:",omitempty"`
+	Update         *TransactUpdate `json:",omitempty"`
+	ConditionCheck *ConditionCheck `json:",omitempty"`
+}
+
+type TransactWriteItemsInput struct {
+	TransactItems      []TransactWriteItem
+	ClientRequestToken string // accepted, transactions are not deduplicated
+}
+
+type TransactWriteItemsOutput struct{}
+
+// CancellationReason is why an item canceled a transaction, Code is "None" for items that did not
+type CancellationReason struct {
+	Code    string
+	Message string `json:",omitempty"`
+}
+
+type KeySchemaElement struct {
+	AttributeName string
+	KeyType       string // HASH for the partition key, RANGE for the sort key
+}
+
+type AttributeDefinition struct {
+	AttributeName string
+	AttributeType string // S, N or B
+}
+
+type TimeToLiveSpecification struct {
+	AttributeName string
+	Enabled       bool
+}
+
+type StreamSpecification struct {
+	StreamEnabled  bool
+	StreamViewType string // NEW_AND_OLD_IMAGES, records hold the item before and after the write
+}
+
+type Projection struct {
+	ProjectionType string // ALL, index entries hold every attribute of their item
+}
+
+type GlobalSecondaryIndex struct {
+	IndexName  string
+	KeySchema  []KeySchemaElement
+	Projection *Projection // nil for ALL
+}
+
+/* GlobalSecondaryIndexDescription describes an index, PendingUpdates and LagMillis extend the protocol */
+type GlobalSecondaryIndexDescription struct {
+	IndexName      string
+	KeySchema      []KeySchemaElement
+	Projection     Projection
+	IndexStatus    string
+	PendingUpdates int   // entry updates not yet held by W owners
+	LagMillis      int64 // age of the oldest pending update
+}
+
+/* CreateTableInput declares the key schema of a table, N, R, W and ConflictResolution extend the protocol */
+type CreateTableInput struct {
+	TableName               string
+	KeySchema               []KeySchemaElement
+	AttributeDefinitions    []AttributeDefinition
+	GlobalSecondaryIndexes  []GlobalSecondaryIndex
+	StreamSpecification     *StreamSpecification
+	TimeToLiveSpecification *TimeToLiveSpecification
+	N, R, W                 int    // replication settings, 0 for the cluster's
+	ConflictResolution      string // VECTOR_CLOCK or LAST_WRITER_WINS, "" for VECTOR_CLOCK
+}
+
+type TableDescription struct {
+	TableName               string
+	TableStatus             string
+	KeySchema               []KeySchemaElement
+	CreationDateTime        float64                           // epoch seconds
+	TimeToLiveSpecification *TimeToLiveSpecification          `json:",omitempty"`
+	GlobalSecondaryIndexes  []GlobalSecondaryIndexDescription `json:",omitempty"`
+	StreamSpecification     *StreamSpecification              `json:",omitempty"`
+	N, R, W                 int
+	ConflictResolution      string
+}
+
+type CreateTableOutput struct {
+	TableDescription TableDescription
+}
+
+type DeleteTableInput struct {
+	TableName string
+}
+
+type DeleteTableOutput struct {
+	TableDescription TableDescription
+}
+
+type DescribeTableInput struct {
+	TableName string
+}
+
+type DescribeTableOutput struct {
+	Table TableDescription
+}
+
+type ListTablesInput struct {
+	ExclusiveStartTableName string
+	Limit                   int
+}
+
+type ListTablesOutput struct {
+	TableNames             []string
+	LastEvaluatedTableName string `json:",omitempty"`
+}
+
+func describeTable(t base.Table, status string) TableDescription {
+	desc := TableDescription{
+		TableName:          t.Name,
+		TableStatus:        status,
+		KeySchema:          []KeySchemaElement{{AttributeName: t.KeyAttribute, KeyType: "HASH"}},
+		CreationDateTime:   float64(t.CreatedAt.UnixNano()) / 1e9,
+		N:                  t.N,
+		R:                  t.R,
+		W:                  t.W,
+		ConflictResolution: t.ConflictResolution,
+	}
+	if t.SortKeyAttribute != "" {
+		desc.KeySchema = append(desc.KeySchema, KeySchemaElement{AttributeName: t.SortKeyAttribute, KeyType: "RANGE"})
+	}
+	if t.TTLAttribute != "" {
+		desc.TimeToLiveSpecification = &TimeToLiveSpecification{AttributeName: t.TTLAttribute, Enabled: true}
+	}
+	if t.Stream {
+		desc.StreamSpecification = &StreamSpecification{StreamEnabled: true, StreamViewType: "NEW_AND_OLD_IMAGES"}
+	}
+	for _, idx := range t.Indexes {
+		index := GlobalSecondaryIndexDescription{
+			IndexName:   idx.Name,
+			KeySchema:   []KeySchemaElement{{AttributeName: idx.PartitionAttribute, KeyType: "HASH"}},
+			Projection:  Projection{ProjectionType: "ALL"},
+			IndexStatus: status,
+		}
+		if idx.SortAttribute != "" {
+			index.KeySchema = append(index.KeySchema, KeySchemaElement{AttributeName: idx.SortAttribute, KeyType: "RANGE"})
+		}
+		desc.GlobalSecondaryIndexes = append(desc.GlobalSecondaryIndexes, index)
+	}
+	return desc
+}
+
+// Returns the names of the key attributes, the partition key followed by the sort key if any
+func (s *tableSchema) keyAttributes() []string {
+	if s.SortKeyAttribute == "" {
+		return []string{s.KeyAttribute}
+	}
+	return []string{s.KeyAttribute, s.SortKeyAttribute}
+}
+
+// Returns the index name of the table, nil if it has none
+func (s *tableSchema) index(name string) *base.Index {
+	for i := range s.indexes {
+		if s.indexes[i].Name == name {
+			return &s.indexes[i]
+		}
+	}
+	return nil
+}
+
+// Returns the key attributes of an item
+func (s *tableSchema) keyItem(item Item) Item {
+	key := Item{}
+	for _, name := range s.keyAttributes() {
+		if value, exists := item[name]; exists {
+			key[name] = value
+		}
+	}
+	return key
+}
+
+/* Returns the primary key for an item key, which must consist of the key attributes each holding a S, N or B value */
+func (s *tableSchema) keyOf(key Item) (base.Key, *apiError) {
+	names := s.keyAttributes()
+	if len(key) != len(names) {
+		return base.Key{}, validationError("Key must consist of exactly the %q attributes", names)
+	}
+
+	var parts []string
+	for _, name := range names {
+		part, err := keyString(name, key[name])
+		if err != nil {
+			return base.Key{}, err
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 1 {
+		return base.Key{Partition: parts[0]}, nil
+	}
+	return base.Key{Partition: parts[0], Sort: parts[1]}, nil
+}
+
+/*
+Returns the cursor of an index query from an ExclusiveStartKey holding the index key attributes of the queried
+partition and the key attributes of the table.
+*/
+func (s *tableSchema) indexStartKey(start Item, partition string, keyAttribute string, sortKeyAttribute string) (string, *apiError) {
+	key, err := s.keyOf(s.keyItem(start))
+	if err != nil {
+		return "", err
+	}
+	if value, err := keyString(keyAttribute, start[keyAttribute]); err != nil || value != partition {
+		return "", validationError("ExclusiveStartKey must be in the queried partition")
+	}
+	sortValue := ""
+	if sortKeyAttribute != "" {
+		if sortValue, err = keyString(sortKeyAttribute, start[sortKeyAttribute]); err != nil {
+			return "", err
+		}
+	}
+	return base.IndexStartKey(sortValue, key), nil
+}
+
+func keyString(name string, value AttributeValue) (string, *apiError) {
+	if len(value) != 1 {
+		return "", validationError("Key must include the %q attribute", name)
+	}
+
+	for _, keyType := range []string{"S", "N", "B"} {
+		raw, exists := value[keyType]
+		if !exists {
+			continue
+		}
+		var str string
+		if err := json.Unmarshal(raw, &str); err != nil || str == "" {
+			return "", validationError("key attribute %q must be a non-empty %s", name, keyType)
+		}
+		return str, nil
+	}
+	return "", validationError("key attribute %q must be of type S, N or B", name)
+}
+
+/* Converts an item of the protocol into a typed item of the store, rejecting unknown or malformed types */
+func toItem(item Item) (base.Item, *apiError) {
+	ret := make(base.Item, len(item))
+	for name, value := range item {
+		typed, err := toAttributeValue(value)
+		if err != nil {
+			return nil, validationError("attribute %s: %s", name, err.message)
+		}
+		ret[name] = typed
+	}
+	return ret, nil
+}
+
+func toAttributeValue(value AttributeValue) (base.AttributeValue, *apiError) {
+	var typed base.AttributeValue
+	raw, _ := json.Marshal(value)
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return typed, validationError("invalid attribute value %s", raw)
+	}
+	if err := typed.Validate(); err != nil {
+		return typed, validationError("%s", err)
+	}
+	return typed, nil
+}
+
+func fromItem(item base.Item) Item {
+	ret := make(Item, len(item))
+	for name, value := range item {
+		var av AttributeValue
+		raw, _ := json.Marshal(value)
+		json.Unmarshal(raw, &av)
+		ret[name] = av
+	}
+	return ret
+}
+
+func stringValue(s string) AttributeValue {
+	raw, _ := json.Marshal(s)
+	return AttributeValue{"S": raw}
+}
+
+/*
+Decodes the plain data read from the store into an item.
+Values written outside the API (e.g. put(k,v) in the CLI) are returned as a "value" string attribute.
+*/
+func decodeItem(data string, keyAttrs Item) Item {
+	item := Item{}
+	if err := json.Unmarshal([]byte(data), &item); err == nil && len(item) > 0 {
+		return item
+	}
+
+	item = Item{"value": stringValue(data)}
+	for name, value := range keyAttrs {
+		item[name] = value
+	}
+	return item
+}
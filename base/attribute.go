@@ -0,0 +1,261 @@
+package base
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// numbers are kept as strings, like DynamoDB, so they keep their precision
+var numberRegex = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+/*
+AttributeValue is a typed attribute of an item, exactly one of its fields is set.
+Its JSON encoding is the DynamoDB one, e.g. {"S": "hello"} or {"N": "42"}.
+*/
+type AttributeValue struct {
+	S    *string                   `json:",omitempty"`
+	N    *string                   `json:",omitempty"` // decimal number
+	B    []byte                    `json:",omitempty"`
+	BOOL *bool                     `json:",omitempty"`
+	NULL bool                      `json:",omitempty"`
+	L    []AttributeValue          `json:",omitempty"`
+	M    map[string]AttributeValue `json:",omitempty"`
+	SS   []string                  `json:",omitempty"`
+	NS   []string                  `json:",omitempty"`
+}
+
+// Item is a structured value, a map of attribute names to typed values
+type Item map[string]AttributeValue
+
+func S(s string) AttributeValue {
+	return AttributeValue{S: &s}
+}
+
+func N(n string) AttributeValue {
+	return AttributeValue{N: &n}
+}
+
+func B(b []byte) AttributeValue {
+	if b == nil {
+		b = []byte{}
+	}
+	return AttributeValue{B: b}
+}
+
+func Bool(b bool) AttributeValue {
+	return AttributeValue{BOOL: &b}
+}
+
+func Null() AttributeValue {
+	return AttributeValue{NULL: true}
+}
+
+func L(values ...AttributeValue) AttributeValue {
+	if values == nil {
+		values = []AttributeValue{}
+	}
+	return AttributeValue{L: values}
+}
+
+func M(values map[string]AttributeValue) AttributeValue {
+	if values == nil {
+		values = map[string]AttributeValue{}
+	}
+	return AttributeValue{M: values}
+}
+
+func SS(values ...string) AttributeValue {
+	return AttributeValue{SS: values}
+}
+
+func NS(values ...string) AttributeValue {
+	return AttributeValue{NS: values}
+}
+
+// Type returns the DynamoDB type of v, "" if no field is set
+func (v AttributeValue) Type() string {
+	switch {
+	case v.S != nil:
+		return "S"
+	case v.N != nil:
+		return "N"
+	case v.B != nil:
+		return "B"
+	case v.BOOL != nil:
+		return "BOOL"
+	case v.NULL:
+		return "NULL"
+	case v.L != nil:
+		return "L"
+	case v.M != nil:
+		return "M"
+	case v.SS != nil:
+		return "SS"
+	case v.NS != nil:
+		return "NS"
+	}
+	return ""
+}
+
+func (v AttributeValue) numTypes() int {
+	set := 0
+	for _, isSet := range []bool{v.S != nil, v.N != nil, v.B != nil, v.BOOL != nil, v.NULL, v.L != nil, v.M != nil, v.SS != nil, v.NS != nil} {
+		if isSet {
+			set++
+		}
+	}
+	return set
+}
+
+// Validate checks v holds exactly one type, numbers are valid and sets are non-empty without duplicates
+func (v AttributeValue) Validate() error {
+	if v.numTypes() != 1 {
+		return fmt.Errorf("attribute value must have exactly one type, got %d", v.numTypes())
+	}
+
+	switch v.Type() {
+	case "N":
+		if !numberRegex.MatchString(*v.N) {
+			return fmt.Errorf("invalid number %q", *v.N)
+		}
+	case "L":
+		for i, elem := range v.L {
+			if err := elem.Validate(); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+	case "M":
+		return Item(v.M).Validate()
+	case "SS", "NS":
+		set := v.SS
+		if set == nil {
+			set = v.NS
+		}
+		if len(set) == 0 {
+			return fmt.Errorf("%s must not be empty", v.Type())
+		}
+		seen := make(map[string]struct{}, len(set))
+		for _, elem := range set {
+			if v.NS != nil && !numberRegex.MatchString(elem) {
+				return fmt.Errorf("invalid number %q in NS", elem)
+			}
+			if _, duplicate := seen[elem]; duplicate {
+				return fmt.Errorf("duplicate %q in %s", elem, v.Type())
+			}
+			seen[elem] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// Copy returns a deep copy of v, values sent to or stored by nodes do not share memory with the client
+func (v AttributeValue) Copy() AttributeValue {
+	ret := AttributeValue{NULL: v.NULL}
+	if v.S != nil {
+		s := *v.S
+		ret.S = &s
+	}
+	if v.N != nil {
+		n := *v.N
+		ret.N = &n
+	}
+	if v.B != nil {
+		ret.B = append([]byte{}, v.B...)
+	}
+	if v.BOOL != nil {
+		b := *v.BOOL
+		ret.BOOL = &b
+	}
+	if v.L != nil {
+		ret.L = make([]AttributeValue, len(v.L))
+		for i, elem := range v.L {
+			ret.L[i] = elem.Copy()
+		}
+	}
+	if v.M != nil {
+		ret.M = Item(v.M).Copy()
+	}
+	if v.SS != nil {
+		ret.SS = append([]string{}, v.SS...)
+	}
+	if v.NS != nil {
+		ret.NS = append([]string{}, v.NS...)
+	}
+	return ret
+}
+
+// String formats v for the CLI, e.g. "bob", 42, true, null, [1, "a"], {"k": 1}, <<"a", "b">>
+func (v AttributeValue) String() string {
+	switch v.Type() {
+	case "S":
+		return strconv.Quote(*v.S)
+	case "N":
+		return *v.N
+	case "B":
+		return "b64:" + base64.StdEncoding.EncodeToString(v.B)
+	case "BOOL":
+		return strconv.FormatBool(*v.BOOL)
+	case "NULL":
+		return "null"
+	case "L":
+		elems := make([]string, len(v.L))
+		for i, elem := range v.L {
+			elems[i] = elem.String()
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case "M":
+		return Item(v.M).String()
+	case "SS":
+		elems := make([]string, len(v.SS))
+		for i, elem := range v.SS {
+			elems[i] = strconv.Quote(elem)
+		}
+		return "<<" + strings.Join(elems, ", ") + ">>"
+	case "NS":
+		return "<<" + strings.Join(v.NS, ", ") + ">>"
+	}
+	return "<invalid>"
+}
+
+// Validate checks every attribute name is non-empty and every value is valid
+func (item Item) Validate() error {
+	for name, value := range item {
+		if name == "" {
+			return fmt.Errorf("attribute names must not be empty")
+		}
+		if err := value.Validate(); err != nil {
+			return fmt.Errorf("attribute %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (item Item) Copy() Item {
+	if item == nil {
+		return nil
+	}
+	ret := make(Item, len(item))
+	for name, value := range item {
+		ret[name] = value.Copy()
+	}
+	return ret
+}
+
+// String formats the item with its attributes sorted by name
+func (item Item) String() string {
+	names := make([]string, 0, len(item))
+	for name := range item {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]string, len(names))
+	for i, name := range names {
+		attrs[i] = strconv.Quote(name) + ": " + item[name].String()
+	}
+	return "{" + strings.Join(attrs, ", ") + "}"
+}
@@ -0,0 +1,45 @@
+package base
+
+import (
+	"config"
+	"constants"
+	"sync"
+	"time"
+)
+
+/*
+Serves the requests of a CLIENT_REQ_BATCH in parallel, each with the settings of its table, and replies
+CLIENT_ACK_BATCH with their replies in request order once every request has answered. A request that
+does not answer within the client timeout of its command is replied as a NACK.
+*/
+func (n *Node) serveBatch(msg Message, c *config.Config) {
+	replies := make([]Message, len(msg.Batch))
+	var wg sync.WaitGroup
+
+	for i, req := range msg.Batch {
+		wg.Add(1)
+		go func(i int, req Message) {
+			defer wg.Done()
+			reply_ch := make(chan Message, 1) // buffered so a late reply never blocks the request
+			req.JobId, req.SrcID, req.Client_Ch = msg.JobId, msg.SrcID, reply_ch
+			go n.serveClient(req, c)
+
+			command, timeout_ms := constants.CLIENT_NACK_WRITE, c.CLIENT_PUT_TIMEOUT_MS
+			if req.Command == constants.CLIENT_REQ_READ {
+				command, timeout_ms = constants.CLIENT_NACK_READ, c.CLIENT_GET_TIMEOUT_MS
+			}
+			timer := time.NewTimer(time.Duration(timeout_ms) * time.Millisecond)
+			defer timer.Stop()
+			select {
+			case replies[i] = <-reply_ch:
+				return
+			case <-timer.C:
+			case <-n.close_ch:
+			}
+			replies[i] = Message{JobId: msg.JobId, Command: command, Key: req.Key, Reason: "request timed out", SrcID: n.id}
+		}(i, req)
+	}
+	wg.Wait()
+
+	n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_ACK_BATCH, Batch: replies, SrcID: n.id})
+}
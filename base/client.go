@@ -8,10 +8,102 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
-	"sync/atomic"
+	"strings"
 	"time"
 )
 
+var (
+	// no reply was processed by StartListening within the timeout
+	ErrRequestTimeout = errors.New("request timed out")
+	// the client was closed before the reply arrived
+	ErrClientClosed = errors.New("client closed")
+	// another request with the same JobId is still pending on the client
+	ErrDuplicateJob = errors.New("job id already pending")
+	// the coordinator replied KEY_NOT_FOUND
+	ErrKeyNotFound = errors.New("key not found")
+	// the coordinator replied CONDITION_FAILED, the reply carries the current version
+	ErrConditionFailed = errors.New("condition failed")
+	// the coordinator replied INVALID_REQUEST, e.g. ADD on an attribute that is not a counter
+	ErrInvalidRequest = errors.New("invalid request")
+	// the coordinator replied TABLE_NOT_FOUND, or the table named does not exist
+	ErrTableNotFound = errors.New("table not found")
+	// CreateTable was given the name of an existing table
+	ErrTableExists = errors.New("table already exists")
+	// the coordinator replied TRANSACTION_CANCELED, nothing of the transaction was written
+	ErrTransactionCanceled = errors.New("transaction canceled")
+	// the coordinator replied TRANSACTION_CONFLICT, the key is locked by a transaction in progress
+	ErrTransactionConflict = errors.New("transaction conflict")
+	// a shard iterator points before the oldest record retained by its stream
+	ErrTrimmedData = errors.New("stream records trimmed")
+)
+
+// NackError is a failed request reported by the coordinator with CLIENT_NACK_READ or CLIENT_NACK_WRITE
+type NackError struct {
+	Command  int
+	Reason   string
+	Replicas int
+	Quorum   int
+}
+
+func (e *NackError) Error() string {
+	return fmt.Sprintf("%s: %s, %d/%d replicas", constants.GetConstantString(e.Command), e.Reason, e.Replicas, e.Quorum)
+}
+
+/*
+TransactionCanceledError is a transaction aborted by its coordinator with TRANSACTION_CANCELED. Reasons holds the
+error of every item in request order, nil for items that did not cancel it. It matches ErrTransactionCanceled
+and the errors of its reasons, e.g. ErrConditionFailed.
+*/
+type TransactionCanceledError struct {
+	Reasons []error
+}
+
+func (e *TransactionCanceledError) Error() string {
+	reasons := make([]string, len(e.Reasons))
+	for i, reason := range e.Reasons {
+		reasons[i] = "None"
+		if reason != nil {
+			reasons[i] = reason.Error()
+		}
+	}
+	return fmt.Sprintf("%s: [%s]", ErrTransactionCanceled, strings.Join(reasons, ", "))
+}
+
+func (e *TransactionCanceledError) Unwrap() []error {
+	errs := []error{ErrTransactionCanceled}
+	for _, reason := range e.Reasons {
+		if reason != nil {
+			errs = append(errs, reason)
+		}
+	}
+	return errs
+}
+
+// ReplyError returns the error reported by a reply from a node, nil for ACKs
+func ReplyError(msg Message) error {
+	switch msg.Command {
+	case constants.KEY_NOT_FOUND:
+		return ErrKeyNotFound
+	case constants.CONDITION_FAILED:
+		return ErrConditionFailed
+	case constants.INVALID_REQUEST:
+		return fmt.Errorf("%w: %s", ErrInvalidRequest, msg.Reason)
+	case constants.TABLE_NOT_FOUND:
+		return fmt.Errorf("%w: %s", ErrTableNotFound, msg.Reason)
+	case constants.CLIENT_NACK_READ, constants.CLIENT_NACK_WRITE:
+		return &NackError{Command: msg.Command, Reason: msg.Reason, Replicas: msg.Replicas, Quorum: msg.Quorum}
+	case constants.TRANSACTION_CONFLICT:
+		return fmt.Errorf("%w: %s", ErrTransactionConflict, msg.Reason)
+	case constants.TRANSACTION_CANCELED:
+		reasons := make([]error, len(msg.Batch))
+		for i, reply := range msg.Batch {
+			reasons[i] = ReplyError(reply)
+		}
+		return &TransactionCanceledError{Reasons: reasons}
+	}
+	return nil
+}
+
 func ParsePutArg(putRegex string, input string) (string, string, int, error) {
 	re := regexp.MustCompile(putRegex)
 	matches := re.FindStringSubmatch(input)
@@ -26,6 +118,66 @@ func ParsePutArg(putRegex string, input string) (string, string, int, error) {
 	}
 	return matches[1], matches[2], client, nil
 }
+
+/* Parses update(key,ACTION name [value] ...) client_id, e.g. update(k,ADD hits 1 SET name bob REMOVE tmp) 1 */
+func ParseUpdateArg(updateRegex string, input string) (string, []UpdateAction, int, error) {
+	errInvalid := errors.New("invalid update command format, must be update(string,SET name value|REMOVE name|ADD name int ...) int;")
+	re := regexp.MustCompile(updateRegex)
+	matches := re.FindStringSubmatch(input)
+
+	if len(matches) != 4 {
+		return "", nil, 0, errInvalid
+	}
+
+	client, err := strconv.Atoi(matches[3])
+	if err != nil {
+		return "", nil, 0, errInvalid
+	}
+
+	var actions []UpdateAction
+	fields := strings.Fields(matches[2])
+	for i := 0; i < len(fields); {
+		op := strings.ToUpper(fields[i])
+		switch {
+		case op == constants.UPDATE_REMOVE && i+1 < len(fields):
+			actions = append(actions, UpdateAction{Op: op, Name: fields[i+1]})
+			i += 2
+		case op == constants.UPDATE_SET && i+2 < len(fields):
+			actions = append(actions, UpdateAction{Op: op, Name: fields[i+1], Value: ParseValueArg(fields[i+2])})
+			i += 3
+		case op == constants.UPDATE_ADD && i+2 < len(fields):
+			delta, err := strconv.ParseInt(fields[i+2], 10, 64)
+			if err != nil {
+				return "", nil, 0, errInvalid
+			}
+			actions = append(actions, UpdateAction{Op: op, Name: fields[i+1], Delta: delta})
+			i += 3
+		default:
+			return "", nil, 0, errInvalid
+		}
+	}
+	if len(actions) == 0 {
+		return "", nil, 0, errInvalid
+	}
+	return matches[1], actions, client, nil
+}
+
+/* Parses a value typed in the CLI: "quoted" strings, numbers, true, false and null, anything else is a string */
+func ParseValueArg(input string) AttributeValue {
+	if unquoted, err := strconv.Unquote(input); err == nil && strings.HasPrefix(input, `"`) {
+		return S(unquoted)
+	}
+	switch {
+	case numberRegex.MatchString(input):
+		return N(input)
+	case input == "true" || input == "false":
+		return Bool(input == "true")
+	case input == "null":
+		return Null()
+	}
+	return S(input)
+}
+
 func ParseGetArg(getRegex string, input string) (string, int, error) {
 	re := regexp.MustCompile(getRegex)
 	matches := re.FindStringSubmatch(input)
@@ -40,6 +192,124 @@ func ParseGetArg(getRegex string, input string) (string, int, error) {
 	}
 	return matches[1], client, nil
 }
+
+func ParseScanArg(scanRegex string, input string) (int, error) {
+	re := regexp.MustCompile(scanRegex)
+	matches := re.FindStringSubmatch(input)
+
+	if len(matches) != 2 {
+		return 0, errors.New("invalid scan command format, must be scan() int;")
+	}
+
+	client, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, errors.New("invalid scan command format, must be scan() int;")
+	}
+	return client, nil
+}
+
+func ParseStreamArg(streamRegex string, input string) (string, int, error) {
+	re := regexp.MustCompile(streamRegex)
+	matches := re.FindStringSubmatch(input)
+
+	if len(matches) != 3 {
+		return "", 0, errors.New("invalid stream command format, must be stream(table) int;")
+	}
+
+	client, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return "", 0, errors.New("invalid stream command format, must be stream(table) int;")
+	}
+	return strings.TrimSpace(matches[1]), client, nil
+}
+
+/* Parses import(file[,table]) int; and export(file[,table]) int;, the default keyspace if table is omitted */
+func ParseBulkArg(bulkRegex string, input string) (string, string, int, error) {
+	re := regexp.MustCompile(bulkRegex)
+	matches := re.FindStringSubmatch(input)
+
+	errInvalid := errors.New("invalid import or export command format, must be import(file) int;, import(file,table) int;, export(file) int; or export(file,table) int;")
+	if len(matches) != 4 || strings.TrimSpace(matches[1]) == "" {
+		return "", "", 0, errInvalid
+	}
+
+	client, err := strconv.Atoi(matches[3])
+	if err != nil {
+		return "", "", 0, errInvalid
+	}
+	return strings.TrimSpace(matches[1]), strings.TrimSpace(matches[2]), client, nil
+}
+
+var backupNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+/* Parses backup(name) and restore(name), names are letters, digits, '_', '-' or '.' so they stay in the backup directory */
+func ParseBackupArg(backupRegex string, input string) (string, error) {
+	re := regexp.MustCompile(backupRegex)
+	matches := re.FindStringSubmatch(input)
+
+	if len(matches) != 2 || !backupNameRegex.MatchString(strings.TrimSpace(matches[1])) {
+		return "", errors.New("invalid backup command format, must be backup(name); or restore(name); with a name of letters, digits, '_', '-' or '.'")
+	}
+	return strings.TrimSpace(matches[1]), nil
+}
+
+/* Parses set <param> <value>, a setting of config.Config and its integer value */
+func ParseSetArg(setRegex string, input string) (string, int, error) {
+	re := regexp.MustCompile(setRegex)
+	matches := re.FindStringSubmatch(input)
+
+	errInvalid := errors.New("invalid set command format, must be set <param> <value>; with an integer value")
+	if len(matches) != 3 {
+		return "", 0, errInvalid
+	}
+
+	value, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return "", 0, errInvalid
+	}
+	return matches[1], value, nil
+}
+
+/* Parses expect get(string) [int] == value and != value, the value is compared as typed, the client defaults to 1 */
+func ParseExpectArg(expectRegex string, input string) (string, int, bool, string, error) {
+	re := regexp.MustCompile(expectRegex)
+	matches := re.FindStringSubmatch(input)
+
+	errInvalid := errors.New("invalid expect command format, must be expect get(string) [int] == value;, expect get(string) [int] != value; or expect command fails;")
+	if len(matches) != 5 {
+		return "", 0, false, "", errInvalid
+	}
+
+	client := 1
+	if matches[2] != "" {
+		var err error
+		if client, err = strconv.Atoi(matches[2]); err != nil {
+			return "", 0, false, "", errInvalid
+		}
+	}
+	return matches[1], client, matches[3] == "==", strings.TrimSpace(matches[4]), nil
+}
+
+/* Parses sleep(duration), milliseconds or a duration such as 1.5s */
+func ParseSleepArg(sleepRegex string, input string) (time.Duration, error) {
+	re := regexp.MustCompile(sleepRegex)
+	matches := re.FindStringSubmatch(input)
+
+	errInvalid := errors.New("invalid sleep command format, must be sleep(int); in milliseconds or sleep(duration); e.g. sleep(1.5s)")
+	if len(matches) != 2 {
+		return 0, errInvalid
+	}
+
+	if ms, err := strconv.Atoi(matches[1]); err == nil && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	duration, err := time.ParseDuration(matches[1])
+	if err != nil || duration < 0 {
+		return 0, errInvalid
+	}
+	return duration, nil
+}
+
 func ParseKillArg(killRegex string, input string) (int, string, error) {
 
 	re := regexp.MustCompile(killRegex)
@@ -79,7 +349,12 @@ func ParseRevArg(revRegex string, input string) (int, error) {
 // Separate routine from client CLI
 // Single and only source of client channel consume
 // Messages are tracked by JobId to handle multiple requests for same node / dropped requests
+// Replies to job ids nobody waits on are dropped once older than the longer client timeout.
 func (client *Client) StartListening(c *config.Config) {
+	retention := time.Duration(c.CLIENT_GET_TIMEOUT_MS) * time.Millisecond
+	if put := time.Duration(c.CLIENT_PUT_TIMEOUT_MS) * time.Millisecond; put > retention {
+		retention = put
+	}
 	for {
 		select {
 		case <-client.Close:
@@ -88,16 +363,28 @@ func (client *Client) StartListening(c *config.Config) {
 			var debugMsg bytes.Buffer // allow appending of messages
 			debugMsg.WriteString(fmt.Sprintf("Start: %s ", msg.ToString(-1)))
 
-			if !client.AwaitUids[msg.JobId].Load() { // timeout reached for job id
-				delete(client.AwaitUids, msg.JobId)
-
+			now := time.Now()
+			client.mutex.Lock()
+			client.initMaps()
+			client.prune(now, retention)
+			future, waiting := client.awaiting[msg.JobId]
+			_, expired := client.expired[msg.JobId]
+			if expired { // timeout reached for job id
+				delete(client.expired, msg.JobId)
+			} else if waiting {
+				delete(client.awaiting, msg.JobId)
 			} else {
-				client.AwaitUids[msg.JobId].Store(false)
+				client.early[msg.JobId] = earlyReply{msg: msg, at: now}
+			}
+			if !expired && msg.Command == constants.CLIENT_ACK_READ {
+				client.NewestRead = msg.Data //for testing purposes, set before the future completes
+			}
+			client.mutex.Unlock()
 
+			if !expired {
 				switch msg.Command {
 
 				case constants.CLIENT_ACK_READ:
-					client.NewestRead = msg.Data //for testing purposes
 					// TODO: validity check
 					fmt.Printf("COMPLETED Jobid=%d Command=%s: (%s, %s)\n",
 						msg.JobId, constants.GetConstantString(msg.Command), msg.Key, msg.Data)
@@ -111,9 +398,21 @@ func (client *Client) StartListening(c *config.Config) {
 				case constants.CLIENT_ACK_ALIVE:
 					fmt.Printf("Node is alive!")
 
+				case constants.CLIENT_NACK_READ, constants.CLIENT_NACK_WRITE, constants.KEY_NOT_FOUND:
+					fmt.Printf("FAILED Jobid=%d Command=%s: (%s) %s\n",
+						msg.JobId, constants.GetConstantString(msg.Command), msg.Key, ReplyError(msg))
+
+				case constants.CONDITION_FAILED, constants.INVALID_REQUEST, constants.TABLE_NOT_FOUND, constants.TRANSACTION_CONFLICT:
+					fmt.Printf("FAILED Jobid=%d Command=%s: (%s) %s\n",
+						msg.JobId, constants.GetConstantString(msg.Command), msg.Key, msg.Reason)
+
 				default:
 					panic("Unexpected ACK received in client_ch.")
 				}
+
+				if waiting {
+					future.complete(msg, ReplyError(msg))
+				}
 			}
 
 			if c.DEBUG_LEVEL >= constants.VERBOSE_FIXED {
@@ -123,32 +422,118 @@ func (client *Client) StartListening(c *config.Config) {
 	}
 }
 
-// Separate routine from client CLI
-// Constantly consume client_ch even after timeout
-// !!!! Loops infinitely if server drops request and does not ACK it
+// Sends msg to a node and returns a future for its reply without blocking on it.
+// Many requests may be in flight at once, each must use a JobId not pending on this client.
+// The future fails with ErrRequestTimeout if no reply is processed within timeout_ms.
+func (client *Client) Send(node_ch chan Message, msg Message, timeout_ms int) *Future {
+	future, err := client.await(msg.JobId, msg.Command, timeout_ms)
+	if err != nil {
+		return future
+	}
+	msg.Client_Ch = client.Client_ch
+	msg.SrcID = client.Id
+	node_ch <- msg
+	return future
+}
+
+// Blocks until the reply for jobId is processed by StartListening or timeout_ms passes.
+// Returns False on timeout or a failure reply, replies arriving after a timeout are dropped.
 func (client *Client) StartTimeout(jobId int, command int, timeout_ms int) bool {
-	if _, exists := client.AwaitUids[jobId]; !exists {
-		client.AwaitUids[jobId] = new(atomic.Bool)
+	future, _ := client.await(jobId, command, timeout_ms)
+	_, err := future.Wait()
+	return err == nil || err == ErrClientClosed
+}
+
+// Returns the future for jobId, completed right away if its reply was already processed.
+// Returns ErrDuplicateJob, with the future failed with it, if jobId is already pending.
+func (client *Client) await(jobId int, command int, timeout_ms int) (*Future, error) {
+	future := &Future{JobId: jobId, close_ch: client.Close, done: make(chan struct{})}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.initMaps()
+	if reply, answered := client.early[jobId]; answered {
+		delete(client.early, jobId)
+		future.complete(reply.msg, ReplyError(reply.msg))
+		return future, nil
+	}
+	if _, pending := client.awaiting[jobId]; pending {
+		future.complete(Message{}, ErrDuplicateJob)
+		return future, ErrDuplicateJob
 	}
-	client.AwaitUids[jobId].Store(true)
-	reqTime := time.Now()
+	client.awaiting[jobId] = future
+	future.timer = time.AfterFunc(time.Duration(timeout_ms)*time.Millisecond, func() {
+		client.expire(future, command)
+	})
+	return future, nil
+}
 
-	for {
-		select {
-		case <-client.Close:
-			return true
-		default:
-			if !(client.AwaitUids[jobId].Load()) {
-				delete(client.AwaitUids, jobId)
-				return true
-			}
-			if time.Since(reqTime) > time.Duration(timeout_ms)*time.Millisecond {
-				fmt.Printf("TIMEOUT REACHED: Jobid=%d Command=%s\n", jobId, constants.GetConstantString(command))
-				client.AwaitUids[jobId].Store(false)
-				return false
-			}
+// Fails future with ErrRequestTimeout unless its reply raced the timer
+func (client *Client) expire(future *Future, command int) {
+	client.mutex.Lock()
+	if client.awaiting[future.JobId] != future {
+		client.mutex.Unlock()
+		return
+	}
+	delete(client.awaiting, future.JobId)
+	client.expired[future.JobId] = time.Now()
+	client.mutex.Unlock()
+
+	fmt.Printf("TIMEOUT REACHED: Jobid=%d Command=%s\n", future.JobId, constants.GetConstantString(command))
+	future.complete(Message{}, ErrRequestTimeout)
+}
+
+// Returns the number of requests still waiting for a reply
+func (client *Client) Pending() int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return len(client.awaiting)
+}
+
+// Returns the number of replies processed that no request claimed yet, and of timed out job ids still tracked
+func (client *Client) Unclaimed() int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return len(client.early) + len(client.expired)
+}
+
+/*
+Drops the replies no request claimed and the timed out job ids older than retention, at most once per retention.
+A reply is claimed right after its request is sent, so an older one answers a request nobody waits on anymore,
+e.g. a duplicate reply to a request that timed out. Caller must hold client.mutex.
+*/
+func (client *Client) prune(now time.Time, retention time.Duration) {
+	if now.Sub(client.lastPrune) < retention {
+		return
+	}
+	client.lastPrune = now
+	for jobId, reply := range client.early {
+		if now.Sub(reply.at) >= retention {
+			delete(client.early, jobId)
+		}
+	}
+	for jobId, at := range client.expired {
+		if now.Sub(at) >= retention {
+			delete(client.expired, jobId)
 		}
 	}
 }
 
-// func (client *Client)
+// allows Client to be constructed without NewClient. Caller must hold client.mutex.
+func (client *Client) initMaps() {
+	if client.awaiting == nil {
+		client.awaiting = make(map[int]*Future)
+		client.early = make(map[int]earlyReply)
+		client.expired = make(map[int]time.Time)
+	}
+}
+
+// Waits for every future and returns their replies and errors in the same order
+func WaitAll(futures []*Future) ([]Message, []error) {
+	msgs := make([]Message, len(futures))
+	errs := make([]error, len(futures))
+	for i, future := range futures {
+		msgs[i], errs[i] = future.Wait()
+	}
+	return msgs, errs
+}
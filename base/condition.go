@@ -0,0 +1,66 @@
+package base
+
+import (
+	"config"
+	"constants"
+	"fmt"
+)
+
+/*
+Evaluates the condition of a conditional write on obj, the value reconciled from R replicas.
+Replies to the client and returns False if the write must not proceed.
+*/
+func (n *Node) checkCondition(msg Message, obj *Object, c *config.Config) bool {
+	reason, version := conditionFailure(msg.Condition, msg.Version, obj)
+	if reason == "" {
+		return true
+	}
+
+	if c.DEBUG_LEVEL >= constants.INFO {
+		fmt.Printf("Put: Condition failed for job %d: %s\n", msg.JobId, reason)
+	}
+	n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CONDITION_FAILED, Key: msg.Key, Reason: reason, Version: version, SrcID: n.id})
+	return false
+}
+
+// Returns why condition does not hold on obj, "" if it holds, and the version of obj visible to clients
+func conditionFailure(condition int, expected []int, obj *Object) (string, []int) {
+	// deleted values have no version visible to clients
+	var version []int
+	if obj != nil && !obj.isDeleted {
+		version = obj.context.v_clk
+	}
+
+	switch condition {
+	case constants.COND_NONE:
+		return "", version
+	case constants.COND_NOT_EXISTS:
+		if version == nil {
+			return "", version
+		}
+		return "key exists", version
+	case constants.COND_EXISTS:
+		if version != nil {
+			return "", version
+		}
+		return "key does not exist", version
+	case constants.COND_VERSION_EQUALS:
+		if version != nil && equalVC(version, expected) {
+			return "", version
+		}
+		return fmt.Sprintf("version is %v, expected %v", version, expected), version
+	}
+	return fmt.Sprintf("unknown condition %d", condition), version
+}
+
+func equalVC(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -4,7 +4,6 @@ import (
 	"config"
 	"constants"
 	"fmt"
-	"sync/atomic"
 	"time"
 )
 
@@ -13,7 +12,8 @@ func (n *Node) busyWait(duration int, c *config.Config) {
 	if c.DEBUG_LEVEL >= constants.INFO {
 		fmt.Printf("\nbusyWait: %d killed for %d ms...\n", n.GetID(), duration)
 	}
-	reviveTime := time.Now().Add(time.Millisecond * time.Duration(duration))
+	timer := time.NewTimer(time.Millisecond * time.Duration(duration))
+	defer timer.Stop()
 
 	for {
 		select {
@@ -28,48 +28,54 @@ func (n *Node) busyWait(duration int, c *config.Config) {
 				return
 			}
 
-		case <-time.After(10 * time.Millisecond): // check in intervals of 10ms
-			if time.Since(reviveTime) >= 0 {
-				if c.DEBUG_LEVEL >= constants.INFO {
-					fmt.Printf("\nbusyWait: %d reviving...\n", n.GetID())
-				}
-				return
+		case <-timer.C:
+			if c.DEBUG_LEVEL >= constants.INFO {
+				fmt.Printf("\nbusyWait: %d reviving...\n", n.GetID())
 			}
-
+			return
 		}
 	}
 }
 
-/* Send message to update the node with object, Keeps retrying forever.*/
+/*
+Send message to update the node with object, Keeps retrying until ACK or the system is closed.
+Retries resend the same attempt, the ACK of any of them completes the handoff.
+*/
 func (n *Node) restoreHandoff(token *Token, msg Message, c *config.Config) {
 	n.mutex.Lock()
-	if _, exists := n.awaitAck[token.phy_id]; !exists {
-		n.awaitAck[token.phy_id] = new(atomic.Bool)
-	}
-	n.awaitAck[token.phy_id].Store(true)
-	n.channels[token.phy_id] <- msg
+	msg.JobId = n.newJobId()
+	msg.Attempt = 0
+	key, ack_ch := n.awaitAckFrom(token.phy_id, &msg)
 	n.mutex.Unlock()
+	n.channels[token.phy_id] <- msg
 
-	reqTime := time.Now()
+	timeout := time.Duration(c.SET_DATA_TIMEOUT_MS) * time.Millisecond
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
 	for {
-		n.mutex.Lock()
-		if !(n.awaitAck[token.phy_id].Load()) {
+		select {
+		case <-n.close_ch:
+			n.mutex.Lock()
+			delete(n.awaitAck, key)
+			n.mutex.Unlock()
+			return
+
+		case <-ack_ch:
 			if c.DEBUG_LEVEL >= constants.VERBOSE_FIXED {
 				fmt.Printf("restoreHandoff: %d->%d complete.\n", n.GetID(), token.phy_id)
 			}
+			n.mutex.Lock()
 			delete(n.backup, token.phy_id)
 			n.mutex.Unlock()
 			return
-		} else {
-			n.mutex.Unlock()
-		}
-		if time.Since(reqTime) > time.Duration(config.SET_DATA_TIMEOUT_MS)*time.Millisecond {
+
+		case <-timer.C:
 			if c.DEBUG_LEVEL >= constants.VERY_VERBOSE {
 				fmt.Printf("restoreHandoff: %d->%d timeout reached. Retrying...\n", n.GetID(), token.phy_id)
 			}
 			n.channels[token.phy_id] <- msg
-			reqTime = time.Now()
+			timer.Reset(timeout)
 		}
 	}
 }
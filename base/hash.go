@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"strings"
 )
 
 // Function to compute the MD5 hash of a string
@@ -17,6 +18,37 @@ func ComputeMD5(data string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+/*
+Returns the key placing the partitionKey of table on the ring. Items of the default keyspace are
+placed by their partition key, items of a named table by the table name and their partition key.
+*/
+func RoutingKey(table string, partitionKey string) string {
+	if table == "" {
+		return partitionKey
+	}
+	return table + "/" + partitionKey
+}
+
+/*
+Returns the key an item is stored under on its replicas. Items are routed by the hash of their
+routing key, items sharing a partition key are stored under that hash followed by their sort key.
+*/
+func StorageKey(table string, partitionKey string, sortKey string) string {
+	partition := ComputeMD5(RoutingKey(table, partitionKey))
+	if sortKey == "" {
+		return partition
+	}
+	return partition + "#" + sortKey
+}
+
+// Returns the partition hash of a storage key, which places it on the ring
+func partitionOf(storageKey string) string {
+	if i := strings.IndexByte(storageKey, '#'); i >= 0 {
+		return storageKey[:i]
+	}
+	return storageKey
+}
+
 func hashInRange(hashStr string, lowerBound string, upperBound string) bool {
 	hashInt := new(big.Int)
 	hashInt.SetString(hashStr, 16)
@@ -52,6 +84,18 @@ func populatePreferenceList(node *Node, startNode *TreeNode, N int) []*TreeNode
 	return nodes
 }
 
+/*
+Returns the preference list of token holding at least N nodes, False if token has none. Lists are
+precomputed for the cluster's N, those of tables replicating to more nodes are extended along the ring.
+*/
+func (n *Node) preferenceList(token *Token, N int) ([]*TreeNode, bool) {
+	pref, ok := n.prefList[token]
+	if !ok || len(pref) >= N || len(pref) == 0 {
+		return pref, ok
+	}
+	return populatePreferenceList(n, pref[0], N), true
+}
+
 func logPreferenceList(tokenID int, prefList []*TreeNode, c *config.Config) {
 	if c.DEBUG_LEVEL >= constants.VERY_VERBOSE {
 		fmt.Printf("Preference list for token %d: \n", tokenID)
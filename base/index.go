@@ -0,0 +1,256 @@
+package base
+
+import (
+	"config"
+	"constants"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// separates the index sort value and the primary key of the item in the sort key of an index entry
+const indexSeparator = "\x00"
+
+/*
+Index is a global secondary index of a table. Its entries are copies of the items holding its key
+attributes, partitioned by PartitionAttribute and ordered by SortAttribute, stored on the ring as a
+keyspace of their own with the N, R and W of the table. Entries are updated asynchronously once a write
+reached W replicas, so queries of an index may lag behind the table.
+*/
+type Index struct {
+	Name               string
+	PartitionAttribute string // S, N or B attribute partitioning the entries
+	SortAttribute      string // optional S, N or B attribute ordering the entries of a partition
+}
+
+/* IndexStatus reports the entry updates of an index not yet acknowledged by W owners */
+type IndexStatus struct {
+	Pending int           // updates in flight on every node
+	Lag     time.Duration // age of the oldest update in flight, 0 if none
+}
+
+/* An index entry update in flight, for IndexStatus */
+type indexUpdate struct {
+	index string // keyspace of the index
+	since time.Time
+}
+
+// Returns the keyspace of the index of table, '#' is not allowed in table names so keyspaces never collide
+func indexTable(table string, index string) string {
+	return table + "#" + index
+}
+
+/* IndexRoutingKey returns the key placing the partition partitionKey of the index of table on the ring */
+func IndexRoutingKey(table string, index string, partitionKey string) string {
+	return RoutingKey(indexTable(table, index), partitionKey)
+}
+
+// Returns the table and index of an index keyspace, False for tables
+func splitIndexTable(name string) (string, string, bool) {
+	return strings.Cut(name, "#")
+}
+
+// Returns the index name of t, nil if t has none
+func (t *Table) index(name string) *Index {
+	for i := range t.Indexes {
+		if t.Indexes[i].Name == name {
+			return &t.Indexes[i]
+		}
+	}
+	return nil
+}
+
+// Validates the indexes of t
+func (t *Table) initIndexes() error {
+	for i, idx := range t.Indexes {
+		if !tableNameRegex.MatchString(idx.Name) {
+			return fmt.Errorf("%w: index name %q must be 3 to 255 letters, digits, '_', '-' or '.'", ErrInvalidRequest, idx.Name)
+		}
+		if idx.PartitionAttribute == "" {
+			return fmt.Errorf("%w: index %q must name a partition attribute", ErrInvalidRequest, idx.Name)
+		}
+		for _, other := range t.Indexes[:i] {
+			if other.Name == idx.Name {
+				return fmt.Errorf("%w: index %q is declared twice", ErrInvalidRequest, idx.Name)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+Returns the partition and sort key of the entry of obj in idx, False if obj is absent or lacks the key attributes
+of idx. Entries are sorted by the index sort value, then by the primary key of their item.
+*/
+func (idx *Index) entryKey(obj *Object) (string, string, bool) {
+	if obj == nil || obj.isDeleted || strings.Contains(obj.key+obj.sortKey, indexSeparator) {
+		return "", "", false
+	}
+	item := obj.item()
+	partition, ok := indexValue(item[idx.PartitionAttribute])
+	if !ok {
+		return "", "", false
+	}
+	sortValue := ""
+	if idx.SortAttribute != "" {
+		if sortValue, ok = indexValue(item[idx.SortAttribute]); !ok {
+			return "", "", false
+		}
+	}
+	return partition, IndexStartKey(sortValue, Key{Partition: obj.key, Sort: obj.sortKey}), true
+}
+
+// Returns the string an index key attribute is stored under, as the HTTP API keys items: B values are base64 encoded
+func indexValue(v AttributeValue) (string, bool) {
+	var value string
+	switch v.Type() {
+	case "S":
+		value = *v.S
+	case "N":
+		value = *v.N
+	case "B":
+		value = base64.StdEncoding.EncodeToString(v.B)
+	}
+	return value, value != "" && !strings.Contains(value, indexSeparator)
+}
+
+/* IndexStartKey returns the cursor of an index query after the entry of the item under key with the index sort value sortValue */
+func IndexStartKey(sortValue string, key Key) string {
+	return sortValue + indexSeparator + key.Partition + indexSeparator + key.Sort
+}
+
+// Returns the index sort value of the sort key of an index entry
+func indexSortValue(sortKey string) string {
+	sortValue, _, _ := strings.Cut(sortKey, indexSeparator)
+	return sortValue
+}
+
+// Returns the primary key of the item of the sort key of an index entry
+func indexedKey(sortKey string) Key {
+	_, key, _ := strings.Cut(sortKey, indexSeparator)
+	partition, sort, _ := strings.Cut(key, indexSeparator)
+	return Key{Partition: partition, Sort: sort}
+}
+
+/*
+Updates the indexes of t after obj replaced old, nil if there was none. The entry of old is deleted if its index
+key changed and the entry of obj is written. Entries carry the timestamp of obj, index keyspaces resolve conflicts
+by LAST_WRITER_WINS so updates arriving out of order leave the entry of the latest write.
+*/
+func (n *Node) updateIndexes(t *Table, old *Object, obj *Object, c *config.Config) {
+	tc := t.config(c)
+	var wg sync.WaitGroup
+	write := func(entry *Object) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.writeIndexEntry(entry, tc)
+		}()
+	}
+
+	for i := range t.Indexes {
+		idx := &t.Indexes[i]
+		name := indexTable(t.Name, idx.Name)
+		partition, sortKey, indexed := idx.entryKey(obj)
+		if oldPartition, oldSortKey, wasIndexed := idx.entryKey(old); wasIndexed && (!indexed || oldPartition != partition || oldSortKey != sortKey) {
+			write(&Object{context: obj.context.Copy(), table: name, key: oldPartition, sortKey: oldSortKey, isDeleted: true, timestamp: obj.timestamp})
+		}
+		if indexed {
+			write(&Object{context: obj.context.Copy(), table: name, key: partition, sortKey: sortKey, attrs: obj.item(), timestamp: obj.timestamp})
+		}
+	}
+	wg.Wait()
+}
+
+/*
+Sends an index entry to the owners of its partition, asking again those that did not ACK until W of them
+hold it or the system is closed. The update is pending in the IndexStatus of the index until then.
+*/
+func (n *Node) writeIndexEntry(entry *Object, c *config.Config) {
+	replicationCount := GetReplicationCount(c)
+	W := getWCount(c)
+	key := StorageKey(entry.table, entry.key, entry.sortKey)
+	token := n.tokenStruct.Search(ComputeMD5(RoutingKey(entry.table, entry.key)), c).Token
+	pref, ok := n.preferenceList(token, replicationCount)
+	if !ok {
+		return
+	}
+
+	n.mutex.Lock()
+	jobId := n.newJobId()
+	n.indexUpdates[jobId] = indexUpdate{index: entry.table, since: time.Now()}
+	n.mutex.Unlock()
+	defer func() {
+		n.mutex.Lock()
+		delete(n.indexUpdates, jobId)
+		n.mutex.Unlock()
+	}()
+
+	var owners []*Token
+	for i := 0; i < replicationCount && i < len(pref); i++ {
+		owners = append(owners, pref[i].Token)
+	}
+
+	acked := 0
+	for attempt := 0; len(owners) > 0; attempt++ {
+		var mutex sync.Mutex
+		var failed []*Token
+		var wg sync.WaitGroup
+		for _, owner := range owners {
+			wg.Add(1)
+			go func(owner *Token) {
+				defer wg.Done()
+				if !n.updateToken(owner, Message{JobId: jobId, Attempt: attempt, Command: constants.SET_DATA, Key: key, ObjData: entry.Copy(), SrcID: n.GetID()}, c) {
+					mutex.Lock()
+					failed = append(failed, owner)
+					mutex.Unlock()
+				}
+			}(owner)
+		}
+		wg.Wait()
+
+		acked += len(owners) - len(failed)
+		if acked >= W {
+			return
+		}
+		owners = failed
+		select {
+		case <-n.close_ch:
+			return
+		default:
+		}
+		if c.DEBUG_LEVEL >= constants.INFO {
+			fmt.Printf("writeIndexEntry: %d/%d owners of %s hold the entry, retrying\n", acked, W, entry.table)
+		}
+	}
+}
+
+/* DescribeIndex returns the status of the index name of table on the cluster of phy_nodes */
+func DescribeIndex(phy_nodes []*Node, table string, name string) (IndexStatus, error) {
+	t, err := DescribeTable(phy_nodes, table)
+	if err != nil {
+		return IndexStatus{}, err
+	}
+	if t.index(name) == nil {
+		return IndexStatus{}, fmt.Errorf("%w: table %q has no index %q", ErrInvalidRequest, table, name)
+	}
+
+	var status IndexStatus
+	now := time.Now()
+	keyspace := indexTable(table, name)
+	for _, node := range phy_nodes {
+		node.mutex.Lock()
+		for _, update := range node.indexUpdates {
+			if update.index == keyspace {
+				status.Pending++
+				if lag := now.Sub(update.since); lag > status.Lag {
+					status.Lag = lag
+				}
+			}
+		}
+		node.mutex.Unlock()
+	}
+	return status, nil
+}
@@ -6,14 +6,15 @@ import (
 	"constants"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
-func (n *Node) Start(wg *sync.WaitGroup, c *config.Config) {
+/* Serves the messages of the node until close_ch is closed, each with the settings of the node when it arrives */
+func (n *Node) Start(wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for {
@@ -23,15 +24,19 @@ func (n *Node) Start(wg *sync.WaitGroup, c *config.Config) {
 			return
 
 		case msg := <-n.rcv_ch:
+			c := n.Config()
 			var debugMsg bytes.Buffer // allow appending of messages
 			debugMsg.WriteString(fmt.Sprintf("Start: %s ", msg.ToString(n.GetID())))
 
 			switch msg.Command {
-			case constants.CLIENT_REQ_READ:
-				go n.Get(msg, c) // nicer debug message (CLIENT_REQ_READ before subsequent handling messages)
+			case constants.CLIENT_REQ_READ, constants.CLIENT_REQ_WRITE, constants.CLIENT_REQ_DELETE, constants.CLIENT_REQ_UPDATE, constants.CLIENT_REQ_QUERY, constants.CLIENT_REQ_SCAN:
+				go n.serveClient(msg, c) // nicer debug message (CLIENT_REQ_READ before subsequent handling messages)
 
-			case constants.CLIENT_REQ_WRITE:
-				go n.Put(msg, msg.Data, c)
+			case constants.CLIENT_REQ_BATCH:
+				go n.serveBatch(msg, c)
+
+			case constants.CLIENT_REQ_TRANSACT:
+				go n.Transact(msg, c)
 
 			case constants.CLIENT_REQ_KILL:
 				duration, err := strconv.Atoi(strings.TrimSpace(msg.Data))
@@ -39,47 +44,121 @@ func (n *Node) Start(wg *sync.WaitGroup, c *config.Config) {
 					fmt.Printf("CLIENT_REQ_KILL ERROR: %d->%d invalid duration %s, expect integer value denoting milliseconds to kill for.", msg.SrcID, n.GetID(), msg.Data)
 				}
 				n.busyWait(duration, c) // blocking
+				go n.recoverTransactions(c)
 
 			case constants.SET_DATA:
-				n.data[msg.Key] = msg.ObjData
-				n.channels[msg.SrcID] <- Message{JobId: msg.JobId, Command: constants.ACK_SET_DATA, Key: msg.Key, SrcID: n.GetID(), ObjData: msg.ObjData}
+				n.mutex.Lock()
+				if n.tables.exists(msg.ObjData.table) { // late writes to a deleted table are dropped
+					n.store(msg.Key, n.storeVersion(n.data[msg.Key], msg.ObjData))
+				}
+				n.mutex.Unlock()
+				n.channels[msg.SrcID] <- Message{JobId: msg.JobId, Attempt: msg.Attempt, Command: constants.ACK_SET_DATA, Key: msg.Key, SrcID: n.GetID(), ObjData: msg.ObjData}
 
 			case constants.BACK_DATA:
 				backupID := msg.HandoffToken.phy_id
+				n.mutex.Lock()
 				if _, exists := n.backup[backupID]; !exists {
 					n.backup[backupID] = make(map[string]*Object)
 				}
-				n.backup[backupID][msg.Key] = msg.ObjData
-				n.channels[msg.SrcID] <- Message{JobId: msg.JobId, Command: constants.ACK_BACK_DATA, Key: msg.Key, SrcID: n.GetID()}
+				if n.tables.exists(msg.ObjData.table) {
+					n.backup[backupID][msg.Key] = n.storeVersion(n.backup[backupID][msg.Key], msg.ObjData)
+				}
+				n.mutex.Unlock()
+				n.channels[msg.SrcID] <- Message{JobId: msg.JobId, Attempt: msg.Attempt, Command: constants.ACK_BACK_DATA, Key: msg.Key, SrcID: n.GetID()}
 				msg.Command = constants.SET_DATA
 				msg.SrcID = n.GetID()
 				go n.restoreHandoff(msg.HandoffToken, msg, c)
 
 			case constants.READ_DATA: //coordinator requested to read data, so send it back
 				//return data
-				obj := n.data[msg.Key]
+				n.mutex.Lock()
+				obj := n.data[msg.Key].Copy()
+				n.mutex.Unlock()
 				fmt.Printf("[%d] send acknowledgement\n", n.id)
-				n.channels[msg.SrcID] <- Message{JobId: msg.JobId, Command: constants.READ_DATA_ACK, Key: msg.Key, SrcID: n.GetID(), ObjData: obj, Client_Ch: msg.Client_Ch}
+				n.channels[msg.SrcID] <- Message{JobId: msg.JobId, Command: constants.READ_DATA_ACK, Key: msg.Key, SrcID: n.GetID(), ObjData: obj}
 
 			case constants.READ_DATA_ACK:
-				n.numReads[msg.JobId]++
-				debugMsg.WriteString(fmt.Sprintf("numReads: %d", n.numReads))
-				R := getRCount(c)
-				if n.numReads[msg.JobId] == R {
-					n.reconcile(n.data[msg.Key], msg.ObjData)
-					msg.Client_Ch <- Message{JobId: msg.JobId, Command: constants.CLIENT_ACK_READ, Key: msg.Key, Data: n.data[msg.Key].data, SrcID: n.GetID()}
+				n.mutex.Lock()
+				if read, pending := n.reads[msg.JobId]; pending {
+					read.replicas++
+					debugMsg.WriteString(fmt.Sprintf("numReads: %d", read.replicas))
+					if read.obj == nil {
+						read.obj = msg.ObjData.Copy()
+					} else {
+						n.reconcile(read.obj, msg.ObjData)
+					}
+					if read.replicas == read.quorum {
+						close(read.done)
+						delete(n.reads, msg.JobId)
+					}
+				}
+				n.mutex.Unlock()
+
+			case constants.QUERY_DATA: //coordinator requested the items of a partition
+				n.mutex.Lock()
+				objs := n.queryPartition(msg.Key, msg.Query)
+				n.mutex.Unlock()
+				n.channels[msg.SrcID] <- Message{JobId: msg.JobId, Command: constants.QUERY_DATA_ACK, Key: msg.Key, SrcID: n.GetID(), Objects: objs}
+
+			case constants.SCAN_DATA: //scan coordinator requested the items of a hash range
+				n.mutex.Lock()
+				objs := n.scanRange(msg.scanRange)
+				n.mutex.Unlock()
+				n.channels[msg.SrcID] <- Message{JobId: msg.JobId, Command: constants.SCAN_DATA_ACK, SrcID: n.GetID(), Objects: objs}
+
+			case constants.QUERY_DATA_ACK, constants.SCAN_DATA_ACK:
+				n.mutex.Lock()
+				if read, pending := n.reads[msg.JobId]; pending {
+					read.replicas++
+					n.reconcileItems(read, msg.Objects)
+					if read.replicas >= read.quorum { // a scan with R < 1 completes on its first reply
+						close(read.done)
+						delete(n.reads, msg.JobId)
+					}
+				}
+				n.mutex.Unlock()
+
+			case constants.TX_PREPARE: //transaction coordinator locks the key and reads it
+				vote := Message{JobId: msg.JobId, Command: constants.TX_PREPARE_ACK, Key: msg.Key, TxId: msg.TxId, SrcID: n.GetID()}
+				n.mutex.Lock()
+				if lock, locked := n.intents[msg.Key]; locked && lock.txId != msg.TxId {
+					vote.Reason = fmt.Sprintf("key is locked by transaction %s", lock.txId)
 				} else {
-					n.reconcile(n.data[msg.Key], msg.ObjData)
+					n.intents[msg.Key] = &intent{txId: msg.TxId, coordinator: msg.SrcID}
+					vote.ObjData = n.data[msg.Key].Copy()
 				}
+				n.mutex.Unlock()
+				n.channels[msg.SrcID] <- vote
 
-			case constants.ACK_SET_DATA:
+			case constants.TX_PREPARE_ACK:
 				n.mutex.Lock()
-				n.awaitAck[msg.SrcID].Store(false)
+				if votes, pending := n.votes[msg.JobId]; pending {
+					votes.countVote(n, msg)
+					if votes.replies == votes.owners || votes.conflict != "" {
+						close(votes.done)
+						delete(n.votes, msg.JobId)
+					}
+				}
 				n.mutex.Unlock()
 
-			case constants.ACK_BACK_DATA:
+			case constants.TX_COMMIT, constants.TX_ABORT:
+				n.resolveIntent(msg)
+				if msg.Command == constants.TX_COMMIT && msg.Attempt >= 0 { // outcomes answering TX_STATUS are not ACKed
+					n.channels[msg.SrcID] <- Message{JobId: msg.JobId, Attempt: msg.Attempt, Command: constants.TX_COMMIT_ACK, Key: msg.Key, TxId: msg.TxId, SrcID: n.GetID()}
+				}
+
+			case constants.TX_STATUS: //a participant asks for the outcome of a transaction it holds a lock for
+				if reply, decided := n.transactionOutcome(msg); decided {
+					n.channels[msg.SrcID] <- reply
+				}
+
+			case constants.ACK_SET_DATA, constants.ACK_BACK_DATA, constants.TX_COMMIT_ACK:
+				key := ackKey{jobId: msg.JobId, dst: msg.SrcID, attempt: msg.Attempt}
 				n.mutex.Lock()
-				n.awaitAck[msg.SrcID].Store(false)
+				if ack_ch, exists := n.awaitAck[key]; exists {
+					close(ack_ch)
+					delete(n.awaitAck, key)
+				}
 				n.mutex.Unlock()
 
 			case constants.ALIVE_ACK:
@@ -89,17 +168,40 @@ func (n *Node) Start(wg *sync.WaitGroup, c *config.Config) {
 			if c.DEBUG_LEVEL >= constants.VERBOSE_FIXED {
 				fmt.Printf("%s\n", debugMsg.String())
 			}
-
-		case jobId := <-n.readTimeout:
-			R := getRCount(c)
-			if n.numReads[jobId] < R {
-				fmt.Println("Quorum not fulfilled for get(), get() failed")
-				n.numReads[jobId] = -c.NUM_NODES //set to some negative number so it will not send
-			}
 		}
 	}
 }
 
+// Config returns the settings the node serves its requests with
+func (n *Node) Config() *config.Config {
+	return n.config.Load()
+}
+
+var configMutex sync.Mutex // serializes SetConfig, updates are not lost
+
+/*
+SetConfig sets the setting name of the cluster of phy_nodes to value and returns the updated settings, see
+config.Update for the settings that can change. Every node, killed ones included, serves the messages it
+receives from now on with them, requests in progress finish with the settings they started with.
+*/
+func SetConfig(phy_nodes []*Node, name string, value int) (config.Config, error) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if len(phy_nodes) == 0 {
+		return config.Config{}, fmt.Errorf("%w: the cluster has no node", ErrInvalidRequest)
+	}
+	updated, err := phy_nodes[0].Config().Update(name, value)
+	if err != nil {
+		return updated, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	for _, n := range phy_nodes {
+		settings := updated
+		n.config.Store(&settings)
+	}
+	return updated, nil
+}
+
+/* R of c, clamped into [0, min(N, NUM_NODES)] for configs with errors of config.Validate, printed by CreateNodes */
 func getRCount(c *config.Config) int {
 	rCount := 0
 	if c.R > 0 {
@@ -141,84 +243,153 @@ func FindPrefList(token *Token, phy_nodes []*Node, cnt int) *Node {
 	return phy_nodes[pref[cnt].Token.phy_id]
 }
 
-// helper func for GET
-func (n *Node) requestTreeNodeData(curToken *Token, hashKey string, c *config.Config) *Object {
-	if _, exists := n.awaitAck[curToken.phy_id]; !exists {
-		n.awaitAck[curToken.phy_id] = new(atomic.Bool)
+// attempt to reconcile original with receiving. Caller must hold n.mutex.
+func (n *Node) reconcile(original *Object, replica *Object) {
+	fmt.Println(replica)
+	if replica == nil {
+		return //don't reconcile if there is nothing at replica
 	}
 
-	n.awaitAck[curToken.phy_id].Store(true)
-	n.channels[curToken.phy_id] <- Message{Command: constants.CLIENT_REQ_READ, Key: hashKey, SrcID: n.GetID()}
-	reqTime := time.Now()
+	order := compareVC(original.context.v_clk, replica.context.v_clk)
+	if n.tables.conflictResolution(original.table) == constants.CONFLICT_LAST_WRITER_WINS {
+		order = compareTimestamps(original, replica)
+	}
 
-	for {
-		if !(n.awaitAck[curToken.phy_id].Load()) {
-			//receive the ACK with data
-			resp := <-n.rcv_ch
-			return resp.ObjData
-		}
+	switch order {
+	case 1: //means the replica has a strictly greater clock, reconcile.
+		latest := replica.Copy()
+		original.key = latest.key
+		original.sortKey = latest.sortKey
+		original.data = latest.data
+		original.attrs = latest.attrs
+		original.counters = latest.counters
+		original.isDeleted = latest.isDeleted
+		original.context = latest.context
+		original.timestamp = latest.timestamp
+	case 0: //means the replica and original have concurrent copies. original keeps its own copy, counters merge
+		original.mergeCounters(replica)
+	}
+	//if -1, means original alrd has the latest clock
+}
 
-		if time.Since(reqTime) > time.Duration(c.SET_DATA_TIMEOUT_MS)*time.Millisecond {
-			fmt.Printf("node %d: request node %d timeout reached.\n", n.GetID(), curToken.phy_id)
-			break
+/*
+Returns the object to store when received replaces stored, a copy of received.
+Writes are applied in arrival order, counters of concurrent versions are merged so no increment is lost.
+Tables resolving conflicts by LAST_WRITER_WINS keep stored if it was written later than received.
+Caller must hold n.mutex.
+*/
+func (n *Node) storeVersion(stored *Object, received *Object) *Object {
+	if stored != nil && n.tables.conflictResolution(received.table) == constants.CONFLICT_LAST_WRITER_WINS {
+		if compareTimestamps(stored, received) == -1 {
+			return stored
 		}
+		return received.Copy()
 	}
-	return nil
-}
 
-// attempt to reconcile original with receiving
-func (n *Node) reconcile(original *Object, replica *Object) {
-	fmt.Println(replica)
-	if replica == nil {
-		return //don't reconcile if there is nothing at replica
+	obj := received.Copy()
+	if stored != nil && compareVC(stored.context.v_clk, obj.context.v_clk) == 0 {
+		obj.mergeCounters(stored)
 	}
+	return obj
+}
 
-	//if 1, means the replica has a strictly greater clock, reconcile.
-	if compareVC(replica.context.v_clk, original.context.v_clk) == 1 {
-		original.data = replica.data
-		original.context = replica.Copy().context
+// helper func for LAST_WRITER_WINS, compares as compareVC: 1 if b was written after a, -1 if before, 0 if at the same time
+func compareTimestamps(a, b *Object) int {
+	if a.timestamp < b.timestamp {
+		return 1
+	}
+	if a.timestamp > b.timestamp {
+		return -1
 	}
-	//if 0, means the replica and original have concurrent copies. original keeps its own copy
-	//if -1, means latestObj=objects[0] alrd has the latest clock
+	return 0
 }
 
 // helper func for GET
-// if A -> B, A strictly lesser than B
+// if A -> B, A strictly lesser than B and 1 is returned. -1 if B -> A, 0 if equal or concurrent.
+// Clocks are compared as a partial order, not lexicographically, so versions written without seeing each other
+// are found concurrent and keep the increments of both through mergeCounters, see reconcile and storeVersion.
 func compareVC(a, b []int) int {
+	lesser, greater := false, false
 	for i := range a {
 		if a[i] > b[i] {
-			return -1
+			greater = true
 		} else if a[i] < b[i] {
-			return 1
+			lesser = true
 		}
 	}
+	if lesser && !greater {
+		return 1
+	}
+	if greater && !lesser {
+		return -1
+	}
 	return 0
 }
 
-// internal function GET
-func (n *Node) Get(msg Message, c *config.Config) {
-	n.increment_vclk()
-	hashKey := ComputeMD5(msg.Key)
-
+// internal function GET, c holds the replication settings of table t
+func (n *Node) Get(msg Message, t *Table, c *config.Config) {
 	R := getRCount(c)
-
-	if _, exists := n.data[hashKey]; !exists {
+	obj, replicas, ok := n.readQuorum(StorageKey(msg.Table, msg.Key, msg.SortKey), c)
+	if !ok {
+		if c.DEBUG_LEVEL >= constants.INFO {
+			fmt.Printf("Get: Quorum not fulfilled for job %d, %d/%d replicas answered\n", msg.JobId, replicas, R)
+		}
+		n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_NACK_READ, Key: msg.Key, Reason: "read quorum not reached", Replicas: replicas, Quorum: R, SrcID: n.GetID()})
 		return
 	}
 
-	//consider trivial case where R = 1
-	//function just passes its data to the client and returns
-	if R == 1 {
-		msg.Client_Ch <- Message{JobId: msg.JobId, Command: constants.CLIENT_ACK_READ, Key: hashKey, Data: n.data[hashKey].data, SrcID: n.GetID()}
+	if obj == nil || obj.isDeleted || t.expired(obj, time.Now()) {
+		n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.KEY_NOT_FOUND, Key: msg.Key, SrcID: n.GetID()})
 		return
 	}
+	n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_ACK_READ, Key: msg.Key, Data: obj.data, Attrs: obj.item(), Version: obj.context.v_clk, SrcID: n.GetID()})
+}
+
+/*
+Reads the storage key from the coordinator and its N-1 successors, returns the object reconciled from
+the first R replies (nil if no replica holds the key) and the number of replicas that answered.
+Returns False if fewer than R replicas answered within CLIENT_GET_TIMEOUT_MS.
+The coordinator's own copy is repaired with the reconciled object.
+*/
+func (n *Node) readQuorum(key string, c *config.Config) (*Object, int, bool) {
+	R := getRCount(c)
+
+	// the coordinator counts as the first replica, the trivial case R = 1 does not query other nodes
+	n.mutex.Lock()
+	n.increment_vclk()
+	read := &quorumRead{obj: n.data[key].Copy(), replicas: 1, quorum: R, done: make(chan struct{})}
+	jobId := n.newJobId()
+	if R > 1 {
+		n.reads[jobId] = read
+	}
+	n.mutex.Unlock()
 
-	n.numReads[msg.JobId] = 1
-	//set up timer for the particular jobId here
+	if R <= 1 {
+		return read.obj, read.replicas, true
+	}
+
+	replicas, ok := n.awaitReplicas(Message{JobId: jobId, Command: constants.READ_DATA, Key: key, SrcID: n.GetID()}, read, c)
+	if !ok {
+		return nil, replicas, false
+	}
 
-	curTreeNode := n.tokenStruct.Search(hashKey, c)
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if local, exists := n.data[key]; exists && read.obj != nil {
+		n.reconcile(local, read.obj)
+	}
+	return read.obj.Copy(), replicas, true
+}
+
+/*
+Sends req to the N-1 successors of the coordinator for the partition of req.Key, and waits for the
+replies to complete read, registered under req.JobId. Returns as awaitRead.
+*/
+func (n *Node) awaitReplicas(req Message, read *quorumRead, c *config.Config) (int, bool) {
+	curTreeNode := n.tokenStruct.Search(partitionOf(req.Key), c)
 	initToken := curTreeNode.Token
 	visitedNodes := make(map[int]struct{}) // To keep track of unique physical nodes
+	visitedNodes[n.id] = struct{}{}
 
 	reqCounter := 0
 
@@ -231,25 +402,52 @@ func (n *Node) Get(msg Message, c *config.Config) {
 		}
 
 		if _, visited := visitedNodes[curToken.phy_id]; !visited {
-			n.channels[curToken.phy_id] <- Message{JobId: msg.JobId, Command: constants.READ_DATA, Key: hashKey, SrcID: n.GetID(), Client_Ch: msg.Client_Ch}
+			n.channels[curToken.phy_id] <- req.Copy()
 			visitedNodes[curToken.phy_id] = struct{}{}
 			reqCounter++
 		}
 	}
+	return n.awaitRead(req.JobId, read, c)
+}
 
-	go n.startTimer(time.Duration(c.CLIENT_GET_TIMEOUT_MS)*time.Millisecond, msg.JobId)
+/*
+Waits for the replies to complete read, registered under jobId. Returns the number of replicas that
+answered, and False if fewer than R answered within CLIENT_GET_TIMEOUT_MS, late replies are then dropped.
+*/
+func (n *Node) awaitRead(jobId int, read *quorumRead, c *config.Config) (int, bool) {
+	timer := time.NewTimer(time.Duration(c.CLIENT_GET_TIMEOUT_MS) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-read.done:
+	case <-timer.C:
+	case <-n.close_ch:
+	}
 
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if _, pending := n.reads[jobId]; pending {
+		delete(n.reads, jobId) // late replies are dropped
+		return read.replicas, false
+	}
+	return read.replicas, true
 }
 
-func (n *Node) startTimer(duration time.Duration, jobId int) {
-	timer := time.NewTimer(duration)
-	<-timer.C
-	n.readTimeout <- jobId
+/* Sends a reply to a client from a request goroutine, gives up if the system is closed first */
+func (n *Node) replyClient(client_ch chan Message, msg Message) {
+	select {
+	case client_ch <- msg:
+	case <-n.close_ch:
+	}
 }
 
 func CreateNodes(close_ch chan struct{}, c *config.Config) []*Node {
 	fmt.Println("Constructing machines...")
+	if err := c.Validate().Err(); err != nil {
+		fmt.Printf("CreateNodes: N, R and W are clamped into range, the config has errors:\n%v\n", err)
+	}
 
+	tables := newCatalog()
+	settings := *c // copied, so later changes to c do not reach the nodes
 	numNodes := c.NUM_NODES
 	var nodeGroup []*Node
 	for j := 0; j < numNodes; j++ {
@@ -260,20 +458,27 @@ func CreateNodes(close_ch chan struct{}, c *config.Config) []*Node {
 
 		//make j nodes
 		node := Node{
-			id:          j,
-			v_clk:       make([]int, numNodes),
-			channels:    make(map[int](chan Message)),
-			rcv_ch:      make(chan Message, numNodes*100),
-			data:        make(map[string]*Object),
-			backup:      make(map[int](map[string]*Object)),
-			tokenStruct: BST{},
-			close_ch:    close_ch,
-			awaitAck:    make(map[int](*atomic.Bool)),
-			prefList:    pl,
-			numReads:    make(map[int]int),
-			readTimeout: make(chan int),
+			id:           j,
+			v_clk:        make([]int, numNodes),
+			channels:     make(map[int](chan Message)),
+			rcv_ch:       make(chan Message, numNodes*100),
+			data:         make(map[string]*Object),
+			partitions:   make(map[string][]string),
+			backup:       make(map[int](map[string]*Object)),
+			tokenStruct:  BST{},
+			close_ch:     close_ch,
+			tables:       tables,
+			awaitAck:     make(map[ackKey](chan struct{})),
+			prefList:     pl,
+			reads:        make(map[int]*quorumRead),
+			txns:         make(map[string]*transaction),
+			intents:      make(map[string]*intent),
+			votes:        make(map[int]*txVotes),
+			indexUpdates: make(map[int]indexUpdate),
+			keyLocks:     make(map[string]*keyLock),
 		}
 
+		node.config.Store(&settings)
 		nodeGroup = append(nodeGroup, &node)
 	}
 
@@ -293,20 +498,83 @@ func CreateNodes(close_ch chan struct{}, c *config.Config) []*Node {
 
 }
 
+/* Stores obj under the storage key, indexing the sort key of new items. Caller must hold n.mutex. */
+func (n *Node) store(key string, obj *Object) {
+	if _, exists := n.data[key]; !exists && obj.sortKey != "" {
+		partition := partitionOf(key)
+		sortKeys := n.partitions[partition]
+		i := sort.SearchStrings(sortKeys, obj.sortKey)
+		sortKeys = append(sortKeys, "")
+		copy(sortKeys[i+1:], sortKeys[i:])
+		sortKeys[i] = obj.sortKey
+		n.partitions[partition] = sortKeys
+	}
+	n.data[key] = obj
+}
+
+/* Returns a snapshot of the items this node stores under partitionKey of table, ordered by sort key. Deleted items are included as tombstones. */
+func (n *Node) GetPartition(table string, partitionKey string) []*Object {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.queryPartition(ComputeMD5(RoutingKey(table, partitionKey)), nil)
+}
+
+/* Returns a job id unique to this node for inter-node requests. Caller must hold n.mutex. */
+func (n *Node) newJobId() int {
+	n.lastJobId++
+	return n.lastJobId
+}
+
+/* Returns the channel closed when node dst ACKs msg. Caller must hold n.mutex. */
+func (n *Node) awaitAckFrom(dst int, msg *Message) (ackKey, chan struct{}) {
+	key := ackKey{jobId: msg.JobId, dst: dst, attempt: msg.Attempt}
+	ack_ch := make(chan struct{})
+	n.awaitAck[key] = ack_ch
+	return key, ack_ch
+}
+
+/* Caller must hold n.mutex. */
 func (n *Node) copy_vclk() []int {
 	copy_clk := make([]int, len(n.v_clk)) //send time of election
 	copy(copy_clk, n.v_clk)
 	return copy_clk
 }
 
+/* Advances the clock past v_clk, e.g. the version read by a read-modify-write. Caller must hold n.mutex. */
+func (n *Node) merge_vclk(v_clk []int) {
+	for i := range n.v_clk {
+		if i < len(v_clk) && v_clk[i] > n.v_clk[i] {
+			n.v_clk[i] = v_clk[i]
+		}
+	}
+}
+
+/* Caller must hold n.mutex. */
 func (n *Node) increment_vclk() {
 	n.v_clk[n.id]++
 }
 
+/* Returns a snapshot of the data store, later writes to the node are not reflected in it */
 func (n *Node) GetAllData() map[string]*Object {
-	return n.data
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	data := make(map[string]*Object, len(n.data))
+	for key, obj := range n.data {
+		data[key] = obj.Copy()
+	}
+	return data
 }
 
+/* Returns a snapshot of the hinted handoff backups, later writes to the node are not reflected in it */
 func (n *Node) GetAllBackup() map[int]map[string]*Object {
-	return n.backup
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	backup := make(map[int]map[string]*Object, len(n.backup))
+	for nodeId, objs := range n.backup {
+		backup[nodeId] = make(map[string]*Object, len(objs))
+		for key, obj := range objs {
+			backup[nodeId][key] = obj.Copy()
+		}
+	}
+	return backup
 }
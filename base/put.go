@@ -5,11 +5,10 @@ import (
 	"constants"
 	"fmt"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
-/* Get Replication count */
+/* Get Replication count, N clamped into [0, min(NUM_NODES, NUM_TOKENS)] as reported by config.Validate */
 func GetReplicationCount(c *config.Config) int {
 	replicationCount := 0
 
@@ -26,6 +25,7 @@ func GetReplicationCount(c *config.Config) int {
 	return replicationCount
 }
 
+/* W of c, clamped into [0, min(N, NUM_NODES)] for configs with errors of config.Validate, printed by CreateNodes */
 func getWCount(c *config.Config) int {
 	wCount := 0
 	if c.W > 0 {
@@ -45,31 +45,35 @@ func getWCount(c *config.Config) int {
 /* Send message to update the node with object. Returns True if ACK receive within timeout, False otherwise */
 func (n *Node) updateToken(token *Token, msg Message, c *config.Config) bool {
 	n.mutex.Lock()
-	if _, exists := n.awaitAck[token.phy_id]; !exists {
-		n.awaitAck[token.phy_id] = new(atomic.Bool)
-	}
-	n.awaitAck[token.phy_id].Store(true)
-	n.channels[token.phy_id] <- msg
+	key, ack_ch := n.awaitAckFrom(token.phy_id, &msg)
 	n.mutex.Unlock()
-	reqTime := time.Now()
+	n.channels[token.phy_id] <- msg
 
-	for {
+	timer := time.NewTimer(time.Duration(c.SET_DATA_TIMEOUT_MS) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-ack_ch:
+		return true
+	case <-timer.C:
 		n.mutex.Lock()
-		if !(n.awaitAck[token.phy_id].Load()) {
-			n.mutex.Unlock()
-			return true
-		} else {
-			n.mutex.Unlock()
-		}
-		if time.Since(reqTime) > time.Duration(c.SET_DATA_TIMEOUT_MS)*time.Millisecond {
-			if c.DEBUG_LEVEL >= constants.VERBOSE_FIXED {
-				fmt.Printf("updateToken: %d->%d timeout reached.\n", n.GetID(), token.phy_id)
-			}
-			return false
+		delete(n.awaitAck, key)
+		n.mutex.Unlock()
+		if c.DEBUG_LEVEL >= constants.VERBOSE_FIXED {
+			fmt.Printf("updateToken: %d->%d timeout reached.\n", n.GetID(), token.phy_id)
 		}
+		return false
 	}
 }
 
+/* NACKs the write msg that only replicated to replicated of the replicationCount owners, short of W */
+func (n *Node) failWrite(msg Message, replicated int, replicationCount int, W int, c *config.Config) {
+	if c.DEBUG_LEVEL >= constants.VERBOSE_FIXED {
+		fmt.Printf("Put: ERROR! Only replicated %d/%d times!\n", replicated, replicationCount)
+	}
+	n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_NACK_WRITE, Key: msg.Key, Reason: "write quorum not reached", Replicas: replicated, Quorum: W, SrcID: n.id})
+}
+
 /* Issue replication request, update a queue if replication failed */
 func (n *Node) replicate(repJob *ReplicationJob, c *config.Config, failedRepQueue *ReplicationQueue, wg *sync.WaitGroup) {
 	defer wg.Done()
@@ -90,18 +94,107 @@ func (n *Node) replicate(repJob *ReplicationJob, c *config.Config, failedRepQueu
     b. Check for sloppy quorum condition, ACK if success
     c. Populate next batch requests by traversing ring and updating last batch request
 */
-func (n *Node) Put(msg Message, value string, c *config.Config) {
+/*
+Locks the writes to the storage key coordinated by this node and returns the unlock. Writes to other keys, and
+writes to the key coordinated by other nodes, e.g. a fallback coordinator while this one is down, are not excluded.
+*/
+func (n *Node) lockKey(key string) func() {
+	n.mutex.Lock()
+	lock, exists := n.keyLocks[key]
+	if !exists {
+		lock = &keyLock{}
+		n.keyLocks[key] = lock
+	}
+	lock.holders++
+	n.mutex.Unlock()
+
+	lock.mutex.Lock()
+	return func() {
+		lock.mutex.Unlock()
+		n.mutex.Lock()
+		lock.holders--
+		if lock.holders == 0 {
+			delete(n.keyLocks, key)
+		}
+		n.mutex.Unlock()
+	}
+}
+
+func (n *Node) Put(msg Message, value string, t *Table, c *config.Config) {
 	replicationCount := GetReplicationCount(c)
+	W := getWCount(c)
 	if replicationCount <= 0 {
+		n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_NACK_WRITE, Key: msg.Key, Reason: "replication count is 0", Quorum: W, SrcID: n.id})
+		return
+	}
+
+	if err := msg.Attrs.Validate(); err != nil {
+		n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.INVALID_REQUEST, Key: msg.Key, Reason: err.Error(), SrcID: n.id})
+		return
+	}
+
+	hashKey := ComputeMD5(RoutingKey(msg.Table, msg.Key)) // places the partition on the ring
+	key := StorageKey(msg.Table, msg.Key, msg.SortKey)
+
+	// keys locked by a transaction on the coordinator are not written until its outcome is known
+	n.mutex.Lock()
+	lock, locked := n.intents[key]
+	n.mutex.Unlock()
+	if locked {
+		n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.TRANSACTION_CONFLICT, Key: msg.Key, Reason: fmt.Sprintf("key is locked by transaction %s", lock.txId), SrcID: n.id})
 		return
 	}
 
-	W := getWCount(c)
 	ackSent := false
 
-	hashKey := ComputeMD5(msg.Key)
+	// writes to a key coordinated by this node stay serialized from their read until W replicas hold the write,
+	// so a read-modify-write is not interleaved with another write through this node, see lockKey
+	unlockKey := n.lockKey(key)
+	keyLocked := true
+	defer func() {
+		if keyLocked {
+			unlockKey()
+		}
+	}()
+
+	// read-modify-writes read the value they check or update,
+	// writes to tables with indexes or a stream read the value they replace to update its index entries and record it
+	var current *Object // value reconciled from R replicas
+	if msg.Condition != constants.COND_NONE || msg.Command == constants.CLIENT_REQ_UPDATE || t.readsBeforeWrite() {
+		var replicas int
+		var ok bool
+		current, replicas, ok = n.readQuorum(key, c)
+		if !ok {
+			n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_NACK_WRITE, Key: msg.Key, Reason: "read quorum not reached", Replicas: replicas, Quorum: getRCount(c), SrcID: n.id})
+			return
+		}
+		if t.expired(current, time.Now()) {
+			current.isDeleted = true // expired items are absent to conditions and updates
+		}
+		if !n.checkCondition(msg, current, c) {
+			return
+		}
+	}
+
+	obj := &Object{table: msg.Table, key: msg.Key, sortKey: msg.SortKey, data: value, attrs: msg.Attrs.Copy(), isDeleted: msg.Command == constants.CLIENT_REQ_DELETE}
+	if msg.Command == constants.CLIENT_REQ_UPDATE {
+		if err := obj.applyUpdate(current, msg.Update, n.id); err != nil {
+			n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.INVALID_REQUEST, Key: msg.Key, Reason: err.Error(), SrcID: n.id})
+			return
+		}
+	}
+
+	// replication messages carry a job id of this node, client job ids are not unique across clients
+	n.mutex.Lock()
+	if current != nil {
+		n.merge_vclk(current.context.v_clk) // the write supersedes the version read
+	}
 	n.increment_vclk()
 	copy_vclk := n.copy_vclk()
+	repJobId := n.newJobId()
+	n.mutex.Unlock()
+	obj.context = &Context{v_clk: copy_vclk}
+	obj.timestamp = time.Now().UnixNano()
 
 	initToken := n.tokenStruct.Search(hashKey, c).Token
 
@@ -110,12 +203,13 @@ func (n *Node) Put(msg Message, value string, c *config.Config) {
 	}
 
 	// Retrieve preference list
-	pref_list, ok := n.prefList[initToken]
+	pref_list, ok := n.preferenceList(initToken, replicationCount)
 	if !ok {
 		pref_list = nil
 		if c.DEBUG_LEVEL >= constants.VERY_VERBOSE {
 			fmt.Println("Token is not found on the preference list")
 		}
+		n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_NACK_WRITE, Key: msg.Key, Reason: "token is not in the preference list", Quorum: W, SrcID: n.id})
 		return
 	}
 
@@ -123,8 +217,9 @@ func (n *Node) Put(msg Message, value string, c *config.Config) {
 	var curTreeNode *TreeNode               // enforce traversal order despite concurrent replicate requests
 	var repJobs []*ReplicationJob           // replication jobs per batch iteration
 	for i := 0; i < replicationCount; i++ { // populate first batch request
-		repObj := Object{data: value, context: &Context{v_clk: copy_vclk}, isReplica: true}
-		repMsg := Message{JobId: msg.JobId, Command: constants.SET_DATA, Key: hashKey, ObjData: &repObj, SrcID: n.GetID(), HandoffToken: pref_list[i].Token}
+		repObj := obj.Copy()
+		repObj.isReplica = true
+		repMsg := Message{JobId: repJobId, Command: constants.SET_DATA, Key: key, ObjData: repObj, SrcID: n.GetID(), HandoffToken: pref_list[i].Token}
 		repJob := ReplicationJob{msg: repMsg, dst: pref_list[i]}
 		repJobs = append(repJobs, &repJob)
 	}
@@ -160,7 +255,23 @@ func (n *Node) Put(msg Message, value string, c *config.Config) {
 		// sloppy quorum: after W replications, sent ACK to client
 		if replicationCount-len(failedRepQueue.Data) >= W && !ackSent {
 			ackSent = true
-			msg.Client_Ch <- Message{JobId: msg.JobId, Command: constants.CLIENT_ACK_WRITE, Key: msg.Key, Data: msg.Data, SrcID: n.id}
+			if t.Stream {
+				n.recordChange(t, current, obj, c)
+			}
+			// released before replying, a client that is gone does not keep later writes to the key waiting
+			unlockKey()
+			keyLocked = false
+			n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_ACK_WRITE, Key: msg.Key, Data: msg.Data, Attrs: obj.item(), Version: copyVersion(copy_vclk), SrcID: n.id})
+			if len(t.Indexes) > 0 {
+				go n.updateIndexes(t, current, obj, c)
+			}
+		}
+
+		// nodes left to hand off to cannot make up the write quorum, NACK without walking the rest of the ring
+		replicated := replicationCount - len(failedRepQueue.Data)
+		if !ackSent && replicated+len(n.channels)-len(visitedNodes) < W {
+			n.failWrite(msg, replicated, replicationCount, W, c)
+			return
 		}
 
 		// populate next batch request
@@ -169,12 +280,15 @@ func (n *Node) Put(msg Message, value string, c *config.Config) {
 		for cnt < len(failedRepQueue.Data) {
 			nxt := n.tokenStruct.getNext(curTreeNode)
 			if nxt.Token.GetID() == initToken.GetID() {
-				fmt.Printf("Put: ERROR! Only replicated %d/%d times!\n", replicationCount-len(failedRepQueue.Data), c.N)
+				if !ackSent {
+					n.failWrite(msg, replicated, replicationCount, W, c)
+				}
 				return
 			}
 			if _, visited := visitedNodes[nxt.Token.phy_id]; !visited {
 				failedRepQueue.Data[cnt].dst = nxt
 				failedRepQueue.Data[cnt].msg.Command = constants.BACK_DATA // subsequent replications are handoffs
+				failedRepQueue.Data[cnt].msg.Attempt++
 				repJobs = append(repJobs, failedRepQueue.Data[cnt])
 				cnt++
 			}
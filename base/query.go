@@ -0,0 +1,227 @@
+package base
+
+import (
+	"config"
+	"constants"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/* SortKeyCondition selects the items of a partition by sort key */
+type SortKeyCondition struct {
+	Op    string // constants.QUERY_*
+	Value string
+	Upper string // for QUERY_BETWEEN, inclusive upper bound
+}
+
+/*
+Query is the request of CLIENT_REQ_QUERY for the items of the partition named by Message.Key.
+Queries of an index select its entries by index key, Condition then applies to the index sort value.
+*/
+type Query struct {
+	Condition *SortKeyCondition // nil selects every item of the partition
+	Reverse   bool              // descending sort key order
+	Limit     int               // maximum number of items returned, 0 for no limit
+	StartKey  string            // cursor, the page starts after this sort key, see IndexStartKey for indexes
+	Index     string            // index of the table queried, "" for the table itself
+}
+
+/* QueryItem is an item returned by a query or a scan, in the reply to CLIENT_REQ_QUERY or CLIENT_REQ_SCAN */
+type QueryItem struct {
+	Key     string // partition key
+	SortKey string
+	Data    string
+	Attrs   Item
+	Version []int
+}
+
+func copyItems(items []QueryItem) []QueryItem {
+	if items == nil {
+		return nil
+	}
+	ret := make([]QueryItem, len(items))
+	for i, item := range items {
+		ret[i] = QueryItem{Key: item.Key, SortKey: item.SortKey, Data: item.Data, Attrs: item.Attrs.Copy(), Version: copyVersion(item.Version)}
+	}
+	return ret
+}
+
+func (q *Query) Copy() *Query {
+	if q == nil {
+		return nil
+	}
+	ret := *q
+	if q.Condition != nil {
+		cond := *q.Condition
+		ret.Condition = &cond
+	}
+	return &ret
+}
+
+func (q *Query) Validate() error {
+	if q.Limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %d", q.Limit)
+	}
+	if q.Condition == nil {
+		return nil
+	}
+	switch q.Condition.Op {
+	case constants.QUERY_EQ, constants.QUERY_LT, constants.QUERY_LE, constants.QUERY_GT, constants.QUERY_GE, constants.QUERY_BEGINS_WITH:
+	case constants.QUERY_BETWEEN:
+		if q.Condition.Value > q.Condition.Upper {
+			return fmt.Errorf("BETWEEN bounds %q and %q are out of order", q.Condition.Value, q.Condition.Upper)
+		}
+	default:
+		return fmt.Errorf("unknown sort key condition %q", q.Condition.Op)
+	}
+	return nil
+}
+
+// matches reports whether sortKey satisfies the condition and comes after the cursor
+func (q *Query) matches(sortKey string) bool {
+	if q == nil {
+		return true
+	}
+	if q.StartKey != "" && (!q.Reverse && sortKey <= q.StartKey || q.Reverse && sortKey >= q.StartKey) {
+		return false
+	}
+	if q.Condition == nil {
+		return true
+	}
+	if q.Index != "" {
+		sortKey = indexSortValue(sortKey)
+	}
+
+	value := q.Condition.Value
+	switch q.Condition.Op {
+	case constants.QUERY_EQ:
+		return sortKey == value
+	case constants.QUERY_LT:
+		return sortKey < value
+	case constants.QUERY_LE:
+		return sortKey <= value
+	case constants.QUERY_GT:
+		return sortKey > value
+	case constants.QUERY_GE:
+		return sortKey >= value
+	case constants.QUERY_BETWEEN:
+		return sortKey >= value && sortKey <= q.Condition.Upper
+	case constants.QUERY_BEGINS_WITH:
+		return strings.HasPrefix(sortKey, value)
+	}
+	return false
+}
+
+/*
+Returns the items this node stores under the partition hash that match q, tombstones included
+so replicas can reconcile deletes. Items are copies in ascending sort key order. Caller must hold n.mutex.
+*/
+func (n *Node) queryPartition(partition string, q *Query) []*Object {
+	var objs []*Object
+	for _, sortKey := range n.partitions[partition] {
+		if q.matches(sortKey) {
+			objs = append(objs, n.data[partition+"#"+sortKey].Copy())
+		}
+	}
+	return objs
+}
+
+/*
+Coordinates a query: reads the matching items of the partition from R replicas, reconciles them
+per sort key and replies with a page of live items in the requested order. Replicas return every
+match past the cursor, the limit is applied by the coordinator once deletes are reconciled.
+If the page is cut short by the limit, LastKey holds the cursor of the next page.
+Queries of an index read the partition of its keyspace, and reply with the primary keys of the items.
+*/
+func (n *Node) Query(msg Message, t *Table, c *config.Config) {
+	if msg.Query == nil {
+		msg.Query = &Query{}
+	}
+	if err := msg.Query.Validate(); err != nil {
+		n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.INVALID_REQUEST, Key: msg.Key, Reason: err.Error(), SrcID: n.id})
+		return
+	}
+
+	keyspace := msg.Table
+	if msg.Query.Index != "" {
+		if t.index(msg.Query.Index) == nil {
+			n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.INVALID_REQUEST, Key: msg.Key, Reason: fmt.Sprintf("table %q has no index %q", msg.Table, msg.Query.Index), SrcID: n.id})
+			return
+		}
+		keyspace = indexTable(msg.Table, msg.Query.Index)
+	}
+
+	R := getRCount(c)
+	partition := ComputeMD5(RoutingKey(keyspace, msg.Key))
+
+	n.mutex.Lock()
+	n.increment_vclk()
+	read := &quorumRead{items: make(map[string]*Object), replicas: 1, quorum: R, done: make(chan struct{})}
+	for _, obj := range n.queryPartition(partition, msg.Query) {
+		read.items[partition+"#"+obj.sortKey] = obj
+	}
+	jobId := n.newJobId()
+	if R > 1 {
+		n.reads[jobId] = read
+	}
+	n.mutex.Unlock()
+
+	if R > 1 {
+		req := Message{JobId: jobId, Command: constants.QUERY_DATA, Key: partition, Query: msg.Query.Copy(), SrcID: n.id}
+		if replicas, ok := n.awaitReplicas(req, read, c); !ok {
+			if c.DEBUG_LEVEL >= constants.INFO {
+				fmt.Printf("Query: Quorum not fulfilled for job %d, %d/%d replicas answered\n", msg.JobId, replicas, R)
+			}
+			n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_NACK_READ, Key: msg.Key, Reason: "read quorum not reached", Replicas: replicas, Quorum: R, SrcID: n.id})
+			return
+		}
+	}
+
+	sortKeys := make([]string, 0, len(read.items))
+	now := time.Now()
+	n.mutex.Lock()
+	for key, obj := range read.items {
+		if local, exists := n.data[key]; exists {
+			n.reconcile(local, obj)
+		}
+		if !obj.isDeleted && !t.expired(obj, now) {
+			sortKeys = append(sortKeys, obj.sortKey)
+		}
+	}
+	n.mutex.Unlock()
+
+	if msg.Query.Reverse {
+		sort.Sort(sort.Reverse(sort.StringSlice(sortKeys)))
+	} else {
+		sort.Strings(sortKeys)
+	}
+
+	reply := Message{JobId: msg.JobId, Command: constants.CLIENT_ACK_READ, Key: msg.Key, Items: []QueryItem{}, SrcID: n.id}
+	if msg.Query.Limit > 0 && len(sortKeys) > msg.Query.Limit {
+		sortKeys = sortKeys[:msg.Query.Limit]
+		reply.LastKey = sortKeys[len(sortKeys)-1]
+	}
+	for _, sortKey := range sortKeys {
+		obj := read.items[partition+"#"+sortKey]
+		key := Key{Partition: obj.key, Sort: sortKey}
+		if msg.Query.Index != "" {
+			key = indexedKey(sortKey)
+		}
+		reply.Items = append(reply.Items, QueryItem{Key: key.Partition, SortKey: key.Sort, Data: obj.data, Attrs: obj.item(), Version: obj.context.v_clk})
+	}
+	n.replyClient(msg.Client_Ch, reply)
+}
+
+// reconciles the items of a QUERY_DATA_ACK or SCAN_DATA_ACK into a read in progress. Caller must hold n.mutex.
+func (n *Node) reconcileItems(read *quorumRead, objs []*Object) {
+	for _, obj := range objs {
+		key := StorageKey(obj.table, obj.key, obj.sortKey)
+		if item, exists := read.items[key]; exists {
+			n.reconcile(item, obj)
+		} else {
+			read.items[key] = obj.Copy()
+		}
+	}
+}
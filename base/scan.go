@@ -0,0 +1,198 @@
+package base
+
+import (
+	"config"
+	"constants"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the hash space scanned by a Scan, every storage key starts with the MD5 of its partition key
+var maxHash = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+/* Scan is the request of CLIENT_REQ_SCAN for one segment of the table */
+type Scan struct {
+	Segment       int  // segment scanned, in [0, TotalSegments)
+	TotalSegments int  // number of disjoint hash ranges the table is split into, 0 or 1 for a single segment
+	Limit         int  // maximum number of items returned, 0 for no limit
+	StartKey      *Key // cursor, the page starts after this item
+}
+
+/* Storage keys of table in the hash range [lower, upper] after the storage key after, requested by SCAN_DATA */
+type keyRange struct {
+	table string
+	lower string
+	upper string
+	after string
+}
+
+func (s *Scan) Copy() *Scan {
+	if s == nil {
+		return nil
+	}
+	ret := *s
+	if s.StartKey != nil {
+		startKey := *s.StartKey
+		ret.StartKey = &startKey
+	}
+	return &ret
+}
+
+func (s *Scan) Validate() error {
+	if s.TotalSegments < 0 || s.Segment < 0 || s.Segment >= s.TotalSegments && !(s.Segment == 0 && s.TotalSegments == 0) {
+		return fmt.Errorf("segment %d is not in [0, %d)", s.Segment, s.TotalSegments)
+	}
+	if s.Limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %d", s.Limit)
+	}
+	return nil
+}
+
+// Returns the hash range of the segment as lowercase hex, comparable with storage keys
+func (s *Scan) bounds() (string, string) {
+	if s.TotalSegments <= 1 {
+		return fmt.Sprintf("%032x", 0), fmt.Sprintf("%032x", maxHash)
+	}
+	total := big.NewInt(int64(s.TotalSegments))
+	lower := new(big.Int).Div(new(big.Int).Mul(maxHash, big.NewInt(int64(s.Segment))), total)
+	upper := new(big.Int).Div(new(big.Int).Mul(maxHash, big.NewInt(int64(s.Segment+1))), total)
+	if s.Segment < s.TotalSegments-1 {
+		upper.Sub(upper, big.NewInt(1))
+	}
+	return fmt.Sprintf("%032x", lower), fmt.Sprintf("%032x", upper)
+}
+
+/*
+Returns the items of r.table this node stores in the hash range r, tombstones included so replicas can
+reconcile deletes. Items are copies in storage key order. Caller must hold n.mutex.
+*/
+func (n *Node) scanRange(r *keyRange) []*Object {
+	var keys []string
+	for key, obj := range n.data {
+		partition := partitionOf(key)
+		if obj.table == r.table && partition >= r.lower && partition <= r.upper && key > r.after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	objs := make([]*Object, len(keys))
+	for i, key := range keys {
+		objs[i] = n.data[key].Copy()
+	}
+	return objs
+}
+
+/*
+Coordinates a page of a scan of table t. Walks the token ranges of the ring overlapping the segment in hash order,
+reads each from R of the N nodes of its preference list and reconciles the replies per key.
+Live items are returned in storage key order until Limit is reached, LastKey is then the storage key
+of the last item returned and the next page starts after Items' last key.
+Any node can coordinate a scan, it need not be a replica of the ranges it reads.
+*/
+func (n *Node) Scan(msg Message, t *Table, c *config.Config) {
+	if msg.Scan == nil {
+		msg.Scan = &Scan{}
+	}
+	if err := msg.Scan.Validate(); err != nil {
+		n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.INVALID_REQUEST, Key: msg.Key, Reason: err.Error(), SrcID: n.id})
+		return
+	}
+
+	lower, upper := msg.Scan.bounds()
+	after := ""
+	if msg.Scan.StartKey != nil {
+		after = StorageKey(msg.Table, msg.Scan.StartKey.Partition, msg.Scan.StartKey.Sort)
+	}
+
+	reply := Message{JobId: msg.JobId, Command: constants.CLIENT_ACK_READ, Key: msg.Key, Items: []QueryItem{}, SrcID: n.id}
+	first := n.tokenStruct.leftMostNode(n.tokenStruct.Root)
+	for treeNode := first; ; {
+		token := treeNode.Token
+		r := &keyRange{table: msg.Table, lower: maxString(lower, strings.ToLower(token.range_start)), upper: minString(upper, strings.ToLower(token.range_end)), after: after}
+
+		if r.lower <= r.upper && partitionOf(after) <= r.upper {
+			objs, replicas, ok := n.readRange(token, r, t, c)
+			if !ok {
+				R := getRCount(c)
+				if c.DEBUG_LEVEL >= constants.INFO {
+					fmt.Printf("Scan: Quorum not fulfilled for job %d on token %d, %d/%d replicas answered\n", msg.JobId, token.id, replicas, R)
+				}
+				n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_NACK_READ, Key: msg.Key, Reason: fmt.Sprintf("read quorum not reached for token %d", token.id), Replicas: replicas, Quorum: R, SrcID: n.id})
+				return
+			}
+
+			for _, obj := range objs {
+				if msg.Scan.Limit > 0 && len(reply.Items) == msg.Scan.Limit {
+					last := reply.Items[len(reply.Items)-1]
+					reply.LastKey = StorageKey(msg.Table, last.Key, last.SortKey)
+					n.replyClient(msg.Client_Ch, reply)
+					return
+				}
+				reply.Items = append(reply.Items, QueryItem{Key: obj.key, SortKey: obj.sortKey, Data: obj.data, Attrs: obj.item(), Version: obj.context.v_clk})
+			}
+		}
+
+		treeNode = n.tokenStruct.getNext(treeNode)
+		if treeNode == first {
+			break
+		}
+	}
+	n.replyClient(msg.Client_Ch, reply)
+}
+
+/*
+Reads the hash range r of token from the first N nodes of its preference list, the coordinator
+included if it is one of them. Returns the live items of table t reconciled from the first R replies in storage
+key order, the number of replicas that answered, and False if fewer than R answered in time.
+*/
+func (n *Node) readRange(token *Token, r *keyRange, t *Table, c *config.Config) ([]*Object, int, bool) {
+	read := &quorumRead{items: make(map[string]*Object), quorum: getRCount(c), done: make(chan struct{})}
+
+	n.mutex.Lock()
+	n.increment_vclk()
+	jobId := n.newJobId()
+	n.reads[jobId] = read
+	n.mutex.Unlock()
+
+	pref, _ := n.preferenceList(token, c.N)
+	for i := 0; i < len(pref) && i < c.N; i++ {
+		n.channels[pref[i].Token.phy_id] <- Message{JobId: jobId, Command: constants.SCAN_DATA, SrcID: n.id, scanRange: r}
+	}
+	replicas, ok := n.awaitRead(jobId, read, c)
+	if !ok {
+		return nil, replicas, false
+	}
+
+	keys := make([]string, 0, len(read.items))
+	now := time.Now()
+	for key, obj := range read.items {
+		if !obj.isDeleted && !t.expired(obj, now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	objs := make([]*Object, len(keys))
+	for i, key := range keys {
+		objs[i] = read.items[key]
+	}
+	return objs, replicas, true
+}
+
+func maxString(a, b string) string {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minString(a, b string) string {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,297 @@
+package base
+
+import (
+	"bufio"
+	"config"
+	"constants"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+/*
+A backup is a directory holding a manifest of the cluster and its tables, and a file of JSON lines per node holding
+the items and hinted handoff backups of the node. Streams are not backed up, restored tables start an empty one.
+*/
+const manifestFile = "manifest.json"
+
+/* BackupManifest describes a backup, the layout of the cluster it was taken from and its tables */
+type BackupManifest struct {
+	Created   time.Time
+	NumNodes  int
+	NumTokens int
+	Tables    []Table
+	Objects   int // items and backups saved across the nodes, replicas included
+}
+
+/* An object as saved in a backup */
+type snapshotObject struct {
+	HandoffFor *int   `json:",omitempty"` // node a hinted handoff backup is held for, nil for the data of the node
+	Table      string `json:",omitempty"`
+	Key        string
+	SortKey    string                     `json:",omitempty"`
+	Data       string                     `json:",omitempty"`
+	Attrs      Item                       `json:",omitempty"`
+	Counters   map[string]snapshotCounter `json:",omitempty"`
+	Deleted    bool                       `json:",omitempty"`
+	Timestamp  int64
+	Clock      []int
+}
+
+type snapshotCounter struct {
+	Inc map[int]int64 `json:",omitempty"`
+	Dec map[int]int64 `json:",omitempty"`
+}
+
+func nodeFile(id int) string {
+	return fmt.Sprintf("node-%03d.jsonl", id)
+}
+
+func newSnapshotObject(obj *Object, handoffFor *int) snapshotObject {
+	saved := snapshotObject{HandoffFor: handoffFor, Table: obj.table, Key: obj.key, SortKey: obj.sortKey, Data: obj.data, Attrs: obj.attrs,
+		Deleted: obj.isDeleted, Timestamp: obj.timestamp}
+	if obj.context != nil {
+		saved.Clock = obj.context.v_clk
+	}
+	if obj.counters != nil {
+		saved.Counters = make(map[string]snapshotCounter, len(obj.counters))
+		for name, counter := range obj.counters {
+			saved.Counters[name] = snapshotCounter{Inc: counter.inc, Dec: counter.dec}
+		}
+	}
+	return saved
+}
+
+func (saved *snapshotObject) object() *Object {
+	obj := &Object{context: &Context{v_clk: saved.Clock}, table: saved.Table, key: saved.Key, sortKey: saved.SortKey, data: saved.Data, attrs: saved.Attrs,
+		isDeleted: saved.Deleted, timestamp: saved.Timestamp}
+	if saved.Counters != nil {
+		obj.counters = make(map[string]*pnCounter, len(saved.Counters))
+		for name, counter := range saved.Counters {
+			obj.counters[name] = &pnCounter{inc: counter.Inc, dec: counter.Dec}
+			if obj.counters[name].inc == nil {
+				obj.counters[name].inc = make(map[int]int64)
+			}
+			if obj.counters[name].dec == nil {
+				obj.counters[name].dec = make(map[int]int64)
+			}
+		}
+	}
+	return obj
+}
+
+/*
+Backup saves the tables and the data of the cluster of phy_nodes to the directory dir, which must not exist.
+Every node is locked while its objects are copied, all of them at once, so the backup holds the writes each node had
+applied at one instant. Writes replicated to some nodes only are reconciled on restore like on reads.
+*/
+func Backup(phy_nodes []*Node, dir string) (BackupManifest, error) {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return BackupManifest{}, err
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return BackupManifest{}, err
+	}
+
+	manifest := BackupManifest{Created: time.Now(), NumNodes: len(phy_nodes)}
+	for _, node := range phy_nodes {
+		manifest.NumTokens += len(node.GetTokens())
+	}
+	for _, name := range ListTables(phy_nodes) {
+		if t, err := describeTable(phy_nodes, name); err == nil { // restored tables keep following the cluster
+			manifest.Tables = append(manifest.Tables, t)
+		}
+	}
+
+	saved := make([][]snapshotObject, len(phy_nodes))
+	for _, node := range phy_nodes {
+		node.mutex.Lock()
+	}
+	for i, node := range phy_nodes {
+		for _, obj := range node.data {
+			saved[i] = append(saved[i], newSnapshotObject(obj.Copy(), nil))
+		}
+		for nodeId, backup := range node.backup {
+			for _, obj := range backup {
+				handoffFor := nodeId
+				saved[i] = append(saved[i], newSnapshotObject(obj.Copy(), &handoffFor))
+			}
+		}
+	}
+	for _, node := range phy_nodes {
+		node.mutex.Unlock()
+	}
+
+	for i, node := range phy_nodes {
+		if err := writeSnapshotObjects(filepath.Join(dir, nodeFile(node.GetID())), saved[i]); err != nil {
+			return BackupManifest{}, err
+		}
+		manifest.Objects += len(saved[i])
+	}
+	// the manifest is written last, a directory without one holds an incomplete backup
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return BackupManifest{}, err
+	}
+	return manifest, os.WriteFile(filepath.Join(dir, manifestFile), data, 0o644)
+}
+
+func writeSnapshotObjects(path string, objs []snapshotObject) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range objs {
+		if err := enc.Encode(&objs[i]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readSnapshotObjects(path string, fn func(saved *snapshotObject)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var saved snapshotObject
+		if err := dec.Decode(&saved); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fn(&saved)
+	}
+	return nil
+}
+
+/* ReadBackupManifest returns the manifest of the backup in dir */
+func ReadBackupManifest(dir string) (BackupManifest, error) {
+	var manifest BackupManifest
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return manifest, fmt.Errorf("%s is not a complete backup: %w", dir, err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	return manifest, nil
+}
+
+/*
+Restore builds a cluster of c.NUM_NODES nodes and c.NUM_TOKENS tokens from the backup in dir, which may have been
+taken from another layout. The copies of every item, handoff backups included, are reconciled with the conflict
+resolution of its table as reads do, then the latest version is stored on the N owners of its key on the new ring.
+Tables keep their settings, N is reduced to the nodes of the new cluster and R and W to N, settings following
+the cluster follow the new one.
+The nodes are returned unstarted, with the manifest of the backup.
+*/
+func Restore(dir string, close_ch chan struct{}, c *config.Config) ([]*Node, BackupManifest, error) {
+	manifest, err := ReadBackupManifest(dir)
+	if err != nil {
+		return nil, manifest, err
+	}
+
+	phy_nodes := CreateNodes(close_ch, c)
+	InitializeTokens(phy_nodes, c)
+	cat := phy_nodes[0].tables
+	for _, t := range manifest.Tables {
+		t := t
+		if t.N > c.NUM_NODES {
+			t.N = c.NUM_NODES
+		}
+		N := t.resolve(c).N
+		if t.R != 0 {
+			t.R = clampQuorum(t.R, N)
+		}
+		if t.W != 0 {
+			t.W = clampQuorum(t.W, N)
+		}
+		cat.tables[t.Name] = &t
+		if t.Stream {
+			cat.streams[t.Name] = newStream(&t, c)
+		}
+	}
+
+	// the latest version of every key across the nodes of the backup
+	latest := make(map[string]*Object)
+	for id := 0; id < manifest.NumNodes; id++ {
+		err := readSnapshotObjects(filepath.Join(dir, nodeFile(id)), func(saved *snapshotObject) {
+			obj := saved.object()
+			key := StorageKey(obj.table, obj.key, obj.sortKey)
+			kept, exists := latest[key]
+			if !exists {
+				latest[key] = obj
+				return
+			}
+			if cat.conflictResolution(obj.table) == constants.CONFLICT_LAST_WRITER_WINS {
+				if compareTimestamps(kept, obj) == 1 {
+					latest[key] = obj
+				}
+				return
+			}
+			switch compareVC(kept.context.v_clk, obj.context.v_clk) {
+			case 1:
+				latest[key] = obj
+			case 0:
+				kept.mergeCounters(obj)
+			}
+		})
+		if err != nil {
+			return nil, manifest, err
+		}
+	}
+
+	keys := make([]string, 0, len(latest))
+	for key := range latest {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	clock := make([]int, c.NUM_NODES)
+	for _, key := range keys {
+		obj := latest[key]
+		table, _, _ := splitIndexTable(obj.table)
+		t, exists := cat.get(table, c)
+		if !exists {
+			continue // left behind by a deleted table
+		}
+		// clocks are sized to the new cluster, versions of nodes that no longer exist are dropped with their entries
+		v_clk := make([]int, c.NUM_NODES)
+		copy(v_clk, obj.context.v_clk)
+		obj.context.v_clk = v_clk
+		for i := range clock {
+			if v_clk[i] > clock[i] {
+				clock[i] = v_clk[i]
+			}
+		}
+
+		replicationCount := GetReplicationCount(t.config(c))
+		token := phy_nodes[0].tokenStruct.Search(ComputeMD5(RoutingKey(obj.table, obj.key)), c).Token
+		pref, ok := phy_nodes[0].preferenceList(token, replicationCount)
+		if !ok {
+			return nil, manifest, fmt.Errorf("token %d is not in the preference list", token.GetID())
+		}
+		for i := 0; i < replicationCount && i < len(pref); i++ {
+			replica := obj.Copy()
+			replica.isReplica = i > 0
+			phy_nodes[pref[i].Token.phy_id].store(key, replica)
+		}
+	}
+
+	// later writes of every node supersede the restored versions
+	for _, node := range phy_nodes {
+		node.merge_vclk(clock)
+	}
+	return phy_nodes, manifest, nil
+}
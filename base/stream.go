@@ -0,0 +1,248 @@
+package base
+
+import (
+	"config"
+	"constants"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* StreamRecord is a change of an item of a table with a stream, images are nil for plain values */
+type StreamRecord struct {
+	SequenceNumber int64  // increasing across the stream, in write order within a shard
+	EventName      string // constants.STREAM_*
+	Key            Key
+	OldImage       Item // item replaced by the write, nil for inserts
+	NewImage       Item // item written, nil for removes
+	Created        time.Time
+}
+
+/* StreamShard describes a shard of a stream, the records of the partitions of one token */
+type StreamShard struct {
+	ShardId                string
+	StartingSequenceNumber int64 // oldest record retained, 0 if every record was trimmed
+	EndingSequenceNumber   int64 // latest record
+}
+
+/* StreamPage is a page of records of a shard, NextIterator reads the records after it */
+type StreamPage struct {
+	Records      []StreamRecord
+	NextIterator string
+}
+
+/*
+The change stream of a table. Records are appended by the coordinators of the writes once W replicas hold
+them, to the shard of the token owning their partition, and trimmed once older than the retention.
+*/
+type stream struct {
+	mutex     sync.Mutex
+	created   int64 // creation time of the table, iterators of a deleted table are rejected by a new one
+	retention time.Duration
+	lastSeq   int64
+	shards    map[string]*shard
+}
+
+type shard struct {
+	records []StreamRecord // in sequence number order
+	trimmed int64          // sequence number of the latest record trimmed
+	lastSeq int64
+}
+
+func newStream(t *Table, c *config.Config) *stream {
+	return &stream{created: t.CreatedAt.UnixNano(), retention: time.Duration(c.STREAM_RETENTION_MS) * time.Millisecond, shards: make(map[string]*shard)}
+}
+
+// Drops the records older than the retention. Caller must hold s.mutex.
+func (s *stream) trim(now time.Time) {
+	for _, sh := range s.shards {
+		i := 0
+		for i < len(sh.records) && now.Sub(sh.records[i].Created) > s.retention {
+			sh.trimmed = sh.records[i].SequenceNumber
+			i++
+		}
+		sh.records = sh.records[i:]
+	}
+}
+
+// Returns the shard of the records coordinated with token
+func shardOf(token *Token) string {
+	return fmt.Sprintf("shard-%05d", token.GetID())
+}
+
+/*
+Records the change of obj replacing old, nil if there was none, in the stream of t. Deletes of absent items
+are not recorded. Coordinators record writes before releasing their read-modify-write lock, so the records
+of a partition coordinated by one node are in write order.
+*/
+func (n *Node) recordChange(t *Table, old *Object, obj *Object, c *config.Config) {
+	record := StreamRecord{Key: Key{Partition: obj.key, Sort: obj.sortKey}, NewImage: obj.item()}
+	if old != nil && !old.isDeleted {
+		record.OldImage = old.item()
+	}
+	switch {
+	case obj.isDeleted && (old == nil || old.isDeleted):
+		return
+	case obj.isDeleted:
+		record.EventName, record.NewImage = constants.STREAM_REMOVE, nil
+	case old == nil || old.isDeleted:
+		record.EventName = constants.STREAM_INSERT
+	default:
+		record.EventName = constants.STREAM_MODIFY
+	}
+
+	n.tables.mutex.Lock()
+	s, exists := n.tables.streams[t.Name]
+	n.tables.mutex.Unlock()
+	if !exists {
+		return // the table was deleted
+	}
+
+	token := n.tokenStruct.Search(ComputeMD5(RoutingKey(t.Name, obj.key)), c).Token
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	record.Created = time.Now()
+	s.trim(record.Created)
+	sh, exists := s.shards[shardOf(token)]
+	if !exists {
+		sh = &shard{trimmed: s.lastSeq}
+		s.shards[shardOf(token)] = sh
+	}
+	s.lastSeq++
+	record.SequenceNumber = s.lastSeq
+	sh.lastSeq = s.lastSeq
+	sh.records = append(sh.records, record)
+}
+
+// Returns the iterator of the records of a shard after the sequence number after, '/' is not allowed in table names
+func shardIterator(table string, created int64, shardId string, after int64) string {
+	return fmt.Sprintf("%s/%d/%s/%d", table, created, shardId, after)
+}
+
+// Returns the stream of the table name
+func (cat *Catalog) stream(name string) (*stream, error) {
+	cat.mutex.Lock()
+	defer cat.mutex.Unlock()
+	if _, exists := cat.tables[name]; !exists {
+		return nil, fmt.Errorf("%w: %s", ErrTableNotFound, name)
+	}
+	s, exists := cat.streams[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: table %q has no stream", ErrInvalidRequest, name)
+	}
+	return s, nil
+}
+
+/* DescribeStream returns the shards of the stream of table that recorded changes, in shard id order */
+func DescribeStream(phy_nodes []*Node, table string) ([]StreamShard, error) {
+	s, err := phy_nodes[0].tables.stream(table)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.trim(time.Now())
+
+	shards := make([]StreamShard, 0, len(s.shards))
+	for id, sh := range s.shards {
+		desc := StreamShard{ShardId: id, EndingSequenceNumber: sh.lastSeq}
+		if len(sh.records) > 0 {
+			desc.StartingSequenceNumber = sh.records[0].SequenceNumber
+		}
+		shards = append(shards, desc)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].ShardId < shards[j].ShardId })
+	return shards, nil
+}
+
+/*
+GetShardIterator returns an iterator of the shard shardId of the stream of table at the position iteratorType,
+one of constants.ITERATOR_*. sequenceNumber is the record of AT_SEQUENCE_NUMBER and AFTER_SEQUENCE_NUMBER.
+Iterators are opaque strings holding the last sequence number read.
+*/
+func GetShardIterator(phy_nodes []*Node, table string, shardId string, iteratorType string, sequenceNumber int64) (string, error) {
+	s, err := phy_nodes[0].tables.stream(table)
+	if err != nil {
+		return "", err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.trim(time.Now())
+	sh, exists := s.shards[shardId]
+	if !exists {
+		return "", fmt.Errorf("%w: stream of table %q has no shard %q", ErrInvalidRequest, table, shardId)
+	}
+
+	var after int64
+	switch iteratorType {
+	case constants.ITERATOR_TRIM_HORIZON:
+		after = sh.trimmed
+	case constants.ITERATOR_LATEST:
+		after = sh.lastSeq
+	case constants.ITERATOR_AT_SEQUENCE_NUMBER:
+		after = sequenceNumber - 1
+	case constants.ITERATOR_AFTER_SEQUENCE_NUMBER:
+		after = sequenceNumber
+	default:
+		return "", fmt.Errorf("%w: unknown shard iterator type %q", ErrInvalidRequest, iteratorType)
+	}
+	if after < sh.trimmed {
+		return "", fmt.Errorf("%w: records of shard %q up to %d were trimmed", ErrTrimmedData, shardId, sh.trimmed)
+	}
+	return shardIterator(table, s.created, shardId, after), nil
+}
+
+/*
+GetRecords returns up to limit records after iterator, all of them if limit is 0, and the iterator of the
+following records. Shards stay open, a page without records returns an iterator at the same position.
+Iterators whose records were trimmed fail with ErrTrimmedData.
+*/
+func GetRecords(phy_nodes []*Node, iterator string, limit int) (StreamPage, error) {
+	parts := strings.Split(iterator, "/")
+	if len(parts) != 4 {
+		return StreamPage{}, fmt.Errorf("%w: invalid shard iterator %q", ErrInvalidRequest, iterator)
+	}
+	table, shardId := parts[0], parts[2]
+	created, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return StreamPage{}, fmt.Errorf("%w: invalid shard iterator %q", ErrInvalidRequest, iterator)
+	}
+	after, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || limit < 0 {
+		return StreamPage{}, fmt.Errorf("%w: invalid shard iterator %q or limit %d", ErrInvalidRequest, iterator, limit)
+	}
+
+	s, err := phy_nodes[0].tables.stream(table)
+	if err != nil {
+		return StreamPage{}, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.created != created {
+		return StreamPage{}, fmt.Errorf("%w: %s was deleted since the iterator was created", ErrTableNotFound, table)
+	}
+	s.trim(time.Now())
+	sh, exists := s.shards[shardId]
+	if !exists {
+		return StreamPage{}, fmt.Errorf("%w: stream of table %q has no shard %q", ErrInvalidRequest, table, shardId)
+	}
+	if after < sh.trimmed {
+		return StreamPage{}, fmt.Errorf("%w: records of shard %q up to %d were trimmed", ErrTrimmedData, shardId, sh.trimmed)
+	}
+
+	page := StreamPage{Records: []StreamRecord{}}
+	start := sort.Search(len(sh.records), func(i int) bool { return sh.records[i].SequenceNumber > after })
+	for _, record := range sh.records[start:] {
+		if limit > 0 && len(page.Records) == limit {
+			break
+		}
+		record.OldImage, record.NewImage = record.OldImage.Copy(), record.NewImage.Copy()
+		page.Records = append(page.Records, record)
+		after = record.SequenceNumber
+	}
+	page.NextIterator = shardIterator(table, created, shardId, after)
+	return page, nil
+}
@@ -0,0 +1,293 @@
+package base
+
+import (
+	"config"
+	"constants"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var tableNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,255}$`)
+
+/*
+Table holds the settings of a named table. Its items are placed on the ring by the table name
+and their partition key, so tables are isolated, and replicated with the table's N, R and W.
+N, R and W left at 0 follow the current settings of the cluster, see resolve.
+The default keyspace, table "", uses the cluster config and resolves conflicts with vector clocks.
+*/
+type Table struct {
+	Name               string
+	N                  int    // replicas of every item, 0 for the cluster's N
+	R                  int    // replicas read, 0 for the cluster's R
+	W                  int    // replicas written before a write is acknowledged, 0 for the cluster's W
+	ConflictResolution string // constants.CONFLICT_*, "" for CONFLICT_VECTOR_CLOCK
+	TTLAttribute       string // items whose N attribute of this name is a past epoch second are expired, "" for none
+	Indexes            []Index
+	Stream             bool // records the changes of its items, see GetRecords
+
+	// key attribute names of items written through the HTTP API
+	KeyAttribute     string
+	SortKeyAttribute string
+
+	CreatedAt time.Time
+}
+
+/* Catalog holds the tables of a cluster, it is shared by its nodes */
+type Catalog struct {
+	mutex   sync.Mutex
+	tables  map[string]*Table
+	streams map[string]*stream // of the tables with a stream
+}
+
+func newCatalog() *Catalog {
+	return &Catalog{tables: make(map[string]*Table), streams: make(map[string]*stream)}
+}
+
+// Validates t with its N, R and W resolved on the cluster config, and fills in the default conflict resolution
+func (t *Table) init(c *config.Config) error {
+	if !tableNameRegex.MatchString(t.Name) {
+		return fmt.Errorf("%w: table name %q must be 3 to 255 letters, digits, '_', '-' or '.'", ErrInvalidRequest, t.Name)
+	}
+	resolved := t.resolve(c)
+	if resolved.N < 1 || resolved.N > c.NUM_NODES {
+		return fmt.Errorf("%w: N must be in [1, %d], got %d", ErrInvalidRequest, c.NUM_NODES, resolved.N)
+	}
+	if resolved.R < 1 || resolved.R > resolved.N || resolved.W < 1 || resolved.W > resolved.N {
+		return fmt.Errorf("%w: R and W must be in [1, N=%d], got R=%d, W=%d", ErrInvalidRequest, resolved.N, resolved.R, resolved.W)
+	}
+
+	switch t.ConflictResolution {
+	case "":
+		t.ConflictResolution = constants.CONFLICT_VECTOR_CLOCK
+	case constants.CONFLICT_VECTOR_CLOCK, constants.CONFLICT_LAST_WRITER_WINS:
+	default:
+		return fmt.Errorf("%w: unknown conflict resolution %q", ErrInvalidRequest, t.ConflictResolution)
+	}
+	return t.initIndexes()
+}
+
+// Returns the cluster quorum q as the default quorum of a table of N replicas, in [1, N]
+func clampQuorum(q int, N int) int {
+	if q > N {
+		q = N
+	}
+	if q < 1 {
+		q = 1
+	}
+	return q
+}
+
+/*
+Returns a copy of t with N, R and W left at 0 taken from the cluster config c: its N, and its R and W
+reduced to the N of the table, at least 1. Set values are kept, so resolving twice changes nothing.
+*/
+func (t Table) resolve(c *config.Config) Table {
+	if t.N == 0 {
+		t.N = GetReplicationCount(c)
+	}
+	if t.R == 0 {
+		t.R = clampQuorum(getRCount(c), t.N)
+	}
+	if t.W == 0 {
+		t.W = clampQuorum(getWCount(c), t.N)
+	}
+	return t
+}
+
+// Returns a copy of the cluster config with the replication settings of the table
+func (t *Table) config(c *config.Config) *config.Config {
+	resolved := t.resolve(c)
+	tc := *c
+	tc.N, tc.R, tc.W = resolved.N, resolved.R, resolved.W
+	return &tc
+}
+
+// Reports whether obj has expired, its TTL attribute is a number of epoch seconds before now
+func (t *Table) expired(obj *Object, now time.Time) bool {
+	if t.TTLAttribute == "" || obj == nil {
+		return false
+	}
+	value, exists := obj.item()[t.TTLAttribute]
+	if !exists || value.N == nil {
+		return false
+	}
+	expiry, err := strconv.ParseFloat(*value.N, 64)
+	return err == nil && expiry <= float64(now.Unix())
+}
+
+// Returns the settings of the table name resolved on c, the default keyspace "" always exists
+func (cat *Catalog) get(name string, c *config.Config) (*Table, bool) {
+	if name == "" {
+		return &Table{N: GetReplicationCount(c), R: getRCount(c), W: getWCount(c), ConflictResolution: constants.CONFLICT_VECTOR_CLOCK}, true
+	}
+	cat.mutex.Lock()
+	defer cat.mutex.Unlock()
+	t, exists := cat.tables[name]
+	if !exists {
+		return nil, false
+	}
+	ret := t.resolve(c)
+	return &ret, true
+}
+
+// Returns the conflict resolution of the table name, the default one if the table was deleted
+func (cat *Catalog) conflictResolution(name string) string {
+	if _, _, isIndex := splitIndexTable(name); isIndex {
+		return constants.CONFLICT_LAST_WRITER_WINS
+	}
+	cat.mutex.Lock()
+	defer cat.mutex.Unlock()
+	if t, exists := cat.tables[name]; exists {
+		return t.ConflictResolution
+	}
+	return constants.CONFLICT_VECTOR_CLOCK
+}
+
+// Reports whether writes to t read the value they replace, for its indexes and stream
+func (t *Table) readsBeforeWrite() bool {
+	return len(t.Indexes) > 0 || t.Stream
+}
+
+// Reports whether the table or index keyspace name exists
+func (cat *Catalog) exists(name string) bool {
+	if name == "" {
+		return true
+	}
+	table, index, isIndex := splitIndexTable(name)
+	cat.mutex.Lock()
+	defer cat.mutex.Unlock()
+	t, exists := cat.tables[table]
+	if isIndex {
+		return exists && t.index(index) != nil
+	}
+	return exists
+}
+
+/*
+CreateTable adds the table t to the cluster of phy_nodes, returns its settings with defaults filled in.
+N, R and W left at 0 are not stored, they follow the settings of the cluster as they change, see SetConfig.
+*/
+func CreateTable(phy_nodes []*Node, t Table, c *config.Config) (Table, error) {
+	if err := t.init(c); err != nil {
+		return Table{}, err
+	}
+	t.CreatedAt = time.Now()
+
+	cat := phy_nodes[0].tables
+	cat.mutex.Lock()
+	defer cat.mutex.Unlock()
+	if _, exists := cat.tables[t.Name]; exists {
+		return Table{}, fmt.Errorf("%w: %s", ErrTableExists, t.Name)
+	}
+	cat.tables[t.Name] = &t
+	if t.Stream {
+		cat.streams[t.Name] = newStream(&t, c)
+	}
+	return t.resolve(c), nil
+}
+
+/* DescribeTable returns the settings of the table name, with the N, R and W it follows from the cluster filled in */
+func DescribeTable(phy_nodes []*Node, name string) (Table, error) {
+	t, err := describeTable(phy_nodes, name)
+	if err != nil {
+		return Table{}, err
+	}
+	return t.resolve(phy_nodes[0].Config()), nil
+}
+
+// Returns the settings of the table name as created, N, R and W following the cluster left at 0
+func describeTable(phy_nodes []*Node, name string) (Table, error) {
+	cat := phy_nodes[0].tables
+	cat.mutex.Lock()
+	defer cat.mutex.Unlock()
+	t, exists := cat.tables[name]
+	if !exists {
+		return Table{}, fmt.Errorf("%w: %s", ErrTableNotFound, name)
+	}
+	return *t, nil
+}
+
+/* ListTables returns the names of the tables of the cluster in order */
+func ListTables(phy_nodes []*Node) []string {
+	cat := phy_nodes[0].tables
+	cat.mutex.Lock()
+	defer cat.mutex.Unlock()
+	names := make([]string, 0, len(cat.tables))
+	for name := range cat.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+DeleteTable removes the table name and its stream, then drops its items, index entries and handoff backups from every node.
+Requests on the table fail with TABLE_NOT_FOUND from then on and replicas ignore late writes to it.
+*/
+func DeleteTable(phy_nodes []*Node, name string) (Table, error) {
+	cat := phy_nodes[0].tables
+	cat.mutex.Lock()
+	t, exists := cat.tables[name]
+	delete(cat.tables, name)
+	delete(cat.streams, name)
+	cat.mutex.Unlock()
+	if !exists {
+		return Table{}, fmt.Errorf("%w: %s", ErrTableNotFound, name)
+	}
+
+	for _, node := range phy_nodes {
+		node.dropTable(name)
+	}
+	return *t, nil
+}
+
+// Drops the items, index entries and backups of the table name
+func (n *Node) dropTable(name string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for key, obj := range n.data {
+		if table, _, _ := splitIndexTable(obj.table); table == name {
+			delete(n.data, key)
+			delete(n.partitions, partitionOf(key))
+		}
+	}
+	for _, backup := range n.backup {
+		for key, obj := range backup {
+			if table, _, _ := splitIndexTable(obj.table); table == name {
+				delete(backup, key)
+			}
+		}
+	}
+}
+
+/*
+Serves a client request with the settings of its table, requests on a table that does not
+exist are answered with TABLE_NOT_FOUND.
+*/
+func (n *Node) serveClient(msg Message, c *config.Config) {
+	t, exists := n.tables.get(msg.Table, c)
+	if !exists {
+		n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.TABLE_NOT_FOUND, Key: msg.Key, Reason: fmt.Sprintf("table %q does not exist", msg.Table), SrcID: n.id})
+		return
+	}
+	tc := t.config(c)
+
+	switch msg.Command {
+	case constants.CLIENT_REQ_READ:
+		n.Get(msg, t, tc)
+	case constants.CLIENT_REQ_WRITE:
+		n.Put(msg, msg.Data, t, tc)
+	case constants.CLIENT_REQ_DELETE:
+		n.Put(msg, "", t, tc) // deletes are replicated as tombstones
+	case constants.CLIENT_REQ_UPDATE:
+		n.Put(msg, "", t, tc) // the updated value is computed from a quorum read
+	case constants.CLIENT_REQ_QUERY:
+		n.Query(msg, t, tc)
+	case constants.CLIENT_REQ_SCAN:
+		n.Scan(msg, t, tc)
+	}
+}
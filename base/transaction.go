@@ -0,0 +1,466 @@
+package base
+
+import (
+	"config"
+	"constants"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	txPreparing = iota
+	txCommitted
+)
+
+// rounds of TX_STATUS of recoverTransactions a finished transaction is kept for, see txRetention
+const txStatusRounds = 10
+
+/*
+A transaction coordinated by this node. Committed transactions are kept until every owner ACKed their
+write, then for txRetention, aborted ones are forgotten: owners asking about a transaction unknown to
+its coordinator abort it.
+*/
+type transaction struct {
+	state    int
+	writes   map[string]*Object // by storage key, nil for condition checks
+	pending  int                // owners retrying their TX_COMMIT, see retryCommit
+	finished time.Time          // when every owner ACKed the commit, zero until then
+}
+
+/* Age after which a transaction every owner ACKed is pruned */
+func txRetention(c *config.Config) time.Duration {
+	return txStatusRounds * time.Duration(c.SET_DATA_TIMEOUT_MS) * time.Millisecond
+}
+
+/* Lock on a storage key held by a prepared transaction until its outcome is known */
+type intent struct {
+	txId        string
+	coordinator int
+}
+
+/* Votes of the owners of a key on TX_PREPARE, reconciled as they arrive. done is closed once every owner voted or one voted no. */
+type txVotes struct {
+	obj      *Object // value reconciled from the yes votes
+	owners   int
+	replies  int
+	yes      int
+	conflict string // reason of a no vote
+	done     chan struct{}
+}
+
+/* An item of a transaction and the owners of its key, the first N nodes of its preference list */
+type txItem struct {
+	req    Message
+	key    string // storage key
+	table  *Table
+	owners []*Token
+	quorum int // yes votes needed, max(R, W) of the table
+	jobId  int
+	votes  *txVotes
+}
+
+/* Caller must hold n.mutex. */
+func (v *txVotes) countVote(n *Node, msg Message) {
+	v.replies++
+	if msg.Reason != "" {
+		v.conflict = msg.Reason
+		return
+	}
+	v.yes++
+	if v.obj == nil {
+		v.obj = msg.ObjData.Copy()
+	} else {
+		n.reconcile(v.obj, msg.ObjData)
+	}
+}
+
+/*
+Applies the writes of a CLIENT_REQ_TRANSACT all or nothing, each with the settings of its table:
+ 1. Send TX_PREPARE to the owners of every key, an owner locks the key unless another transaction holds it,
+    and votes with its copy of the value
+ 2. Check the condition of every item on the value reconciled from max(R, W) yes votes, computing its write
+ 3. Commit if every item passed: record the decision, send TX_COMMIT with the writes to every owner and reply
+    CLIENT_ACK_WRITE once they ACKed or timed out. The commit is resent to the owners that timed out until they
+    ACK it, owners that missed the prepare included, and owners holding a lock ask for the outcome when revived.
+    The changes are then recorded in the streams of their tables, and indexes are updated in the background.
+ 4. Otherwise send TX_ABORT to every owner, and reply TRANSACTION_CANCELED with the reason of every item
+*/
+func (n *Node) Transact(msg Message, c *config.Config) {
+	items, reply := n.transactionItems(msg, c)
+	if reply != nil {
+		n.replyClient(msg.Client_Ch, *reply)
+		return
+	}
+
+	n.mutex.Lock()
+	n.pruneTransactions(time.Now(), txRetention(c))
+	txId := fmt.Sprintf("%d.%d", n.id, n.newJobId())
+	txn := &transaction{state: txPreparing, writes: make(map[string]*Object, len(items))}
+	n.txns[txId] = txn
+	for _, item := range items {
+		item.jobId = n.newJobId()
+		item.votes = &txVotes{owners: len(item.owners), done: make(chan struct{})}
+		n.votes[item.jobId] = item.votes
+	}
+	n.mutex.Unlock()
+
+	for _, item := range items {
+		for _, owner := range item.owners {
+			n.channels[owner.phy_id] <- Message{JobId: item.jobId, Command: constants.TX_PREPARE, Key: item.key, TxId: txId, SrcID: n.GetID()}
+		}
+	}
+
+	timer := time.NewTimer(time.Duration(c.CLIENT_GET_TIMEOUT_MS) * time.Millisecond)
+	defer timer.Stop()
+	expired := false
+	for _, item := range items {
+		if expired {
+			break
+		}
+		select {
+		case <-item.votes.done:
+		case <-timer.C:
+			expired = true
+		case <-n.close_ch:
+			return
+		}
+	}
+
+	// late votes are dropped, their locks are released by the outcome sent to every owner
+	n.mutex.Lock()
+	for _, item := range items {
+		delete(n.votes, item.jobId)
+	}
+	n.mutex.Unlock()
+
+	replies := make([]Message, len(items))
+	writes := make([]*Object, len(items))
+	canceled := false
+	now := time.Now()
+	for i, item := range items {
+		replies[i], writes[i] = n.prepareWrite(item, now)
+		if replies[i].Command != constants.CLIENT_ACK_WRITE {
+			canceled = true
+		}
+	}
+
+	if canceled {
+		n.mutex.Lock()
+		delete(n.txns, txId)
+		n.mutex.Unlock()
+		for i, item := range items {
+			for _, owner := range item.owners {
+				n.channels[owner.phy_id] <- Message{JobId: item.jobId, Command: constants.TX_ABORT, Key: item.key, TxId: txId, SrcID: n.GetID()}
+			}
+			if replies[i].Command == constants.CLIENT_ACK_WRITE {
+				replies[i] = Message{JobId: msg.JobId, Key: item.req.Key, SrcID: n.id} // items that did not cancel the transaction have no reason
+			}
+		}
+		if c.DEBUG_LEVEL >= constants.INFO {
+			fmt.Printf("Transact: transaction %s canceled\n", txId)
+		}
+		n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.TRANSACTION_CANCELED, Key: msg.Key, Reason: "transaction canceled", Batch: replies, SrcID: n.id})
+		return
+	}
+
+	// the writes supersede every version read, and share one clock
+	n.mutex.Lock()
+	for _, item := range items {
+		if item.votes.obj != nil {
+			n.merge_vclk(item.votes.obj.context.v_clk)
+		}
+	}
+	n.increment_vclk()
+	copy_vclk := n.copy_vclk()
+	timestamp := now.UnixNano()
+	for i, item := range items {
+		if writes[i] != nil {
+			writes[i].context = &Context{v_clk: copyVersion(copy_vclk)}
+			writes[i].timestamp = timestamp
+			replies[i].Attrs = writes[i].item()
+			replies[i].Version = copyVersion(copy_vclk)
+		}
+		txn.writes[item.key] = writes[i]
+	}
+	txn.state = txCommitted
+	n.mutex.Unlock()
+
+	var unacked []Message // commits the owner at the same index of owners did not ACK
+	var owners []*Token
+	var ackMutex sync.Mutex
+	var wg sync.WaitGroup
+	for i, item := range items {
+		for j, owner := range item.owners {
+			commit := Message{JobId: item.jobId, Attempt: j, Command: constants.TX_COMMIT, Key: item.key, TxId: txId, ObjData: writes[i].Copy(), SrcID: n.GetID()}
+			if commit.ObjData != nil {
+				commit.ObjData.isReplica = j > 0
+			}
+			wg.Add(1)
+			go func(owner *Token, commit Message) {
+				defer wg.Done()
+				if !n.updateToken(owner, commit, c) {
+					ackMutex.Lock()
+					unacked = append(unacked, commit)
+					owners = append(owners, owner)
+					ackMutex.Unlock()
+				}
+			}(owner, commit)
+		}
+	}
+	wg.Wait()
+
+	for i, item := range items {
+		if writes[i] != nil && item.table.Stream {
+			n.recordChange(item.table, item.votes.obj, writes[i], c)
+		}
+		if writes[i] != nil && len(item.table.Indexes) > 0 {
+			go n.updateIndexes(item.table, item.votes.obj, writes[i], c)
+		}
+	}
+	n.mutex.Lock()
+	txn.pending = len(unacked)
+	if txn.pending == 0 {
+		txn.finished = time.Now() // no owner holds a lock of the transaction anymore
+	}
+	n.mutex.Unlock()
+	for i, commit := range unacked {
+		go n.retryCommit(txn, owners[i], commit, c)
+	}
+	if c.DEBUG_LEVEL >= constants.INFO {
+		fmt.Printf("Transact: transaction %s committed, %d owners to retry\n", txId, len(unacked))
+	}
+	n.replyClient(msg.Client_Ch, Message{JobId: msg.JobId, Command: constants.CLIENT_ACK_WRITE, Key: msg.Key, Batch: replies, SrcID: n.id})
+}
+
+/*
+Returns the items of a transaction with the owners of their keys, or the reply rejecting it: a transaction
+holds 1 to BATCH_MAX_KEYS distinct keys of existing tables, written by puts, deletes, updates and condition checks.
+*/
+func (n *Node) transactionItems(msg Message, c *config.Config) ([]*txItem, *Message) {
+	invalid := func(key string, reason string) *Message {
+		return &Message{JobId: msg.JobId, Command: constants.INVALID_REQUEST, Key: key, Reason: reason, SrcID: n.id}
+	}
+	if len(msg.Batch) == 0 || len(msg.Batch) > c.BATCH_MAX_KEYS {
+		return nil, invalid(msg.Key, fmt.Sprintf("a transaction holds 1 to %d items, got %d", c.BATCH_MAX_KEYS, len(msg.Batch)))
+	}
+
+	items := make([]*txItem, len(msg.Batch))
+	for i, req := range msg.Batch {
+		req.JobId = msg.JobId
+		t, exists := n.tables.get(req.Table, c)
+		if !exists {
+			return nil, &Message{JobId: msg.JobId, Command: constants.TABLE_NOT_FOUND, Key: req.Key, Reason: fmt.Sprintf("table %q does not exist", req.Table), SrcID: n.id}
+		}
+		switch req.Command {
+		case constants.CLIENT_REQ_WRITE, constants.CLIENT_REQ_DELETE, constants.CLIENT_REQ_UPDATE, constants.CLIENT_REQ_CHECK:
+		default:
+			return nil, invalid(req.Key, fmt.Sprintf("%s is not a transaction item", constants.GetConstantString(req.Command)))
+		}
+		if err := req.Attrs.Validate(); err != nil {
+			return nil, invalid(req.Key, err.Error())
+		}
+
+		key := StorageKey(req.Table, req.Key, req.SortKey)
+		for _, other := range items[:i] {
+			if other.key == key {
+				return nil, invalid(req.Key, "key is in the transaction twice")
+			}
+		}
+
+		tc := t.config(c)
+		replicationCount := GetReplicationCount(tc)
+		token := n.tokenStruct.Search(ComputeMD5(RoutingKey(req.Table, req.Key)), c).Token
+		pref, ok := n.preferenceList(token, replicationCount)
+		if replicationCount <= 0 || !ok {
+			return nil, &Message{JobId: msg.JobId, Command: constants.CLIENT_NACK_WRITE, Key: req.Key, Reason: "no owners for the key", SrcID: n.id}
+		}
+
+		item := &txItem{req: req, key: key, table: t, quorum: getRCount(tc)}
+		if W := getWCount(tc); W > item.quorum {
+			item.quorum = W
+		}
+		for j := 0; j < replicationCount && j < len(pref); j++ {
+			item.owners = append(item.owners, pref[j].Token)
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+/*
+Checks the votes and condition of item, and computes its write on the reconciled value, nil for condition checks.
+Returns CLIENT_ACK_WRITE if the item can commit, otherwise the reply saying why it cancels the transaction.
+The clock of the write is set by the caller.
+*/
+func (n *Node) prepareWrite(item *txItem, now time.Time) (Message, *Object) {
+	req, votes := item.req, item.votes
+	reply := Message{JobId: req.JobId, Command: constants.CLIENT_ACK_WRITE, Key: req.Key, SrcID: n.id}
+	if votes.conflict != "" {
+		reply.Command, reply.Reason = constants.TRANSACTION_CONFLICT, votes.conflict
+		return reply, nil
+	}
+	if votes.yes < item.quorum {
+		reply.Command, reply.Reason, reply.Replicas, reply.Quorum = constants.CLIENT_NACK_WRITE, "prepare quorum not reached", votes.yes, item.quorum
+		return reply, nil
+	}
+
+	current := votes.obj
+	if item.table.expired(current, now) {
+		current.isDeleted = true // expired items are absent to conditions and updates
+	}
+	if reason, version := conditionFailure(req.Condition, req.Version, current); reason != "" {
+		reply.Command, reply.Reason, reply.Version = constants.CONDITION_FAILED, reason, version
+		return reply, nil
+	}
+	if req.Command == constants.CLIENT_REQ_CHECK {
+		return reply, nil
+	}
+
+	obj := &Object{table: req.Table, key: req.Key, sortKey: req.SortKey, data: req.Data, attrs: req.Attrs.Copy(), isDeleted: req.Command == constants.CLIENT_REQ_DELETE}
+	if req.Command != constants.CLIENT_REQ_WRITE {
+		obj.data = ""
+	}
+	if req.Command == constants.CLIENT_REQ_UPDATE {
+		if err := obj.applyUpdate(current, req.Update, n.id); err != nil {
+			reply.Command, reply.Reason = constants.INVALID_REQUEST, err.Error()
+			return reply, nil
+		}
+	}
+	reply.Data = obj.data
+	return reply, obj
+}
+
+/*
+Resends the TX_COMMIT of txn to owner every SET_DATA_TIMEOUT_MS until it ACKs or the system is closed, like
+restoreHandoff. Owners store the write whether or not they hold a lock of the transaction, so an owner that
+missed the prepare gets the write too. txn is finished once its last owner ACKed.
+*/
+func (n *Node) retryCommit(txn *transaction, owner *Token, commit Message, c *config.Config) {
+	n.mutex.Lock()
+	key, ack_ch := n.awaitAckFrom(owner.phy_id, &commit)
+	n.mutex.Unlock()
+	n.channels[owner.phy_id] <- commit
+
+	timeout := time.Duration(c.SET_DATA_TIMEOUT_MS) * time.Millisecond
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-n.close_ch:
+			n.mutex.Lock()
+			delete(n.awaitAck, key)
+			n.mutex.Unlock()
+			return
+
+		case <-ack_ch:
+			n.mutex.Lock()
+			txn.pending--
+			if txn.pending == 0 {
+				txn.finished = time.Now()
+			}
+			n.mutex.Unlock()
+			if c.DEBUG_LEVEL >= constants.VERBOSE_FIXED {
+				fmt.Printf("retryCommit: %d->%d transaction %s complete.\n", n.GetID(), owner.phy_id, commit.TxId)
+			}
+			return
+
+		case <-timer.C:
+			if c.DEBUG_LEVEL >= constants.VERY_VERBOSE {
+				fmt.Printf("retryCommit: %d->%d timeout reached. Retrying...\n", n.GetID(), owner.phy_id)
+			}
+			n.channels[owner.phy_id] <- commit
+			timer.Reset(timeout)
+		}
+	}
+}
+
+/* Forgets the transactions every owner ACKed more than retention ago. Caller must hold n.mutex. */
+func (n *Node) pruneTransactions(now time.Time, retention time.Duration) {
+	for txId, txn := range n.txns {
+		if !txn.finished.IsZero() && now.Sub(txn.finished) >= retention {
+			delete(n.txns, txId)
+		}
+	}
+}
+
+/* Releases the lock of the transaction of a TX_COMMIT or TX_ABORT, storing the write of a commit unless another transaction locked the key. */
+func (n *Node) resolveIntent(msg Message) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if lock, locked := n.intents[msg.Key]; locked {
+		if lock.txId != msg.TxId {
+			return
+		}
+		delete(n.intents, msg.Key)
+	}
+	if msg.Command == constants.TX_COMMIT && msg.ObjData != nil && n.tables.exists(msg.ObjData.table) {
+		n.store(msg.Key, n.storeVersion(n.data[msg.Key], msg.ObjData))
+	}
+}
+
+/*
+Returns the TX_COMMIT or TX_ABORT answering the TX_STATUS msg, False while the transaction is preparing.
+The outcome echoes the JobId of msg with Attempt -1, it is not ACKed: commits are ACKed to retryCommit.
+*/
+func (n *Node) transactionOutcome(msg Message) (Message, bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	reply := Message{JobId: msg.JobId, Attempt: -1, Command: constants.TX_ABORT, Key: msg.Key, TxId: msg.TxId, SrcID: n.GetID()}
+	txn, known := n.txns[msg.TxId]
+	if !known {
+		return reply, true // aborted transactions are forgotten, finished ones are pruned once no owner holds their lock
+	}
+	if txn.state == txPreparing {
+		return reply, false
+	}
+	reply.Command = constants.TX_COMMIT
+	reply.ObjData = txn.writes[msg.Key].Copy()
+	return reply, true
+}
+
+/*
+Asks the coordinators of the transactions holding locks on this node for their outcome, as the node may
+have missed it while killed. Asks again every SET_DATA_TIMEOUT_MS until those locks are released.
+*/
+func (n *Node) recoverTransactions(c *config.Config) {
+	n.mutex.Lock()
+	inDoubt := make(map[string]intent, len(n.intents))
+	for key, lock := range n.intents {
+		inDoubt[key] = *lock
+	}
+	n.mutex.Unlock()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-n.close_ch:
+			return
+		}
+
+		n.mutex.Lock()
+		for key, lock := range inDoubt {
+			if current, locked := n.intents[key]; !locked || current.txId != lock.txId {
+				delete(inDoubt, key)
+			}
+		}
+		n.mutex.Unlock()
+		if len(inDoubt) == 0 {
+			return
+		}
+
+		for key, lock := range inDoubt {
+			if c.DEBUG_LEVEL >= constants.INFO {
+				fmt.Printf("recoverTransactions: %d asks %d for the outcome of transaction %s\n", n.GetID(), lock.coordinator, lock.txId)
+			}
+			n.mutex.Lock()
+			jobId := n.newJobId() // echoed by the outcome
+			n.mutex.Unlock()
+			n.channels[lock.coordinator] <- Message{JobId: jobId, Command: constants.TX_STATUS, Key: key, TxId: lock.txId, SrcID: n.GetID()}
+		}
+		timer.Reset(time.Duration(c.SET_DATA_TIMEOUT_MS) * time.Millisecond)
+	}
+}
@@ -6,25 +6,127 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Client struct {
 	Id         int
 	Close      chan struct{}
 	Client_ch  chan Message
-	AwaitUids  map[int](*atomic.Bool)
 	NewestRead string
+
+	mutex     sync.Mutex
+	awaiting  map[int]*Future    // job ids with a reply pending
+	early     map[int]earlyReply // replies processed before anyone waited on their job id
+	expired   map[int]time.Time  // job ids that timed out, by when, their late replies are dropped
+	lastPrune time.Time          // early and expired entries older than the client timeouts are dropped, see prune
+}
+
+/* A reply processed before anyone waited on its job id, unclaimed replies are dropped by prune */
+type earlyReply struct {
+	msg Message
+	at  time.Time
+}
+
+func NewClient(id int, close_ch chan struct{}) *Client {
+	return &Client{
+		Id:        id,
+		Close:     close_ch,
+		Client_ch: make(chan Message),
+		awaiting:  make(map[int]*Future),
+		early:     make(map[int]earlyReply),
+		expired:   make(map[int]time.Time),
+	}
+}
+
+/* Future is the pending reply of a request, it completes once with either the reply or an error */
+type Future struct {
+	JobId int
+
+	close_ch chan struct{}
+	done     chan struct{}
+	timer    *time.Timer
+	reply    Message
+	err      error
+}
+
+// Done is closed once the reply or an error is available
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the future completes or the client is closed.
+// Failure replies are returned along with their ReplyError.
+func (f *Future) Wait() (Message, error) {
+	select {
+	case <-f.done:
+		return f.reply, f.err
+	case <-f.close_ch:
+		select {
+		case <-f.done: // completed just before closing
+			return f.reply, f.err
+		default:
+			return Message{}, ErrClientClosed
+		}
+	}
+}
+
+func (f *Future) complete(reply Message, err error) {
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	f.reply = reply
+	f.err = err
+	close(f.done)
+}
+
+/* Key is the primary key of an item, items sharing a partition key are stored together ordered by sort key */
+type Key struct {
+	Partition string
+	Sort      string // optional
+}
+
+func (k Key) String() string {
+	if k.Sort == "" {
+		return k.Partition
+	}
+	return k.Partition + "/" + k.Sort
 }
 
 type Message struct {
 	JobId   int
 	Command int
+	Table   string // for client, table of the item, "" for the default keyspace
 	Key     string
+	SortKey string // for client, sort key of an item within the partition of Key
 	Data    string // for client
 	Wcount  int
+	Attempt int // for inter-node, ACKs echo JobId and Attempt back to the sender
+
+	Reason   string // for client NACKs, why the request failed
+	Replicas int    // for client NACKs, replicas that answered
+	Quorum   int    // for client NACKs, replicas required
+
+	Condition int   // for client writes, constants.COND_* checked by the coordinator before writing
+	Version   []int // for client, vector clock of the value read or written, expected by COND_VERSION_EQUALS
+
+	Attrs  Item           // for client, attributes of an item written or read, counters as N values
+	Update []UpdateAction // for client, actions of CLIENT_REQ_UPDATE
 
-	SrcID   int     // for inter-node
-	ObjData *Object // for inter-node
+	Query   *Query      // for client and inter-node, request of CLIENT_REQ_QUERY and QUERY_DATA
+	Scan    *Scan       // for client, request of CLIENT_REQ_SCAN
+	Items   []QueryItem // for client, page of items answering a query or scan
+	LastKey string      // for client, cursor of the next page, empty on the last page
+
+	Batch []Message // for client, requests of CLIENT_REQ_BATCH and CLIENT_REQ_TRANSACT, and their replies
+
+	TxId string // for inter-node, transaction of TX_* messages
+
+	SrcID   int       // for inter-node
+	ObjData *Object   // for inter-node
+	Objects []*Object // for inter-node, items answering QUERY_DATA and SCAN_DATA
+
+	scanRange *keyRange // for inter-node, hash range requested by SCAN_DATA
 
 	HandoffToken *Token // for inter-node
 
@@ -37,7 +139,38 @@ func (m *Message) ToString(targetID int) string {
 }
 
 func (m *Message) Copy() Message {
-	return Message{JobId: m.JobId, Command: m.Command, Key: m.Key, Data: m.Data, Wcount: m.Wcount, SrcID: m.SrcID, ObjData: m.ObjData.Copy(), Client_Ch: m.Client_Ch}
+	return Message{JobId: m.JobId, Command: m.Command, Table: m.Table, Key: m.Key, SortKey: m.SortKey, Data: m.Data, Wcount: m.Wcount, Attempt: m.Attempt, Reason: m.Reason, Replicas: m.Replicas, Quorum: m.Quorum, Condition: m.Condition, Version: copyVersion(m.Version), Attrs: m.Attrs.Copy(), Update: copyUpdate(m.Update), Query: m.Query.Copy(), Scan: m.Scan.Copy(), Items: copyItems(m.Items), LastKey: m.LastKey, Batch: copyBatch(m.Batch), TxId: m.TxId, SrcID: m.SrcID, ObjData: m.ObjData.Copy(), Objects: copyObjects(m.Objects), scanRange: m.scanRange, Client_Ch: m.Client_Ch}
+}
+
+func copyBatch(batch []Message) []Message {
+	if batch == nil {
+		return nil
+	}
+	ret := make([]Message, len(batch))
+	for i := range batch {
+		ret[i] = batch[i].Copy()
+	}
+	return ret
+}
+
+func copyObjects(objs []*Object) []*Object {
+	if objs == nil {
+		return nil
+	}
+	ret := make([]*Object, len(objs))
+	for i, obj := range objs {
+		ret[i] = obj.Copy()
+	}
+	return ret
+}
+
+func copyVersion(version []int) []int {
+	if version == nil {
+		return nil
+	}
+	ret := make([]int, len(version))
+	copy(ret, version)
+	return ret
 }
 
 /* Versioning information */
@@ -54,48 +187,127 @@ func (c *Context) Copy() *Context {
 
 type Object struct {
 	context   *Context
+	table     string // table of the item, "" for the default keyspace
+	key       string // key as given by the client, data is stored under its hash
+	sortKey   string // sort key as given by the client, orders the items of a partition
 	data      string
+	attrs     Item                  // named attributes written by item puts and updates
+	counters  map[string]*pnCounter // attributes incremented by ADD, merged across concurrent versions
 	isReplica bool
+	isDeleted bool  // tombstone left behind by a delete
+	timestamp int64 // wall clock of the write in nanoseconds, orders versions of LAST_WRITER_WINS tables
 }
 
 func (o *Object) GetData() string {
 	return o.data
 }
 
+func (o *Object) GetKey() string {
+	return o.key
+}
+
+func (o *Object) GetTable() string {
+	return o.table
+}
+
+func (o *Object) GetSortKey() string {
+	return o.sortKey
+}
+
+// GetAttrs returns the attributes of an item with counters as N values, nil for plain values
+func (o *Object) GetAttrs() Item {
+	return o.item()
+}
+
 func (o *Object) IsReplica() bool {
 	return o.isReplica
 }
 
+func (o *Object) IsDeleted() bool {
+	return o.isDeleted
+}
+
 func (o *Object) Copy() *Object {
-	return &Object{context: o.context.Copy(), data: o.data, isReplica: o.isReplica}
+	if o == nil {
+		return nil
+	}
+	ret := &Object{context: o.context.Copy(), table: o.table, key: o.key, sortKey: o.sortKey, data: o.data, attrs: o.attrs.Copy(), isReplica: o.isReplica, isDeleted: o.isDeleted, timestamp: o.timestamp}
+	if o.counters != nil {
+		ret.counters = make(map[string]*pnCounter, len(o.counters))
+		for name, counter := range o.counters {
+			ret.counters[name] = counter.Copy()
+		}
+	}
+	return ret
 }
 
 func (o *Object) ToString() string {
 	if o == nil {
 		return ""
 	}
-	return fmt.Sprintf("context=%v, data=%s, isReplica=%v", o.context, o.data, o.isReplica)
+	if item := o.item(); item != nil {
+		return fmt.Sprintf("context=%v, item=%s, isReplica=%v, isDeleted=%v", o.context, item, o.isReplica, o.isDeleted)
+	}
+	return fmt.Sprintf("context=%v, data=%s, isReplica=%v, isDeleted=%v", o.context, o.data, o.isReplica, o.isDeleted)
+}
+
+/* Identifies an inter-node request awaiting ACK, so concurrent requests to the same node are tracked independently */
+type ackKey struct {
+	jobId   int
+	dst     int
+	attempt int
 }
 
+/*
+Node state is shared by the Start loop and the goroutines it spawns for Get, Put and
+restoreHandoff. Fields below mutex are guarded by it, the rest is only written before Start.
+Stored objects are owned by the node, objects sent to or received from other nodes are copies.
+*/
 type Node struct {
 	id       int
-	v_clk    []int
 	channels map[int](chan Message)
 	rcv_ch   chan Message
 	tokens   []*Token
-	data     map[string]*Object           // key-value data store
-	backup   map[int](map[string]*Object) // backup of key-value data stores
-	close_ch chan struct{}                //to close go channels properly
+	close_ch chan struct{} //to close go channels properly
 
-	awaitAck     map[int](*atomic.Bool) // flags to check on timeout routines
 	tokenStruct  BST
 	prefList     map[*Token][]*TreeNode
 	handOffQueue []*Token
 
+	tables *Catalog // tables of the cluster, shared by every node
+
+	config atomic.Pointer[config.Config] // settings of the requests it receives, replaced by SetConfig
+
+	keyLocks map[string]*keyLock // serialize the writes coordinated by this node per storage key, see lockKey
+
 	// Locking for concurrent rep
-	mutex       sync.Mutex
-	numReads    map[int]int
-	readTimeout chan int
+	mutex        sync.Mutex
+	v_clk        []int
+	data         map[string]*Object           // key-value data store
+	partitions   map[string][]string          // sort keys stored under each partition hash, in order
+	backup       map[int](map[string]*Object) // backup of key-value data stores
+	awaitAck     map[ackKey](chan struct{})   // closed when the matching ACK arrives
+	lastJobId    int                          // job ids of inter-node requests issued by this node
+	reads        map[int]*quorumRead          // quorum reads in progress, by job id
+	txns         map[string]*transaction      // transactions coordinated by this node, by transaction id
+	intents      map[string]*intent           // locks held by prepared transactions, by storage key, kept across a kill
+	votes        map[int]*txVotes             // prepares in progress, by job id
+	indexUpdates map[int]indexUpdate          // index entries written by this node and not yet held by W owners, by job id
+}
+
+/* Lock of the writes to a storage key coordinated by a node, dropped once nobody holds or waits on it */
+type keyLock struct {
+	mutex   sync.Mutex
+	holders int // holding or waiting, guarded by the node mutex
+}
+
+/* Replies of a quorum read, reconciled as they arrive. done is closed once quorum replicas answered. */
+type quorumRead struct {
+	obj      *Object
+	items    map[string]*Object // for queries and scans, matching items by storage key
+	replicas int
+	quorum   int // R of the table read
+	done     chan struct{}
 }
 
 func (n *Node) GetPrefList() map[*Token][]*TreeNode {
@@ -110,11 +322,13 @@ func (n *Node) GetTokens() []*Token {
 	return n.tokens
 }
 func (n *Node) GetData(key string) *Object {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
 	obj, exists := n.data[key]
 	if !exists {
 		return &Object{}
 	}
-	return obj
+	return obj.Copy()
 }
 
 func (n *Node) GetID() int {
@@ -0,0 +1,155 @@
+package base
+
+import (
+	"constants"
+	"fmt"
+	"strconv"
+)
+
+/* UpdateAction is one action of CLIENT_REQ_UPDATE, applied by the coordinator to the value read from R replicas */
+type UpdateAction struct {
+	Op    string // constants.UPDATE_*
+	Name  string
+	Value AttributeValue // for UPDATE_SET
+	Delta int64          // for UPDATE_ADD, negative to decrement
+}
+
+/*
+PN-counter CRDT. Increments and decrements are tracked per node, so concurrent
+versions of a counter merge by taking the maximum of each node's entries.
+*/
+type pnCounter struct {
+	inc map[int]int64
+	dec map[int]int64
+}
+
+func newPNCounter() *pnCounter {
+	return &pnCounter{inc: make(map[int]int64), dec: make(map[int]int64)}
+}
+
+func (p *pnCounter) Value() int64 {
+	var value int64
+	for _, v := range p.inc {
+		value += v
+	}
+	for _, v := range p.dec {
+		value -= v
+	}
+	return value
+}
+
+// add records delta as applied by node id
+func (p *pnCounter) add(id int, delta int64) {
+	if delta >= 0 {
+		p.inc[id] += delta
+	} else {
+		p.dec[id] -= delta
+	}
+}
+
+func (p *pnCounter) merge(other *pnCounter) {
+	for id, v := range other.inc {
+		if v > p.inc[id] {
+			p.inc[id] = v
+		}
+	}
+	for id, v := range other.dec {
+		if v > p.dec[id] {
+			p.dec[id] = v
+		}
+	}
+}
+
+func (p *pnCounter) Copy() *pnCounter {
+	ret := newPNCounter()
+	ret.merge(p)
+	return ret
+}
+
+func copyUpdate(actions []UpdateAction) []UpdateAction {
+	if actions == nil {
+		return nil
+	}
+	ret := make([]UpdateAction, len(actions))
+	for i, action := range actions {
+		ret[i] = action
+		ret[i].Value = action.Value.Copy()
+	}
+	return ret
+}
+
+// Returns a copy of the attributes with counters as N values, nil for plain values
+func (o *Object) item() Item {
+	if o.attrs == nil && o.counters == nil {
+		return nil
+	}
+	item := o.attrs.Copy()
+	if item == nil {
+		item = make(Item, len(o.counters))
+	}
+	for name, counter := range o.counters {
+		item[name] = N(strconv.FormatInt(counter.Value(), 10))
+	}
+	return item
+}
+
+// Merges the counters of a concurrent version into o, increments seen by either are kept
+func (o *Object) mergeCounters(other *Object) {
+	for name, counter := range other.counters {
+		if _, isAttr := o.attrs[name]; isAttr {
+			continue
+		}
+		if o.counters == nil {
+			o.counters = make(map[string]*pnCounter)
+		}
+		if _, exists := o.counters[name]; !exists {
+			o.counters[name] = newPNCounter()
+		}
+		o.counters[name].merge(counter)
+	}
+}
+
+/*
+Applies actions on top of the live value current (nil if absent or deleted), increments are recorded for node id.
+SET values are validated. ADD creates missing counters and fails on attributes that were SET.
+*/
+func (o *Object) applyUpdate(current *Object, actions []UpdateAction, id int) error {
+	if current != nil && !current.isDeleted {
+		cur := current.Copy()
+		o.data, o.attrs, o.counters = cur.data, cur.attrs, cur.counters
+	}
+	if o.attrs == nil {
+		o.attrs = make(Item)
+	}
+	if o.counters == nil {
+		o.counters = make(map[string]*pnCounter)
+	}
+
+	for _, action := range actions {
+		if action.Name == "" {
+			return fmt.Errorf("attribute names must not be empty")
+		}
+		switch action.Op {
+		case constants.UPDATE_SET:
+			if err := action.Value.Validate(); err != nil {
+				return fmt.Errorf("SET %s: %w", action.Name, err)
+			}
+			delete(o.counters, action.Name)
+			o.attrs[action.Name] = action.Value.Copy()
+		case constants.UPDATE_REMOVE:
+			delete(o.counters, action.Name)
+			delete(o.attrs, action.Name)
+		case constants.UPDATE_ADD:
+			if _, isAttr := o.attrs[action.Name]; isAttr {
+				return fmt.Errorf("attribute %s is not a counter", action.Name)
+			}
+			if _, exists := o.counters[action.Name]; !exists {
+				o.counters[action.Name] = newPNCounter()
+			}
+			o.counters[action.Name].add(id, action.Delta)
+		default:
+			return fmt.Errorf("unknown update action %q", action.Op)
+		}
+	}
+	return nil
+}
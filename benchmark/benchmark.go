@@ -2,28 +2,23 @@ package main
 
 import (
 	"base"
+	"client"
 	"config"
 	"constants"
+	"context"
 	"fmt"
 	"math/rand"
 	"sync"
-	"sync/atomic"
-
-	// "sync/atomic"
+	"syscall"
 	"time"
 )
 
 // /////////////////  Helper funcs  ///////////////////////////
-func setupClients(numClients int, close_ch chan struct{}, c *config.Config) map[int](*base.Client) {
-	clients := make(map[int](*base.Client))
+func setupClients(numClients int, phy_nodes []*base.Node, c *config.Config) map[int](*client.Client) {
+	clients := make(map[int](*client.Client))
 
 	for j := 0; j < numClients; j++ {
-		clients[j] = &base.Client{
-			Id:        j,
-			Close:     close_ch,
-			Client_ch: make(chan base.Message),
-			AwaitUids: make(map[int]*atomic.Bool)}
-		go clients[j].StartListening(c)
+		clients[j] = client.New(phy_nodes, c)
 	}
 
 	return clients
@@ -72,6 +67,31 @@ func generateRandomKeyValuePairs(maxKeyLength int, maxValueLength, n int) map[st
 	return keyValueMap
 }
 
+// cpuTime returns the user and system CPU time consumed by the process so far
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// prints the problems of c found by config.Validate, benchmarks with errors run with N, R and W clamped by the nodes
+func validate(c *config.Config) {
+	if problems := c.Validate(); len(problems) > 0 {
+		fmt.Println(problems)
+	}
+}
+
+func printResult(duration time.Duration, cpu time.Duration) {
+	fmt.Printf("----------------------\n")
+	fmt.Printf("|                     |\n")
+	fmt.Printf("|                     |\n")
+	fmt.Printf("|  wall %v    |\n", duration)
+	fmt.Printf("|  cpu  %v    |\n", cpu)
+	fmt.Printf("|                     |\n")
+	fmt.Printf("|                     |\n")
+	fmt.Printf("----------------------\n")
+}
+
 ///////////////////  Benchmark funcs  ///////////////////////////
 
 func BenchmarkSingleClientGet() {
@@ -96,6 +116,7 @@ func BenchmarkSingleClientGet() {
 	rAndWValue := 10
 
 	startTime := time.Now()
+	startCpu := cpuTime()
 
 	fmt.Printf("clientNum: %d   |   nodeNum: %d   |   tokenNum: %d   |   nValue: %d   |   R_and_W_Value: %d\n", numClients, numNodes, numTokens, nValue, rAndWValue)
 
@@ -107,66 +128,43 @@ func BenchmarkSingleClientGet() {
 	c.W = rAndWValue
 	// c.DEBUG_LEVEL = 1
 	c.CLIENT_GET_TIMEOUT_MS = 5_000
+	validate(&c)
 	close_ch := make(chan struct{})
 	key := "k"
 	value := "val"
 
-	//create 1 client
-	clients := setupClients(1, close_ch, &c)
-
 	phy_nodes := base.CreateNodes(close_ch, &c)
 	base.InitializeTokens(phy_nodes, &c)
 	// defer close(close_ch)
 
+	//create 1 client
+	clients := setupClients(1, phy_nodes, &c)
+
 	var wg sync.WaitGroup
 	for i := range phy_nodes {
 		wg.Add(1)
-		go phy_nodes[i].Start(&wg, &c)
+		go phy_nodes[i].Start(&wg)
 	}
 
 	//NOTE: if this time.Sleep is excluded, data may not be fully replicated before the read
 	time.Sleep(time.Millisecond)
 
 	client := clients[0]
+	ctx := context.Background()
 
-	_, node := base.FindNode(key, phy_nodes, &c)
-	channel := (*node).GetChannel()
-
-	channel <- base.Message{
-		JobId:     0,
-		Key:       key,
-		Command:   constants.CLIENT_REQ_WRITE,
-		Data:      value,
-		SrcID:     client.Id,
-		Client_Ch: client.Client_ch}
-
-	client.StartTimeout(0, constants.CLIENT_REQ_WRITE, c.CLIENT_GET_TIMEOUT_MS)
-
-	channel <- base.Message{
-		JobId:     1,
-		Key:       key,
-		Command:   constants.CLIENT_REQ_READ,
-		SrcID:     client.Id,
-		Client_Ch: client.Client_ch}
-	client.StartTimeout(1, constants.CLIENT_REQ_READ, c.CLIENT_GET_TIMEOUT_MS)
+	if err := client.Put(ctx, key, value); err != nil {
+		fmt.Println(err)
+	}
+	got, err := client.Get(ctx, key)
 
 	close(close_ch)
 	wg.Wait()
-	//read only after execution is done since client's StartListening function is a goroutine
-	if client.NewestRead != value {
-		fmt.Printf("got: %s, expected: %s", client.NewestRead, value)
+	if got != value {
+		fmt.Printf("got: %s, expected: %s, err: %v", got, value, err)
 
 	}
 
-	duration := time.Since(startTime)
-
-	fmt.Printf("----------------------\n")
-	fmt.Printf("|                     |\n")
-	fmt.Printf("|                     |\n")
-	fmt.Printf("|      %v    |\n", duration)
-	fmt.Printf("|                     |\n")
-	fmt.Printf("|                     |\n")
-	fmt.Printf("----------------------")
+	printResult(time.Since(startTime), cpuTime()-startCpu)
 }
 
 func BenchmarkMultipleClientMultiplePutMultipleGet() {
@@ -194,6 +192,7 @@ func BenchmarkMultipleClientMultiplePutMultipleGet() {
 	// testname := fmt.Sprintf("%d_clients_%d_nodes_%d_tokens_%d_n_%d_rAndW", numClients, numNodes, numTokens, nValue, rAndWValue)
 
 	startTime := time.Now()
+	startCpu := cpuTime()
 
 	fmt.Printf("clientNum: %d   |   nodeNum: %d   |   tokenNum: %d   |   nValue: %d   |   R_and_W_Value: %d\n", numClients, numNodes, numTokens, nValue, rAndWValue)
 	fmt.Println("Start test for numclients: ", numClients)
@@ -210,41 +209,30 @@ func BenchmarkMultipleClientMultiplePutMultipleGet() {
 
 	c.CLIENT_GET_TIMEOUT_MS = 5_000
 	// c.DEBUG_LEVEL = 0
-	jobId := 0
 
+	validate(&c)
 	close_ch := make(chan struct{})
 
-	//create 1 client
-	clients := setupClients(numClients, close_ch, &c)
-
 	phy_nodes := base.CreateNodes(close_ch, &c)
 	base.InitializeTokens(phy_nodes, &c)
 	// defer close(close_ch)
 
+	clients := setupClients(numClients, phy_nodes, &c)
+	ctx := context.Background()
+
 	var wg sync.WaitGroup
 	for i := range phy_nodes {
 		wg.Add(1)
-		go phy_nodes[i].Start(&wg, &c)
+		go phy_nodes[i].Start(&wg)
 	}
 
 	//this put is sequential but technically it is how the client is working as well
 	for i, key := range keys {
 		value := keyValuePairs[key]
 		client := clients[i]
-		// fmt.Println("Client ", client.Id, "putting key ", key, " val: ", value)
-		_, node := base.FindNode(key, phy_nodes, &c)
-		channel := (*node).GetChannel()
-
-		channel <- base.Message{
-			JobId:     jobId,
-			Key:       key,
-			Command:   constants.CLIENT_REQ_WRITE,
-			Data:      value,
-			SrcID:     client.Id,
-			Client_Ch: client.Client_ch}
-
-		client.StartTimeout(jobId, constants.CLIENT_REQ_WRITE, c.CLIENT_GET_TIMEOUT_MS)
-		jobId++
+		if err := client.Put(ctx, key, value); err != nil {
+			fmt.Println(err)
+		}
 	}
 
 	//NOTE: if this time.Sleep is excluded, data may not be fully replicated before the read
@@ -252,41 +240,144 @@ func BenchmarkMultipleClientMultiplePutMultipleGet() {
 
 	for i, key := range keys {
 		client := clients[i]
-		// fmt.Println("Client ", client.Id, "getting key ", key, " val: ", keyValuePairs[key])
-		_, node := base.FindNode(key, phy_nodes, &c)
-		channel := (*node).GetChannel()
-		channel <- base.Message{
-			JobId:     jobId,
-			Key:       key,
-			Command:   constants.CLIENT_REQ_READ,
-			SrcID:     client.Id,
-			Client_Ch: client.Client_ch}
-		client.StartTimeout(jobId, constants.CLIENT_REQ_READ, c.CLIENT_GET_TIMEOUT_MS)
-		jobId++
+		got, err := client.Get(ctx, key)
+		if got != keyValuePairs[key] {
+			fmt.Printf("got from client %d: %s, expected: %s, err: %v", i, got, keyValuePairs[key], err)
+		}
 	}
 	close(close_ch)
 	wg.Wait()
-	//read only after execution is done since client's StartListening function is a goroutine
-	for i, key := range keys {
-		client := clients[i]
-		if client.NewestRead != keyValuePairs[key] {
-			fmt.Printf("got from client %d: %s, expected: %s", client.Id, client.NewestRead, keyValuePairs[key])
-		}
+
+	printResult(time.Since(startTime), cpuTime()-startCpu)
+}
+
+// BenchmarkConcurrentPutsWithFailures keeps many puts in flight while some
+// replicas are down, so coordinators wait on replication ACKs until timeout
+func BenchmarkConcurrentPutsWithFailures() {
+	numRequests := 200
+	numNodes := 20
+	numTokens := 40
+	nValue := 5
+	wValue := 3
+	numKill := 3
+
+	fmt.Println("~~ Running benchmark: Concurrent puts with failed replicas ~~ ")
+	fmt.Printf("requestNum: %d   |   nodeNum: %d   |   tokenNum: %d   |   nValue: %d   |   W_Value: %d   |   killNum: %d\n", numRequests, numNodes, numTokens, nValue, wValue, numKill)
+
+	c := config.InstantiateConfig()
+	c.NUM_NODES = numNodes
+	c.NUM_TOKENS = numTokens
+	c.N = nValue
+	c.W = wValue
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_PUT_TIMEOUT_MS = 10_000
+
+	validate(&c)
+	close_ch := make(chan struct{})
+	phy_nodes := base.CreateNodes(close_ch, &c)
+	base.InitializeTokens(phy_nodes, &c)
+
+	var wg sync.WaitGroup
+	for i := range phy_nodes {
+		wg.Add(1)
+		go phy_nodes[i].Start(&wg)
+	}
+	for i := 0; i < numKill; i++ {
+		phy_nodes[i].GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
 	}
 
-	duration := time.Since(startTime)
+	keyValuePairs := generateRandomKeyValuePairs(20, 100, numRequests)
+	cl := client.New(phy_nodes, &c)
 
-	fmt.Printf("----------------------\n")
-	fmt.Printf("|                     |\n")
-	fmt.Printf("|                     |\n")
-	fmt.Printf("|      %v    |\n", duration)
-	fmt.Printf("|                     |\n")
-	fmt.Printf("|                     |\n")
-	fmt.Printf("----------------------")
+	startTime := time.Now()
+	startCpu := cpuTime()
+
+	var puts sync.WaitGroup
+	for key, value := range keyValuePairs {
+		puts.Add(1)
+		go func(key string, value string) {
+			defer puts.Done()
+			if err := cl.Put(context.Background(), key, value); err != nil {
+				fmt.Println(err)
+			}
+		}(key, value)
+	}
+	puts.Wait()
+
+	printResult(time.Since(startTime), cpuTime()-startCpu)
+	close(close_ch)
+	wg.Wait()
+}
+
+// BenchmarkPipelinedClient keeps every request of a single client in flight at
+// once, futures are only awaited after all requests were sent
+func BenchmarkPipelinedClient() {
+	numRequests := 1000
+	numNodes := 20
+	numTokens := 40
+	nValue := 5
+	rAndWValue := 3
+
+	fmt.Println("~~ Running benchmark: Single client pipelined Put and Get ~~ ")
+	fmt.Printf("requestNum: %d   |   nodeNum: %d   |   tokenNum: %d   |   nValue: %d   |   R_and_W_Value: %d\n", numRequests, numNodes, numTokens, nValue, rAndWValue)
+
+	c := config.InstantiateConfig()
+	c.NUM_NODES = numNodes
+	c.NUM_TOKENS = numTokens
+	c.N = nValue
+	c.R = rAndWValue
+	c.W = rAndWValue
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_GET_TIMEOUT_MS = 10_000
+
+	validate(&c)
+	close_ch := make(chan struct{})
+	phy_nodes := base.CreateNodes(close_ch, &c)
+	base.InitializeTokens(phy_nodes, &c)
+
+	var wg sync.WaitGroup
+	for i := range phy_nodes {
+		wg.Add(1)
+		go phy_nodes[i].Start(&wg)
+	}
+
+	cl := base.NewClient(0, close_ch)
+	go cl.StartListening(&c)
+
+	keyValuePairs := generateRandomKeyValuePairs(20, 100, numRequests)
+	keys := generateOrderedKeys(keyValuePairs)
+
+	startTime := time.Now()
+	startCpu := cpuTime()
+
+	failed := 0
+	for _, command := range []int{constants.CLIENT_REQ_WRITE, constants.CLIENT_REQ_READ} {
+		futures := []*base.Future{}
+		for i, key := range keys {
+			_, node := base.FindNode(key, phy_nodes, &c)
+			msg := base.Message{JobId: command*numRequests + i, Key: key, Command: command, Data: keyValuePairs[key]}
+			futures = append(futures, cl.Send(node.GetChannel(), msg, c.CLIENT_GET_TIMEOUT_MS))
+		}
+
+		// all gets are sent after every put completed
+		_, errs := base.WaitAll(futures)
+		for _, err := range errs {
+			if err != nil {
+				failed++
+			}
+		}
+	}
+	fmt.Printf("failed requests: %d/%d\n", failed, 2*numRequests)
+
+	printResult(time.Since(startTime), cpuTime()-startCpu)
+	close(close_ch)
+	wg.Wait()
 }
 
 func main() {
 
 	// BenchmarkSingleClientGet()
 	BenchmarkMultipleClientMultiplePutMultipleGet()
+	BenchmarkConcurrentPutsWithFailures()
+	BenchmarkPipelinedClient()
 }
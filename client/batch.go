@@ -0,0 +1,174 @@
+package client
+
+import (
+	"base"
+	"constants"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// BatchKey names an item of a table, Table "" for the default keyspace
+type BatchKey struct {
+	Table string
+	Key   base.Key
+}
+
+func (k BatchKey) String() string {
+	if k.Table == "" {
+		return k.Key.String()
+	}
+	return k.Table + ":" + k.Key.String()
+}
+
+// BatchWrite puts Item under its key, the plain Value if Item is nil, or deletes the key if Delete is set
+type BatchWrite struct {
+	BatchKey
+	Item   base.Item
+	Value  string
+	Delete bool
+}
+
+// BatchGetResult holds the items read by BatchGet
+type BatchGetResult struct {
+	Items       map[BatchKey]Value // live values and items, keys without one are left out
+	Unprocessed []BatchKey         // keys that failed, in request order, to be retried
+}
+
+// BatchWriteResult holds the writes of BatchWrite that were not acknowledged by W replicas
+type BatchWriteResult struct {
+	Unprocessed []BatchWrite // in request order, to be retried
+}
+
+/*
+BatchGet reads up to BATCH_MAX_KEYS keys of any tables, see batch. Keys that fail to reach their read
+quorum are returned as unprocessed, a missing table fails the whole batch with ErrTableNotFound.
+*/
+func (cl *Client) BatchGet(ctx context.Context, keys []BatchKey) (BatchGetResult, error) {
+	reqs := make([]base.Message, len(keys))
+	for i, key := range keys {
+		reqs[i] = base.Message{Table: key.Table, Key: key.Key.Partition, SortKey: key.Key.Sort, Command: constants.CLIENT_REQ_READ}
+	}
+	if err := cl.checkBatch("batch get", keys); err != nil {
+		return BatchGetResult{}, err
+	}
+
+	result := BatchGetResult{Items: make(map[BatchKey]Value)}
+	replies, err := cl.batch(ctx, "batch get", reqs, cl.config().CLIENT_GET_TIMEOUT_MS)
+	for i, reply := range replies {
+		switch replyErr := base.ReplyError(reply); {
+		case reply.Command == constants.CLIENT_ACK_READ:
+			result.Items[keys[i]] = Value{Data: reply.Data, Attrs: reply.Attrs, Version: reply.Version}
+		case replyErr == ErrNotFound:
+		default:
+			result.Unprocessed = append(result.Unprocessed, keys[i])
+		}
+	}
+	return result, err
+}
+
+/*
+BatchWrite applies up to BATCH_MAX_KEYS unconditional puts and deletes of any tables, see batch.
+Writes that fail to reach their write quorum are returned as unprocessed. Invalid items, missing
+tables and keys written twice fail the whole batch before anything is written.
+*/
+func (cl *Client) BatchWrite(ctx context.Context, writes []BatchWrite) (BatchWriteResult, error) {
+	keys := make([]BatchKey, len(writes))
+	reqs := make([]base.Message, len(writes))
+	for i, write := range writes {
+		keys[i] = write.BatchKey
+		reqs[i] = base.Message{Table: write.Table, Key: write.Key.Partition, SortKey: write.Key.Sort, Command: constants.CLIENT_REQ_WRITE}
+		switch {
+		case write.Delete:
+			reqs[i].Command = constants.CLIENT_REQ_DELETE
+		case write.Item == nil:
+			reqs[i].Data = write.Value
+		default:
+			if err := write.Item.Validate(); err != nil {
+				return BatchWriteResult{}, &RequestError{Op: "batch write", Key: write.BatchKey.String(), Err: fmt.Errorf("%w: %s", ErrInvalidRequest, err)}
+			}
+			reqs[i].Attrs = write.Item
+		}
+	}
+	if err := cl.checkBatch("batch write", keys); err != nil {
+		return BatchWriteResult{}, err
+	}
+
+	var result BatchWriteResult
+	replies, err := cl.batch(ctx, "batch write", reqs, cl.config().CLIENT_PUT_TIMEOUT_MS)
+	for i, reply := range replies {
+		if reply.Command != constants.CLIENT_ACK_WRITE {
+			result.Unprocessed = append(result.Unprocessed, writes[i])
+		}
+	}
+	return result, err
+}
+
+// Checks the batch holds between 1 and BATCH_MAX_KEYS distinct keys of existing tables
+func (cl *Client) checkBatch(op string, keys []BatchKey) error {
+	if len(keys) == 0 || len(keys) > cl.config().BATCH_MAX_KEYS {
+		return &RequestError{Op: op, Err: fmt.Errorf("%w: a batch holds 1 to %d keys, got %d", ErrInvalidRequest, cl.config().BATCH_MAX_KEYS, len(keys))}
+	}
+	seen := make(map[BatchKey]struct{}, len(keys))
+	for _, key := range keys {
+		if _, exists := seen[key]; exists {
+			return &RequestError{Op: op, Key: key.String(), Err: fmt.Errorf("%w: key is in the batch twice", ErrInvalidRequest)}
+		}
+		seen[key] = struct{}{}
+		if key.Table != "" {
+			if _, err := cl.DescribeTable(key.Table); err != nil {
+				return &RequestError{Op: op, Key: key.String(), Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ 1. Group the requests by the token of their coordinator, the requests of a group share its preference list
+ 2. Send every group as one CLIENT_REQ_BATCH in parallel, falling back along the preference list as do does
+ 3. Return the reply of every request in request order, a NACK for the requests of groups that failed
+
+The coordinator NACKs requests that do not answer within timeout_ms, the client waits twice as long
+for the whole group. The error returned is that of the context, if it ended before every group replied.
+*/
+func (cl *Client) batch(ctx context.Context, op string, reqs []base.Message, timeout_ms int) ([]base.Message, error) {
+	phy_nodes := cl.nodes()
+	groups := make(map[*base.Token][]int)
+	for i, req := range reqs {
+		token, _ := base.FindNode(base.RoutingKey(req.Table, req.Key), phy_nodes, cl.config())
+		groups[token] = append(groups[token], i)
+	}
+
+	replies := make([]base.Message, len(reqs))
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		wg.Add(1)
+		go func(group []int) {
+			defer wg.Done()
+			batch := make([]base.Message, len(group))
+			for j, i := range group {
+				batch[j] = reqs[i]
+			}
+			first := reqs[group[0]] // routes the group to its coordinator
+			msg, err := cl.do(ctx, op, base.Message{Table: first.Table, Key: first.Key, SortKey: first.SortKey, Command: constants.CLIENT_REQ_BATCH, Batch: batch}, 2*timeout_ms)
+
+			for j, i := range group {
+				if err == nil && len(msg.Batch) == len(group) {
+					replies[i] = msg.Batch[j]
+				} else {
+					replies[i] = base.Message{Command: constants.CLIENT_NACK_WRITE, Key: reqs[i].Key, Reason: "batch failed"}
+				}
+			}
+			if err != nil && cl.config().DEBUG_LEVEL >= constants.INFO {
+				fmt.Printf("client: %s of %d keys failed: %s\n", op, len(group), err)
+			}
+		}(group)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return replies, &RequestError{Op: op, Err: err}
+	}
+	return replies, nil
+}
@@ -0,0 +1,402 @@
+package client
+
+import (
+	"base"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// formats of Import and Export
+const (
+	FormatJSONLines = "jsonl"
+	FormatCSV       = "csv"
+)
+
+// batches written or segments scanned in parallel by Import and Export unless BulkOptions.Concurrency is set
+const defaultBulkConcurrency = 4
+
+// columns of the key and plain value in CSV files, the other columns are attributes
+const (
+	csvKey     = "key"
+	csvSortKey = "sort_key"
+	csvValue   = "value"
+)
+
+// FormatOf returns the format of the file path by its extension, CSV for ".csv" and JSON Lines otherwise
+func FormatOf(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return FormatCSV
+	}
+	return FormatJSONLines
+}
+
+/*
+BulkRecord is an item as imported and exported in JSON Lines, the plain Value if Item is null.
+In CSV files the header names the columns key, sort_key and value, the other columns are attributes typed as
+the CLI types values: "quoted" strings, numbers, true, false and null, anything else is a string. Rows holding
+an attribute are items, the others plain values. Empty cells are absent attributes.
+*/
+type BulkRecord struct {
+	Key     string
+	SortKey string `json:",omitempty"`
+	Value   string `json:",omitempty"`
+	Item    base.Item
+}
+
+// BulkOptions tune Import and Export
+type BulkOptions struct {
+	Format      string             // FormatJSONLines or FormatCSV, "" for JSON Lines
+	BatchSize   int                // writes of an import batch and items of an export page, 0 for BATCH_MAX_KEYS
+	Concurrency int                // import batches in flight or export segments scanned in parallel, 0 for 4
+	Progress    func(BulkProgress) // called after every batch or page, by one goroutine at a time
+}
+
+// BulkProgress counts the items of an Import or Export so far
+type BulkProgress struct {
+	Items  int // items written to the table or the file
+	Failed int // imported items not acknowledged by W replicas after Retries further batches
+}
+
+func (opts *BulkOptions) init(cl *Client) error {
+	switch opts.Format {
+	case "":
+		opts.Format = FormatJSONLines
+	case FormatJSONLines, FormatCSV:
+	default:
+		return fmt.Errorf("%w: unknown format %q", ErrInvalidRequest, opts.Format)
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > cl.config().BATCH_MAX_KEYS {
+		opts.BatchSize = cl.config().BATCH_MAX_KEYS
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultBulkConcurrency
+	}
+	return nil
+}
+
+/*
+Import writes the records read from r to the table through BatchWrite, opts.Concurrency batches at a time.
+Writes left unprocessed are retried in a further batch up to Retries times, then counted as failed. A key
+repeated in the file is written in file order. A malformed record stops the import with an error naming its line,
+the records before it are written. Counters are imported as numbers.
+*/
+func (t *Table) Import(ctx context.Context, r io.Reader, opts BulkOptions) (BulkProgress, error) {
+	if err := opts.init(t.cl); err != nil {
+		return BulkProgress{}, &RequestError{Op: "import", Key: t.name, Err: err}
+	}
+	if t.name != "" {
+		if _, err := t.cl.DescribeTable(t.name); err != nil {
+			return BulkProgress{}, &RequestError{Op: "import", Key: t.name, Err: err}
+		}
+	}
+
+	var mutex sync.Mutex
+	var progress BulkProgress
+	inFlight := make(map[BatchKey]int) // keys of the batches sent and not yet written
+	written := sync.NewCond(&mutex)
+	batches := make(chan []BatchWrite)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				failed := t.cl.writeBatch(ctx, batch)
+				mutex.Lock()
+				for _, write := range batch {
+					if inFlight[write.BatchKey]--; inFlight[write.BatchKey] == 0 {
+						delete(inFlight, write.BatchKey)
+					}
+				}
+				progress.Items += len(batch) - failed
+				progress.Failed += failed
+				if opts.Progress != nil {
+					opts.Progress(progress)
+				}
+				written.Broadcast()
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	// batches are cut when full or when a key repeats, a key is written again once its previous batch is
+	var batch []BatchWrite
+	inBatch := make(map[BatchKey]struct{})
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		mutex.Lock()
+		for _, write := range batch {
+			inFlight[write.BatchKey]++
+		}
+		mutex.Unlock()
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			return false
+		}
+		batch, inBatch = nil, make(map[BatchKey]struct{})
+		return true
+	}
+	err := readRecords(r, opts.Format, func(record BulkRecord) bool {
+		write := BatchWrite{BatchKey: BatchKey{Table: t.name, Key: base.Key{Partition: record.Key, Sort: record.SortKey}}, Item: record.Item, Value: record.Value}
+		if _, repeated := inBatch[write.BatchKey]; repeated || len(batch) == opts.BatchSize {
+			if !flush() {
+				return false
+			}
+		}
+		mutex.Lock()
+		for inFlight[write.BatchKey] > 0 {
+			written.Wait()
+		}
+		mutex.Unlock()
+		batch = append(batch, write)
+		inBatch[write.BatchKey] = struct{}{}
+		return true
+	})
+	flush() // the records before a malformed one are written
+	close(batches)
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil && progress.Failed > 0 {
+		err = fmt.Errorf("%w: %d item(s) were not acknowledged by W replicas", ErrTimeout, progress.Failed)
+	}
+	if err != nil {
+		return progress, &RequestError{Op: "import", Key: t.name, Attempts: t.cl.Retries + 1, Err: err}
+	}
+	return progress, nil
+}
+
+// Writes batch, retrying the unprocessed writes up to Retries times, returns the number of writes that failed
+func (cl *Client) writeBatch(ctx context.Context, batch []BatchWrite) int {
+	for attempt := 0; len(batch) > 0 && attempt <= cl.Retries; attempt++ {
+		result, err := cl.BatchWrite(ctx, batch)
+		if err != nil && ctx.Err() == nil && len(result.Unprocessed) == 0 {
+			return len(batch) // rejected as a whole, e.g. an invalid item
+		}
+		batch = result.Unprocessed
+	}
+	return len(batch)
+}
+
+// Reads the records of r in format, calling fn on each in order until it returns False
+func readRecords(r io.Reader, format string, fn func(BulkRecord) bool) error {
+	if format == FormatCSV {
+		return readCSV(r, fn)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record BulkRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("%w: line %d: %s", ErrInvalidRequest, line, err)
+		}
+		if err := record.validate(); err != nil {
+			return fmt.Errorf("%w: line %d: %s", ErrInvalidRequest, line, err)
+		}
+		if !fn(record) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func readCSV(r io.Reader, fn func(BulkRecord) bool) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidRequest, err)
+	}
+	hasKey := false
+	for _, column := range header {
+		hasKey = hasKey || column == csvKey
+	}
+	if !hasKey {
+		return fmt.Errorf("%w: line 1: the header has no %q column", ErrInvalidRequest, csvKey)
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidRequest, err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(row) != len(header) {
+			return fmt.Errorf("%w: line %d: %d field(s), the header has %d", ErrInvalidRequest, line, len(row), len(header))
+		}
+		var record BulkRecord
+		for i, cell := range row {
+			switch header[i] {
+			case csvKey:
+				record.Key = cell
+			case csvSortKey:
+				record.SortKey = cell
+			case csvValue:
+				record.Value = cell
+			default:
+				if cell == "" {
+					continue
+				}
+				if record.Item == nil {
+					record.Item = base.Item{}
+				}
+				record.Item[header[i]] = base.ParseValueArg(cell)
+			}
+		}
+		if err := record.validate(); err != nil {
+			return fmt.Errorf("%w: line %d: %s", ErrInvalidRequest, line, err)
+		}
+		if !fn(record) {
+			return nil
+		}
+	}
+}
+
+func (record *BulkRecord) validate() error {
+	if record.Key == "" {
+		return errors.New("the key is empty")
+	}
+	if record.Item != nil && record.Value != "" {
+		return errors.New("a record holds either a value or an item")
+	}
+	return record.Item.Validate()
+}
+
+/*
+Export writes the live items of the table to w in opts.Format, scanning opts.Concurrency segments in parallel
+with pages of opts.BatchSize items. JSON Lines records are written as pages are read, CSV files are written
+once the scan is over as their header names every attribute, with rows in key order. Items holding binary,
+list, map or set attributes cannot be exported to CSV.
+*/
+func (t *Table) Export(ctx context.Context, w io.Writer, opts BulkOptions) (BulkProgress, error) {
+	if err := opts.init(t.cl); err != nil {
+		return BulkProgress{}, &RequestError{Op: "export", Key: t.name, Err: err}
+	}
+
+	var mutex sync.Mutex
+	var progress BulkProgress
+	var records []BulkRecord // of CSV exports
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	errs := make([]error, opts.Concurrency)
+	var wg sync.WaitGroup
+	for segment := 0; segment < opts.Concurrency; segment++ {
+		wg.Add(1)
+		go func(segment int) {
+			defer wg.Done()
+			scan := base.Scan{Segment: segment, TotalSegments: opts.Concurrency, Limit: opts.BatchSize}
+			for {
+				page, err := t.Scan(ctx, scan)
+				if err != nil {
+					errs[segment] = err
+					return
+				}
+				mutex.Lock()
+				for _, item := range page.Items {
+					record := BulkRecord{Key: item.Key, SortKey: item.SortKey, Value: item.Data, Item: item.Attrs}
+					if opts.Format == FormatCSV {
+						records = append(records, record)
+					} else if err := enc.Encode(&record); err != nil {
+						errs[segment] = err
+						mutex.Unlock()
+						return
+					}
+				}
+				progress.Items += len(page.Items)
+				if opts.Progress != nil {
+					opts.Progress(progress)
+				}
+				mutex.Unlock()
+				if page.LastKey == nil {
+					return
+				}
+				scan.StartKey = page.LastKey
+			}
+		}(segment)
+	}
+	wg.Wait()
+
+	err := errors.Join(errs...)
+	if err == nil && opts.Format == FormatCSV {
+		err = writeCSV(out, records)
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		return progress, &RequestError{Op: "export", Key: t.name, Attempts: 1, Err: err}
+	}
+	return progress, nil
+}
+
+func writeCSV(w io.Writer, records []BulkRecord) error {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Key != records[j].Key {
+			return records[i].Key < records[j].Key
+		}
+		return records[i].SortKey < records[j].SortKey
+	})
+	names := make(map[string]struct{})
+	for _, record := range records {
+		for name := range record.Item {
+			names[name] = struct{}{}
+		}
+	}
+	header := []string{csvKey, csvSortKey, csvValue}
+	attributes := make([]string, 0, len(names))
+	for name := range names {
+		if name == csvKey || name == csvSortKey || name == csvValue {
+			return fmt.Errorf("%w: attribute %q is named like a key column, export to JSON Lines", ErrInvalidRequest, name)
+		}
+		attributes = append(attributes, name)
+	}
+	sort.Strings(attributes)
+	header = append(header, attributes...)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := []string{record.Key, record.SortKey, record.Value}
+		for _, name := range attributes {
+			value, exists := record.Item[name]
+			switch {
+			case !exists:
+				row = append(row, "")
+			case value.Type() == "S" || value.Type() == "N" || value.Type() == "BOOL" || value.Type() == "NULL":
+				row = append(row, value.String())
+			default:
+				return fmt.Errorf("%w: attribute %q of %s is a %s, export to JSON Lines", ErrInvalidRequest, name, record.Key, value.Type())
+			}
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
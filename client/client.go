@@ -0,0 +1,341 @@
+package client
+
+import (
+	"base"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// job ids handed out by the library start here so they do not collide with the CLI's
+const firstJobId = 1_000_000
+
+// shared by every Client, nodes track quorum reads by job id
+var jobId atomic.Int64
+
+func init() {
+	jobId.Store(firstJobId)
+}
+
+func nextJobId() int {
+	return int(jobId.Add(1))
+}
+
+var (
+	// the coordinator and every fallback in its preference list failed to answer ALIVE_ACK
+	ErrNoCoordinator = errors.New("no coordinator in the preference list is alive")
+	// a coordinator accepted the request but did not reply in time
+	ErrTimeout = errors.New("request timed out")
+	// the coordinator has no live value for the key, the request is not retried
+	ErrNotFound = base.ErrKeyNotFound
+	// the condition of a conditional put did not hold, the request is not retried
+	ErrConditionFailed = base.ErrConditionFailed
+	// the update cannot be applied to the stored item, the request is not retried
+	ErrInvalidRequest = base.ErrInvalidRequest
+	// the table of the request does not exist, the request is not retried
+	ErrTableNotFound = base.ErrTableNotFound
+	// CreateTable was given the name of an existing table
+	ErrTableExists = base.ErrTableExists
+	// a condition of a transaction failed, a key was locked or an owner did not answer, nothing was written
+	ErrTransactionCanceled = base.ErrTransactionCanceled
+	// the key is locked by a transaction in progress, the request is not retried
+	ErrTransactionConflict = base.ErrTransactionConflict
+	// a shard iterator points before the oldest record retained by its stream, read again from TRIM_HORIZON
+	ErrTrimmedData = base.ErrTrimmedData
+)
+
+// Version is the vector clock of a value, as returned by GetVersion and expected by PutIfVersion
+type Version []int
+
+// Value is a value read from the store, Attrs is nil for plain values
+type Value struct {
+	Data    string
+	Attrs   base.Item
+	Version Version
+}
+
+// QueryPage is a page of items of a partition in the order of the query
+type QueryPage struct {
+	Items   []base.QueryItem
+	LastKey string // cursor of the next page, passed as Query.StartKey, empty on the last page
+}
+
+// ScanPage is a page of a scan segment in storage order
+type ScanPage struct {
+	Items   []base.QueryItem
+	LastKey *base.Key // cursor of the next page, passed as Scan.StartKey, nil on the last page
+}
+
+// RequestError records the operation and key of a failed request
+type RequestError struct {
+	Op       string
+	Key      string
+	Attempts int
+	Err      error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s(%s) failed after %d attempt(s): %s", e.Op, e.Key, e.Attempts, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+/*
+Client routes requests to the coordinator of a key, falling back along the
+preference list when the coordinator is down or does not reply in time.
+It is safe for concurrent use.
+*/
+type Client struct {
+	mutex     sync.Mutex
+	phy_nodes []*base.Node
+	c         *config.Config
+
+	// number of further coordinators tried after a request times out
+	Retries int
+}
+
+// New returns a client of the cluster of phy_nodes, with a copy of the settings c
+func New(phy_nodes []*base.Node, c *config.Config) *Client {
+	settings := *c
+	return &Client{phy_nodes: phy_nodes, c: &settings, Retries: 1}
+}
+
+// SetNodes points the client at a new set of nodes, e.g. after the CLI wipes the system
+func (cl *Client) SetNodes(phy_nodes []*base.Node) {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	cl.phy_nodes = phy_nodes
+}
+
+/*
+UpdateConfig sets the setting name of the cluster to value on every node and on the client, see base.SetConfig.
+Other clients of the cluster keep their settings until given the returned ones with SetConfig.
+*/
+func (cl *Client) UpdateConfig(name string, value int) (config.Config, error) {
+	updated, err := base.SetConfig(cl.nodes(), name, value)
+	if err != nil {
+		return updated, err
+	}
+	cl.SetConfig(updated)
+	return updated, nil
+}
+
+// SetConfig replaces the settings of the client, e.g. after the settings of the cluster are updated
+func (cl *Client) SetConfig(c config.Config) {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	cl.c = &c
+}
+
+// the settings are replaced, never modified, so they are safe to read once returned
+func (cl *Client) config() *config.Config {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	return cl.c
+}
+
+func (cl *Client) nodes() []*base.Node {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	return cl.phy_nodes
+}
+
+// Get returns the value stored under key, ErrNotFound if there is none
+func (cl *Client) Get(ctx context.Context, key string) (string, error) {
+	value, _, err := cl.GetVersion(ctx, key)
+	return value, err
+}
+
+// GetVersion returns the value stored under key along with its version
+func (cl *Client) GetVersion(ctx context.Context, key string) (string, Version, error) {
+	value, err := cl.Read(ctx, base.Key{Partition: key})
+	return value.Data, value.Version, err
+}
+
+// Read returns the plain value or item stored under key, ErrNotFound if there is none
+func (cl *Client) Read(ctx context.Context, key base.Key) (Value, error) {
+	return cl.Table("").Read(ctx, key)
+}
+
+// Put stores value under key, returns once W replicas acknowledged it
+func (cl *Client) Put(ctx context.Context, key string, value string) error {
+	_, err := cl.do(ctx, "put", base.Message{Key: key, Command: constants.CLIENT_REQ_WRITE, Data: value}, cl.config().CLIENT_PUT_TIMEOUT_MS)
+	return err
+}
+
+/*
+PutIfAbsent stores value under key unless it holds a live value, returns the version written.
+The check is atomic only against writes through the same coordinator, see Table.PutItemIfAbsent.
+*/
+func (cl *Client) PutIfAbsent(ctx context.Context, key string, value string) (Version, error) {
+	msg, err := cl.do(ctx, "put", base.Message{Key: key, Command: constants.CLIENT_REQ_WRITE, Data: value, Condition: constants.COND_NOT_EXISTS}, cl.config().CLIENT_PUT_TIMEOUT_MS)
+	return msg.Version, err
+}
+
+/*
+PutIfVersion stores value under key only if its current version equals version, returns the version written.
+The check is atomic only against writes through the same coordinator, see Table.PutItemIfAbsent.
+*/
+func (cl *Client) PutIfVersion(ctx context.Context, key string, value string, version Version) (Version, error) {
+	msg, err := cl.do(ctx, "put", base.Message{Key: key, Command: constants.CLIENT_REQ_WRITE, Data: value, Condition: constants.COND_VERSION_EQUALS, Version: version}, cl.config().CLIENT_PUT_TIMEOUT_MS)
+	return msg.Version, err
+}
+
+// GetItem returns the attributes of the item stored under key, nil if key holds a plain value
+func (cl *Client) GetItem(ctx context.Context, key base.Key) (base.Item, error) {
+	return cl.Table("").GetItem(ctx, key)
+}
+
+// PutItem replaces the item stored under key with attrs, invalid attributes return ErrInvalidRequest
+func (cl *Client) PutItem(ctx context.Context, key base.Key, attrs base.Item) error {
+	return cl.Table("").PutItem(ctx, key, attrs)
+}
+
+// PutItemIfAbsent stores the item attrs under key unless it holds a live value, returns the version written
+func (cl *Client) PutItemIfAbsent(ctx context.Context, key base.Key, attrs base.Item) (Version, error) {
+	return cl.Table("").PutItemIfAbsent(ctx, key, attrs)
+}
+
+// DeleteItem removes the item stored under key
+func (cl *Client) DeleteItem(ctx context.Context, key base.Key) error {
+	return cl.Table("").DeleteItem(ctx, key)
+}
+
+// Update applies actions to the item stored under key, see Table.Update
+func (cl *Client) Update(ctx context.Context, key base.Key, actions ...base.UpdateAction) (base.Item, error) {
+	return cl.Table("").Update(ctx, key, actions...)
+}
+
+// Query returns a page of the items of partitionKey, see Table.Query
+func (cl *Client) Query(ctx context.Context, partitionKey string, q base.Query) (QueryPage, error) {
+	return cl.Table("").Query(ctx, partitionKey, q)
+}
+
+// Scan returns a page of segment s.Segment of the default keyspace, see Table.Scan
+func (cl *Client) Scan(ctx context.Context, s base.Scan) (ScanPage, error) {
+	return cl.Table("").Scan(ctx, s)
+}
+
+// Set is an update action assigning value to the attribute name
+func Set(name string, value base.AttributeValue) base.UpdateAction {
+	return base.UpdateAction{Op: constants.UPDATE_SET, Name: name, Value: value}
+}
+
+// Remove is an update action deleting the attribute name
+func Remove(name string) base.UpdateAction {
+	return base.UpdateAction{Op: constants.UPDATE_REMOVE, Name: name}
+}
+
+// Add is an update action adding delta to the counter name, created at 0 if missing
+func Add(name string, delta int64) base.UpdateAction {
+	return base.UpdateAction{Op: constants.UPDATE_ADD, Name: name, Delta: delta}
+}
+
+// Delete removes key, returns once W replicas acknowledged the tombstone
+func (cl *Client) Delete(ctx context.Context, key string) error {
+	_, err := cl.do(ctx, "delete", base.Message{Key: key, Command: constants.CLIENT_REQ_DELETE}, cl.config().CLIENT_PUT_TIMEOUT_MS)
+	return err
+}
+
+/*
+ 1. Probe the coordinator of key with ALIVE_ACK, move down the preference list if it does not answer
+ 2. Send the request and wait for the reply
+ 3. On timeout or NACK, retry with the next node in the preference list up to Retries times.
+    The error of the last attempt is returned, a *base.NackError if the coordinator reported the failure.
+    A conditional put retried after a NACK may find its own partial write and fail its condition.
+*/
+func (cl *Client) do(ctx context.Context, op string, req base.Message, timeout_ms int) (base.Message, error) {
+	phy_nodes := cl.nodes()
+	key := base.RoutingKey(req.Table, req.Key) // items of a partition share the coordinator of their partition key
+	if req.Query != nil && req.Query.Index != "" {
+		key = base.IndexRoutingKey(req.Table, req.Query.Index, req.Key)
+	}
+	name := base.Key{Partition: req.Key, Sort: req.SortKey}.String()
+	if req.Table != "" {
+		name = req.Table + ":" + name
+	}
+	reply_ch := make(chan base.Message, 8) // buffered so late replies never block a node
+
+	token, node := base.FindNode(key, phy_nodes, cl.config())
+	attempts := 0
+	err := ErrNoCoordinator
+	var lastErr error // error of the last request sent
+
+	for cnt := 1; node != nil && attempts <= cl.Retries; cnt++ {
+		probeId := nextJobId()
+		err = send(ctx, node.GetChannel(), base.Message{JobId: probeId, Key: key, Command: constants.ALIVE_ACK, SrcID: -1, Client_Ch: reply_ch})
+		if err == nil {
+			_, err = await(ctx, reply_ch, probeId, cl.config().CLIENT_GET_TIMEOUT_MS)
+		}
+
+		if err == nil {
+			attempts++
+			reqId := nextJobId()
+			req.JobId, req.SrcID, req.Client_Ch = reqId, -1, reply_ch
+			var msg base.Message
+			reqErr := send(ctx, node.GetChannel(), req)
+			if reqErr == nil {
+				msg, reqErr = await(ctx, reply_ch, reqId, timeout_ms)
+			}
+			if reqErr == nil {
+				reqErr = base.ReplyError(msg)
+			}
+			if reqErr == nil {
+				return msg, nil
+			}
+			if ctx.Err() != nil || reqErr == ErrNotFound || reqErr == ErrConditionFailed || errors.Is(reqErr, ErrInvalidRequest) || errors.Is(reqErr, ErrTableNotFound) || errors.Is(reqErr, ErrTransactionCanceled) || errors.Is(reqErr, ErrTransactionConflict) {
+				return base.Message{}, &RequestError{Op: op, Key: name, Attempts: attempts, Err: reqErr}
+			}
+			lastErr = reqErr
+		} else if ctx.Err() != nil {
+			return base.Message{}, &RequestError{Op: op, Key: name, Attempts: attempts, Err: err}
+		} else {
+			err = ErrNoCoordinator
+		}
+
+		if cl.config().DEBUG_LEVEL >= constants.INFO {
+			fmt.Printf("client: %s(%s) node %d did not reply, looking for node handler...\n", op, key, node.GetID())
+		}
+		node = base.FindPrefList(token, phy_nodes, cnt)
+	}
+
+	if attempts > 0 {
+		err = lastErr
+	}
+	return base.Message{}, &RequestError{Op: op, Key: name, Attempts: attempts, Err: err}
+}
+
+// sends msg to a node, giving up if ctx is done first as the channel of a stalled node may be full
+func send(ctx context.Context, node_ch chan base.Message, msg base.Message) error {
+	select {
+	case node_ch <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func await(ctx context.Context, reply_ch chan base.Message, jobId int, timeout_ms int) (base.Message, error) {
+	timer := time.NewTimer(time.Duration(timeout_ms) * time.Millisecond)
+	defer timer.Stop()
+
+	for {
+		select {
+		case msg := <-reply_ch:
+			if msg.JobId == jobId {
+				return msg, nil
+			}
+		case <-timer.C:
+			return base.Message{}, ErrTimeout
+		case <-ctx.Done():
+			return base.Message{}, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package client
+
+import "base"
+
+// DescribeStream returns the shards of the stream of table that recorded changes
+func (cl *Client) DescribeStream(table string) ([]base.StreamShard, error) {
+	return base.DescribeStream(cl.nodes(), table)
+}
+
+/*
+GetShardIterator returns an iterator of a shard of the stream of table, at one of the positions constants.ITERATOR_*.
+sequenceNumber is used by AT_SEQUENCE_NUMBER and AFTER_SEQUENCE_NUMBER only.
+*/
+func (cl *Client) GetShardIterator(table string, shardId string, iteratorType string, sequenceNumber int64) (string, error) {
+	return base.GetShardIterator(cl.nodes(), table, shardId, iteratorType, sequenceNumber)
+}
+
+/*
+GetRecords returns up to limit records after iterator, 0 for all of them, in write order per partition.
+Pass page.NextIterator to read the records written after them, ErrTrimmedData means records were lost to the retention.
+*/
+func (cl *Client) GetRecords(iterator string, limit int) (base.StreamPage, error) {
+	return base.GetRecords(cl.nodes(), iterator, limit)
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"base"
+	"constants"
+	"context"
+	"fmt"
+)
+
+/*
+Table is a handle on the items of a named table, or of the default keyspace for the name "".
+Requests are replicated with the N, R and W of the table, a table deleted since the handle was made
+returns ErrTableNotFound.
+*/
+type Table struct {
+	cl   *Client
+	name string
+}
+
+// Table returns a handle on the table name, the table need not exist yet
+func (cl *Client) Table(name string) *Table {
+	return &Table{cl: cl, name: name}
+}
+
+func (t *Table) Name() string {
+	return t.name
+}
+
+// CreateTable adds the table t, zero N, R and W default to the cluster's, returns its settings
+func (cl *Client) CreateTable(t base.Table) (base.Table, error) {
+	return base.CreateTable(cl.nodes(), t, cl.config())
+}
+
+// DeleteTable removes the table name along with its items
+func (cl *Client) DeleteTable(name string) (base.Table, error) {
+	return base.DeleteTable(cl.nodes(), name)
+}
+
+// DescribeTable returns the settings of the table name
+func (cl *Client) DescribeTable(name string) (base.Table, error) {
+	return base.DescribeTable(cl.nodes(), name)
+}
+
+// DescribeIndex returns the index entry updates of the index name of table still in flight, and the age of the oldest
+func (cl *Client) DescribeIndex(table string, name string) (base.IndexStatus, error) {
+	return base.DescribeIndex(cl.nodes(), table, name)
+}
+
+// ListTables returns the names of the tables in order
+func (cl *Client) ListTables() []string {
+	return base.ListTables(cl.nodes())
+}
+
+// Read returns the plain value or item stored under key, ErrNotFound if there is none or it has expired
+func (t *Table) Read(ctx context.Context, key base.Key) (Value, error) {
+	msg, err := t.cl.do(ctx, "get", base.Message{Table: t.name, Key: key.Partition, SortKey: key.Sort, Command: constants.CLIENT_REQ_READ}, t.cl.config().CLIENT_GET_TIMEOUT_MS)
+	return Value{Data: msg.Data, Attrs: msg.Attrs, Version: msg.Version}, err
+}
+
+// GetItem returns the attributes of the item stored under key, nil if key holds a plain value
+func (t *Table) GetItem(ctx context.Context, key base.Key) (base.Item, error) {
+	value, err := t.Read(ctx, key)
+	return value.Attrs, err
+}
+
+// PutItem replaces the item stored under key with attrs, invalid attributes return ErrInvalidRequest
+func (t *Table) PutItem(ctx context.Context, key base.Key, attrs base.Item) error {
+	_, err := t.cl.do(ctx, "put", t.itemRequest(key, attrs, constants.COND_NONE), t.cl.config().CLIENT_PUT_TIMEOUT_MS)
+	return err
+}
+
+/*
+PutItemIfAbsent stores the item attrs under key unless it holds a live value, returns the version written.
+The coordinator locks the key from the check until W replicas hold the write, against the writes it coordinates
+only: writes through a fallback coordinator while it is down or slow can pass the same check concurrently.
+*/
+func (t *Table) PutItemIfAbsent(ctx context.Context, key base.Key, attrs base.Item) (Version, error) {
+	return t.PutItemIf(ctx, key, attrs, constants.COND_NOT_EXISTS, nil)
+}
+
+/*
+PutItemIf stores the item attrs under key only if condition, one of constants.COND_*, holds on the value
+stored under it, returns the version written. COND_VERSION_EQUALS compares the stored version with version.
+A failed condition returns ErrConditionFailed, the check is atomic as in PutItemIfAbsent.
+*/
+func (t *Table) PutItemIf(ctx context.Context, key base.Key, attrs base.Item, condition int, version Version) (Version, error) {
+	msg := t.itemRequest(key, attrs, condition)
+	msg.Version = version
+	reply, err := t.cl.do(ctx, "put", msg, t.cl.config().CLIENT_PUT_TIMEOUT_MS)
+	return reply.Version, err
+}
+
+// DeleteItem removes the item stored under key
+func (t *Table) DeleteItem(ctx context.Context, key base.Key) error {
+	return t.DeleteItemIf(ctx, key, constants.COND_NONE, nil)
+}
+
+// DeleteItemIf removes the item stored under key only if condition holds on it, see PutItemIf
+func (t *Table) DeleteItemIf(ctx context.Context, key base.Key, condition int, version Version) error {
+	_, err := t.cl.do(ctx, "delete", base.Message{Table: t.name, Key: key.Partition, SortKey: key.Sort, Command: constants.CLIENT_REQ_DELETE, Condition: condition, Version: version}, t.cl.config().CLIENT_PUT_TIMEOUT_MS)
+	return err
+}
+
+func (t *Table) itemRequest(key base.Key, attrs base.Item, condition int) base.Message {
+	if attrs == nil {
+		attrs = base.Item{} // an empty item is still an item
+	}
+	return base.Message{Table: t.name, Key: key.Partition, SortKey: key.Sort, Command: constants.CLIENT_REQ_WRITE, Attrs: attrs, Condition: condition}
+}
+
+/*
+Update applies actions to the item stored under key at its coordinator and returns the updated item.
+Updates are atomic only against writes through the same coordinator, see PutItemIfAbsent.
+Counters incremented by Add on different coordinators merge without losing increments.
+An update retried after a NACK may be applied twice.
+*/
+func (t *Table) Update(ctx context.Context, key base.Key, actions ...base.UpdateAction) (base.Item, error) {
+	return t.UpdateIf(ctx, key, constants.COND_NONE, nil, actions...)
+}
+
+// UpdateIf applies actions to the item stored under key only if condition holds on it, see PutItemIf and Update
+func (t *Table) UpdateIf(ctx context.Context, key base.Key, condition int, version Version, actions ...base.UpdateAction) (base.Item, error) {
+	msg, err := t.cl.do(ctx, "update", base.Message{Table: t.name, Key: key.Partition, SortKey: key.Sort, Command: constants.CLIENT_REQ_UPDATE, Update: actions, Condition: condition, Version: version}, t.cl.config().CLIENT_PUT_TIMEOUT_MS)
+	return msg.Attrs, err
+}
+
+/*
+Query returns the items of partitionKey whose sort key satisfies q.Condition, in sort key order or its
+reverse, read from R replicas. Pages hold at most q.Limit items, the next page starts after LastKey.
+If q.Index is set, partitionKey is a value of the index partition attribute and the items are read from
+the index, which may lag behind the writes acknowledged by the table.
+*/
+func (t *Table) Query(ctx context.Context, partitionKey string, q base.Query) (QueryPage, error) {
+	msg, err := t.cl.do(ctx, "query", base.Message{Table: t.name, Key: partitionKey, Command: constants.CLIENT_REQ_QUERY, Query: &q}, t.cl.config().CLIENT_GET_TIMEOUT_MS)
+	return QueryPage{Items: msg.Items, LastKey: msg.LastKey}, err
+}
+
+/*
+Scan returns a page of the live items of segment s.Segment of s.TotalSegments, each read from R replicas.
+Segments are disjoint and can be scanned in parallel, the next page starts after LastKey.
+Pages are coordinated by a node picked from the segment number, a page reads every token range
+overlapping the segment so its timeout is CLIENT_GET_TIMEOUT_MS per token.
+*/
+func (t *Table) Scan(ctx context.Context, s base.Scan) (ScanPage, error) {
+	req := base.Message{Table: t.name, Key: fmt.Sprintf("segment-%d", s.Segment), Command: constants.CLIENT_REQ_SCAN, Scan: &s}
+	msg, err := t.cl.do(ctx, "scan", req, t.cl.config().CLIENT_GET_TIMEOUT_MS*(t.cl.config().NUM_TOKENS+1))
+	page := ScanPage{Items: msg.Items}
+	if msg.LastKey != "" && len(msg.Items) > 0 {
+		last := msg.Items[len(msg.Items)-1]
+		page.LastKey = &base.Key{Partition: last.Key, Sort: last.SortKey}
+	}
+	return page, err
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"base"
+	"constants"
+	"context"
+	"fmt"
+)
+
+/*
+TransactItem is a write or condition check of TransactWrite on the item under its key.
+Op is one of constants.CLIENT_REQ_WRITE, CLIENT_REQ_DELETE, CLIENT_REQ_UPDATE and CLIENT_REQ_CHECK.
+Writes put Item, the plain Value if Item is nil, and updates apply Update.
+*/
+type TransactItem struct {
+	BatchKey
+	Op        int
+	Item      base.Item
+	Value     string
+	Update    []base.UpdateAction
+	Condition int     // constants.COND_*, checked on the value before anything is written
+	Version   Version // expected by COND_VERSION_EQUALS
+}
+
+/*
+TransactWrite applies up to BATCH_MAX_KEYS items of any tables all or nothing, see Node.Transact.
+A failed condition, a key locked by another transaction or an owner that does not answer cancels the
+transaction with a *base.TransactionCanceledError, matched by ErrTransactionCanceled and by the error of
+the items at fault. Canceled transactions are not retried, a transaction retried after a timeout may be
+applied twice unless its conditions prevent it.
+*/
+func (cl *Client) TransactWrite(ctx context.Context, items []TransactItem) error {
+	keys := make([]BatchKey, len(items))
+	reqs := make([]base.Message, len(items))
+	for i, item := range items {
+		keys[i] = item.BatchKey
+		reqs[i] = base.Message{Table: item.Table, Key: item.Key.Partition, SortKey: item.Key.Sort, Command: item.Op, Condition: item.Condition, Version: item.Version, Update: item.Update}
+		switch item.Op {
+		case constants.CLIENT_REQ_WRITE:
+			if item.Item == nil {
+				reqs[i].Data = item.Value
+				break
+			}
+			if err := item.Item.Validate(); err != nil {
+				return &RequestError{Op: "transact write", Key: item.BatchKey.String(), Err: fmt.Errorf("%w: %s", ErrInvalidRequest, err)}
+			}
+			reqs[i].Attrs = item.Item
+		case constants.CLIENT_REQ_DELETE, constants.CLIENT_REQ_UPDATE, constants.CLIENT_REQ_CHECK:
+		default:
+			return &RequestError{Op: "transact write", Key: item.BatchKey.String(), Err: fmt.Errorf("%w: %s is not a transaction item", ErrInvalidRequest, constants.GetConstantString(item.Op))}
+		}
+	}
+	if err := cl.checkBatch("transact write", keys); err != nil {
+		return err
+	}
+
+	first := reqs[0] // routes the transaction to the coordinator of its first key
+	_, err := cl.do(ctx, "transact write", base.Message{Table: first.Table, Key: first.Key, SortKey: first.SortKey, Command: constants.CLIENT_REQ_TRANSACT, Batch: reqs}, cl.config().CLIENT_GET_TIMEOUT_MS+cl.config().CLIENT_PUT_TIMEOUT_MS)
+	return err
+}
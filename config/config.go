@@ -1,15 +1,22 @@
 package config
 
+/*
+Config holds the settings of a cluster. Every field is an integer setting that can be loaded from a
+config file, an environment variable or a command-line flag, see RegisterFlags. New fields need a usage tag,
+and a fixed tag if they cannot change on a live cluster, see Update.
+*/
 type Config struct {
-	NUM_NODES             int
-	NUM_TOKENS            int
-	CLIENT_GET_TIMEOUT_MS int
-	CLIENT_PUT_TIMEOUT_MS int
-	SET_DATA_TIMEOUT_MS   int
-	W                     int
-	R                     int
-	N                     int
-	DEBUG_LEVEL           int
+	NUM_NODES             int `usage:"number of physical nodes" fixed:"true"`
+	NUM_TOKENS            int `usage:"number of tokens (virtual nodes)" fixed:"true"`
+	CLIENT_GET_TIMEOUT_MS int `usage:"client timeout of reads in milliseconds"`
+	CLIENT_PUT_TIMEOUT_MS int `usage:"client timeout of writes in milliseconds"`
+	SET_DATA_TIMEOUT_MS   int `usage:"timeout of replication requests in milliseconds"`
+	W                     int `usage:"replicas written before a write is acknowledged"`
+	R                     int `usage:"replicas read before a read is answered"`
+	N                     int `usage:"replicas of every key" fixed:"true"`
+	DEBUG_LEVEL           int `usage:"debug level, see constants.NO_DEBUG to VERY_VERBOSE"`
+	BATCH_MAX_KEYS        int `usage:"keys accepted by a batch get or batch write"`
+	STREAM_RETENTION_MS   int `usage:"age in milliseconds after which the records of table streams are trimmed"`
 }
 
 // Instantiate config object with default values
@@ -24,6 +31,8 @@ func InstantiateConfig() Config {
 		CLIENT_PUT_TIMEOUT_MS: CLIENT_PUT_TIMEOUT_MS,
 		SET_DATA_TIMEOUT_MS:   SET_DATA_TIMEOUT_MS,
 		DEBUG_LEVEL:           DEBUG_LEVEL,
+		BATCH_MAX_KEYS:        BATCH_MAX_KEYS,
+		STREAM_RETENTION_MS:   STREAM_RETENTION_MS,
 	}
 
 	return c
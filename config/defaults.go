@@ -12,6 +12,10 @@ const (
 	CLIENT_PUT_TIMEOUT_MS = 2000
 	SET_DATA_TIMEOUT_MS   = 1000 // 1 second
 
+	BATCH_MAX_KEYS = 100
+
+	STREAM_RETENTION_MS = 24 * 60 * 60 * 1000 // 24 hours
+
 	// see constants.go for description
 	DEBUG_LEVEL = 3
 )
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// prefix of the environment variables overriding settings, e.g. DYNAMO_NUM_NODES
+const EnvPrefix = "DYNAMO_"
+
+/* A setting of Config, named as its field */
+type setting struct {
+	name  string
+	usage string
+	field int  // index in Config
+	fixed bool // shapes the ring, set before the cluster starts
+}
+
+var settings []setting
+
+func init() {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		usage := t.Field(i).Tag.Get("usage")
+		if usage == "" {
+			usage = t.Field(i).Name
+		}
+		settings = append(settings, setting{name: t.Field(i).Name, usage: usage, field: i, fixed: t.Field(i).Tag.Get("fixed") == "true"})
+	}
+}
+
+// Returns the setting name, matched case-insensitively, False if Config has none
+func lookupSetting(name string) (setting, bool) {
+	for _, s := range settings {
+		if strings.EqualFold(s.name, name) {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func (c *Config) get(s setting) int64 {
+	return reflect.ValueOf(c).Elem().Field(s.field).Int()
+}
+
+func (c *Config) set(s setting, value int) {
+	reflect.ValueOf(c).Elem().Field(s.field).SetInt(int64(value))
+}
+
+// Sets the setting name to the integer value
+func (c *Config) setString(name string, value string) error {
+	s, exists := lookupSetting(name)
+	if !exists {
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("setting %s: %q is not an integer", s.name, value)
+	}
+	c.set(s, n)
+	return nil
+}
+
+// FileFormats describes the config files read by LoadFile, as given in the usage of -config
+const FileFormats = `.json, or .yaml, .yml and .toml of flat "NAME: value" and "NAME = value" lines`
+
+// a flat line of a YAML (:) or TOML (=) file, the value possibly quoted and followed by a comment
+var lineRegexes = map[string]*regexp.Regexp{
+	":": regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)[ \t]*:[ \t]*(?:"([^"]*)"|'([^']*)'|([^\s#"'|>\[{&*!][^#]*?))[ \t]*(?:#.*)?$`),
+	"=": regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)[ \t]*=[ \t]*(?:"([^"]*)"|'([^']*)'|([^\s#"'\[{][^#]*?))[ \t]*(?:#.*)?$`),
+}
+
+/*
+LoadFile sets the settings found in the file path, by its extension: a JSON object (.json), YAML (.yaml, .yml)
+or TOML (.toml). Settings are integers named as the fields of Config, in any case. YAML and TOML files are not
+decoded in full, only flat "name: value" and "name = value" lines are accepted, values may be quoted and # starts
+a comment. Sections, indented lines, lists and multi-line values are rejected with their file:line.
+*/
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = c.loadJSON(data)
+	case ".yaml", ".yml":
+		return c.loadLines(path, data, ":")
+	case ".toml":
+		return c.loadLines(path, data, "=")
+	default:
+		err = fmt.Errorf("unknown config format %q, expected %s", ext, FileFormats)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadJSON(data []byte) error {
+	var values map[string]json.Number
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return err
+	}
+	for name, value := range values {
+		if err := c.setString(name, value.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads the lines of name, separator and value of the file path, errors name the file and line
+func (c *Config) loadLines(path string, data []byte, separator string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || (separator == ":" && text == "---") {
+			continue
+		}
+		matches := lineRegexes[separator].FindStringSubmatch(text)
+		if matches == nil {
+			return fmt.Errorf("%s:%d: %s", path, line, unsupportedLine(text, separator))
+		}
+		if err := c.setString(matches[1], matches[2]+matches[3]+matches[4]); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Returns why text is not a flat line of name, separator and value
+func unsupportedLine(text string, separator string) string {
+	trimmed := strings.TrimSpace(text)
+	_, value, found := strings.Cut(trimmed, separator)
+	value = strings.TrimSpace(value)
+	switch {
+	case text[0] == ' ' || text[0] == '\t':
+		return "indented lines are not supported, settings must be flat"
+	case strings.HasPrefix(trimmed, "["):
+		return fmt.Sprintf("sections are not supported, got %q", trimmed)
+	case trimmed == "-" || strings.HasPrefix(trimmed, "- "):
+		return "lists are not supported"
+	case found && (value == "" || strings.HasPrefix(value, "#") || strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") || strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''")):
+		return "multi-line values are not supported"
+	case found && (strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'")):
+		return fmt.Sprintf("quoted values must end on their line and be followed by a comment only, got %s", value)
+	case found && (strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{")):
+		return "lists and tables are not supported as values"
+	}
+	return fmt.Sprintf("expected name %s value, got %q", separator, trimmed)
+}
+
+// LoadEnv sets the settings found in environment variables named EnvPrefix and the setting, e.g. DYNAMO_N
+func (c *Config) LoadEnv(lookupEnv func(string) (string, bool)) error {
+	for _, s := range settings {
+		if value, exists := lookupEnv(EnvPrefix + s.name); exists {
+			if err := c.setString(s.name, value); err != nil {
+				return fmt.Errorf("%s%s: %w", EnvPrefix, s.name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+)
+
+/* Flags holds the command-line flags of the settings and of the config file, registered by RegisterFlags */
+type Flags struct {
+	File   string
+	values map[string]int // settings given on the command line, by name
+}
+
+/*
+RegisterFlags registers on fs a flag per setting, named as the setting in lower case (e.g. -num_nodes, -n),
+and -config naming a config file.
+*/
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{values: make(map[string]int)}
+	fs.StringVar(&f.File, "config", "", "config file of the settings, "+FileFormats)
+	for _, s := range settings {
+		s := s
+		fs.Func(strings.ToLower(s.name), s.usage, func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			f.values[s.name] = n
+			return nil
+		})
+	}
+	return f
+}
+
+/*
+Load sets the settings of c from the config file, then the environment variables, then the flags given, each
+overriding the previous ones, and returns the errors of Validate. Returns False if no source set anything, so
+the caller may ask for the settings instead.
+*/
+func (f *Flags) Load(c *Config, lookupEnv func(string) (string, bool)) (bool, error) {
+	configured := f.File != "" || len(f.values) > 0
+	if f.File != "" {
+		if err := c.LoadFile(f.File); err != nil {
+			return true, err
+		}
+	}
+	for _, s := range settings {
+		if _, exists := lookupEnv(EnvPrefix + s.name); exists {
+			configured = true
+		}
+	}
+	if err := c.LoadEnv(lookupEnv); err != nil {
+		return true, err
+	}
+	for _, s := range settings {
+		if value, exists := f.values[s.name]; exists {
+			c.set(s, value)
+		}
+	}
+	if !configured {
+		return false, nil
+	}
+	return true, c.Validate().Err()
+}
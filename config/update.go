@@ -0,0 +1,30 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// the setting shapes the ring of a live cluster, NUM_NODES, NUM_TOKENS and N
+var ErrNotLive = errors.New("setting fixed on a live cluster")
+
+/*
+Update returns a copy of c with the setting name, matched case-insensitively, set to value, for a live
+cluster. Settings tagged fixed fail with ErrNotLive, and values leaving the copy with errors of Validate
+are rejected, c is never modified.
+*/
+func (c *Config) Update(name string, value int) (Config, error) {
+	s, exists := lookupSetting(name)
+	if !exists {
+		return *c, fmt.Errorf("unknown setting %q", name)
+	}
+	if s.fixed {
+		return *c, fmt.Errorf("%w: %s only changes on restart", ErrNotLive, s.name)
+	}
+	updated := *c
+	updated.set(s, value)
+	if err := updated.Validate().Err(); err != nil {
+		return *c, fmt.Errorf("%s=%d: %w", s.name, value, err)
+	}
+	return updated, nil
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// kinds of Problem, matched with errors.Is
+var (
+	// a count or timeout is zero or negative, or DEBUG_LEVEL is negative
+	ErrNotPositive = errors.New("setting out of range")
+	// N is larger than NUM_NODES, keys cannot have N replicas on distinct nodes
+	ErrNAboveNodes = errors.New("N above NUM_NODES")
+	// R or W is larger than N, a quorum cannot be reached among the replicas of a key
+	ErrQuorumAboveN = errors.New("quorum above N")
+
+	// warning: R + W is at most N, a read may miss the latest write
+	WarnWeakConsistency = errors.New("no strong consistency")
+	// warning: N is larger than NUM_TOKENS, keys are replicated to at most NUM_TOKENS nodes
+	WarnNAboveTokens = errors.New("N above NUM_TOKENS")
+	// warning: SET_DATA_TIMEOUT_MS is not below CLIENT_PUT_TIMEOUT_MS, clients give up on writes whose replicas are handed off
+	WarnReplicationTimeout = errors.New("replication timeout above client timeout")
+)
+
+/* Problem is a setting of Config that is invalid, or valid with a caveat if Warning is set */
+type Problem struct {
+	Kind    error  // one of the Err* and Warn* errors
+	Setting string // field of Config at fault
+	Warning bool
+	Message string
+}
+
+func (p Problem) Error() string {
+	if p.Warning {
+		return "warning: " + p.Message
+	}
+	return p.Message
+}
+
+func (p Problem) Unwrap() error {
+	return p.Kind
+}
+
+/* Problems are the problems of a Config, in setting order */
+type Problems []Problem
+
+// Err returns the problems that are not warnings joined, nil if there are none
+func (ps Problems) Err() error {
+	var errs []error
+	for _, p := range ps {
+		if !p.Warning {
+			errs = append(errs, p)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Warnings returns the problems that are warnings
+func (ps Problems) Warnings() Problems {
+	var warnings Problems
+	for _, p := range ps {
+		if p.Warning {
+			warnings = append(warnings, p)
+		}
+	}
+	return warnings
+}
+
+// Of returns the problems of the setting name
+func (ps Problems) Of(name string) Problems {
+	var of Problems
+	for _, p := range ps {
+		if p.Setting == name {
+			of = append(of, p)
+		}
+	}
+	return of
+}
+
+func (ps Problems) String() string {
+	messages := make([]string, len(ps))
+	for i, p := range ps {
+		messages[i] = p.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+/*
+Validate returns the problems of the settings. Errors: counts and timeouts that are not positive, N above
+NUM_NODES, R or W above N. Warnings: R + W at most N, N above NUM_TOKENS and replication requests timing out
+no sooner than client writes. The nodes clamp N, R and W into range, so a config with errors still runs, but
+not as configured, and base.CreateNodes prints its errors.
+*/
+func (c *Config) Validate() Problems {
+	var ps Problems
+	add := func(kind error, setting string, warning bool, format string, args ...any) {
+		ps = append(ps, Problem{Kind: kind, Setting: setting, Warning: warning, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, s := range settings {
+		value := int(c.get(s))
+		if s.name == "DEBUG_LEVEL" {
+			if value < 0 {
+				add(ErrNotPositive, s.name, false, "DEBUG_LEVEL must not be negative, got %d", value)
+			}
+		} else if value <= 0 {
+			add(ErrNotPositive, s.name, false, "%s must be positive, got %d", s.name, value)
+		}
+
+		switch s.name {
+		case "N":
+			if c.N > c.NUM_NODES {
+				add(ErrNAboveNodes, s.name, false, "N must be at most NUM_NODES=%d, got %d", c.NUM_NODES, c.N)
+			} else if c.N > c.NUM_TOKENS && c.NUM_TOKENS > 0 {
+				add(WarnNAboveTokens, s.name, true, "N=%d is above NUM_TOKENS=%d, keys are replicated to %d nodes", c.N, c.NUM_TOKENS, c.NUM_TOKENS)
+			}
+		case "R", "W":
+			if value > c.N {
+				add(ErrQuorumAboveN, s.name, false, "%s must be at most N=%d, got %d", s.name, c.N, value)
+			}
+		}
+	}
+	if c.R <= c.N && c.W <= c.N && c.R+c.W <= c.N && c.R > 0 && c.W > 0 {
+		add(WarnWeakConsistency, "W", true, "R+W=%d is at most N=%d, reads may miss the latest write", c.R+c.W, c.N)
+	}
+	if c.SET_DATA_TIMEOUT_MS >= c.CLIENT_PUT_TIMEOUT_MS && c.CLIENT_PUT_TIMEOUT_MS > 0 {
+		add(WarnReplicationTimeout, "SET_DATA_TIMEOUT_MS", true, "SET_DATA_TIMEOUT_MS=%d is not below CLIENT_PUT_TIMEOUT_MS=%d, writes handed off time out at the client",
+			c.SET_DATA_TIMEOUT_MS, c.CLIENT_PUT_TIMEOUT_MS)
+	}
+	return ps
+}
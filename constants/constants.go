@@ -6,14 +6,30 @@ const (
 	VERBOSE_FIXED = 3
 	VERY_VERBOSE  = 4
 
-	CLIENT_REQ_READ   = 100
-	CLIENT_REQ_WRITE  = 101
-	CLIENT_REQ_KILL   = 102
-	CLIENT_REQ_REVIVE = 103
+	CLIENT_REQ_READ     = 100
+	CLIENT_REQ_WRITE    = 101
+	CLIENT_REQ_KILL     = 102
+	CLIENT_REQ_REVIVE   = 103
+	CLIENT_REQ_DELETE   = 104
+	CLIENT_REQ_UPDATE   = 105
+	CLIENT_REQ_QUERY    = 106
+	CLIENT_REQ_SCAN     = 107
+	CLIENT_REQ_BATCH    = 108 // requests of keys sharing a coordinator, served in parallel
+	CLIENT_REQ_TRANSACT = 109 // writes applied all or nothing by a two-phase commit
+	CLIENT_REQ_CHECK    = 110 // condition check of a transaction, writes nothing
 
-	CLIENT_ACK_READ  = 200
-	CLIENT_ACK_WRITE = 201
-	CLIENT_ACK_ALIVE = 202
+	CLIENT_ACK_READ      = 200
+	CLIENT_ACK_WRITE     = 201
+	CLIENT_ACK_ALIVE     = 202
+	CLIENT_NACK_READ     = 203 // read quorum not reached
+	CLIENT_NACK_WRITE    = 204 // write quorum not reached
+	KEY_NOT_FOUND        = 205
+	CONDITION_FAILED     = 206 // conditional write check failed
+	INVALID_REQUEST      = 207 // update cannot be applied to the stored value
+	TABLE_NOT_FOUND      = 208 // the table of the request does not exist
+	CLIENT_ACK_BATCH     = 209 // replies of the requests of a CLIENT_REQ_BATCH, in order
+	TRANSACTION_CANCELED = 210 // the transaction was aborted, the reply of every item says why
+	TRANSACTION_CONFLICT = 211 // the key is locked by a transaction in progress
 
 	SET_DATA  = 300
 	BACK_DATA = 301
@@ -24,7 +40,66 @@ const (
 	READ_DATA     = 500
 	READ_DATA_ACK = 501
 
+	QUERY_DATA     = 502
+	QUERY_DATA_ACK = 503
+	SCAN_DATA      = 504
+	SCAN_DATA_ACK  = 505
+
 	ALIVE_ACK = 600
+
+	TX_PREPARE     = 700 // lock a key for a transaction and return its value
+	TX_PREPARE_ACK = 701 // vote on TX_PREPARE, Reason is set if the key is locked by another transaction
+	TX_COMMIT      = 702 // apply the write of a committed transaction and release its lock
+	TX_COMMIT_ACK  = 703
+	TX_ABORT       = 704 // release the lock of an aborted transaction
+	TX_STATUS      = 705 // ask the coordinator of a transaction for its outcome, answered with TX_COMMIT or TX_ABORT
+)
+
+// actions of an update
+const (
+	UPDATE_SET    = "SET"
+	UPDATE_REMOVE = "REMOVE"
+	UPDATE_ADD    = "ADD"
+)
+
+// sort key conditions of a query, sort keys compare as strings
+const (
+	QUERY_EQ          = "="
+	QUERY_LT          = "<"
+	QUERY_LE          = "<="
+	QUERY_GT          = ">"
+	QUERY_GE          = ">="
+	QUERY_BETWEEN     = "BETWEEN" // inclusive of both bounds
+	QUERY_BEGINS_WITH = "begins_with"
+)
+
+// conflict resolution of a table, how concurrent versions of an item are reconciled
+const (
+	CONFLICT_VECTOR_CLOCK     = "VECTOR_CLOCK"     // the version read first is kept, counters merge
+	CONFLICT_LAST_WRITER_WINS = "LAST_WRITER_WINS" // the version written last by wall clock wins
+)
+
+// events of a table stream, recorded by the coordinator of a write
+const (
+	STREAM_INSERT = "INSERT" // the item was absent
+	STREAM_MODIFY = "MODIFY"
+	STREAM_REMOVE = "REMOVE"
+)
+
+// positions of a shard iterator of a table stream
+const (
+	ITERATOR_TRIM_HORIZON          = "TRIM_HORIZON" // the oldest record retained
+	ITERATOR_LATEST                = "LATEST"       // records written after the iterator
+	ITERATOR_AT_SEQUENCE_NUMBER    = "AT_SEQUENCE_NUMBER"
+	ITERATOR_AFTER_SEQUENCE_NUMBER = "AFTER_SEQUENCE_NUMBER"
+)
+
+// conditions of a conditional write, evaluated on the value reconciled from R replicas
+const (
+	COND_NONE           = 0
+	COND_NOT_EXISTS     = 1 // attribute_not_exists, the key is absent or deleted
+	COND_VERSION_EQUALS = 2 // the vector clock of the live value equals the expected version
+	COND_EXISTS         = 3 // attribute_exists, the key holds a live value
 )
 
 func GetConstantString(c int) string {
@@ -46,11 +121,45 @@ func GetConstantString(c int) string {
 		return "CLIENT_REQ_KILL"
 	case 103:
 		return "CLIENT_REQ_REVIVE"
+	case 104:
+		return "CLIENT_REQ_DELETE"
+	case 105:
+		return "CLIENT_REQ_UPDATE"
+	case 106:
+		return "CLIENT_REQ_QUERY"
+	case 107:
+		return "CLIENT_REQ_SCAN"
+	case 108:
+		return "CLIENT_REQ_BATCH"
+	case 109:
+		return "CLIENT_REQ_TRANSACT"
+	case 110:
+		return "CLIENT_REQ_CHECK"
 
 	case 200:
 		return "CLIENT_ACK_READ"
 	case 201:
 		return "CLIENT_ACK_WRITE"
+	case 202:
+		return "CLIENT_ACK_ALIVE"
+	case 203:
+		return "CLIENT_NACK_READ"
+	case 204:
+		return "CLIENT_NACK_WRITE"
+	case 205:
+		return "KEY_NOT_FOUND"
+	case 206:
+		return "CONDITION_FAILED"
+	case 207:
+		return "INVALID_REQUEST"
+	case 208:
+		return "TABLE_NOT_FOUND"
+	case 209:
+		return "CLIENT_ACK_BATCH"
+	case 210:
+		return "TRANSACTION_CANCELED"
+	case 211:
+		return "TRANSACTION_CONFLICT"
 
 	case 300:
 		return "SET_DATA\t"
@@ -66,6 +175,14 @@ func GetConstantString(c int) string {
 		return "READ_DATA\t"
 	case 501:
 		return "READ_DATA_ACK"
+	case 502:
+		return "QUERY_DATA\t"
+	case 503:
+		return "QUERY_DATA_ACK"
+	case 504:
+		return "SCAN_DATA\t"
+	case 505:
+		return "SCAN_DATA_ACK"
 
 	case 600:
 		return "ALIVE_ACK"
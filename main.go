@@ -1,23 +1,34 @@
 package main
 
 import (
+	"api"
 	"base"
 	"bufio"
+	"client"
 	"config"
 	"constants"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"math/rand"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
+	"rpc"
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
 var wg sync.WaitGroup
 
+// directory of the backups taken by backup(name), relative to the working directory
+const backupDir = "backups"
+
 func SetConfigs(c *config.Config, reader *bufio.Reader) {
 	fmt.Println("Start System Configuration")
 
@@ -29,12 +40,14 @@ func SetConfigs(c *config.Config, reader *bufio.Reader) {
 	}{
 		{"NUM_NODES", fmt.Sprintf("Set number of physical nodes (default: %d): ", config.NUM_NODES), func(val int) { c.NUM_NODES = val }, config.NUM_NODES},
 		{"NUM_TOKENS", fmt.Sprintf("Set number of tokens (default: %d): ", config.NUM_TOKENS), func(val int) { c.NUM_TOKENS = val }, config.NUM_TOKENS},
-		{"GET_TIMEOUT", fmt.Sprintf("Set number of CLIENT_GET_TIMEOUT in milliseconds (default: %d): ", config.CLIENT_GET_TIMEOUT_MS), func(val int) { c.CLIENT_GET_TIMEOUT_MS = val }, config.CLIENT_GET_TIMEOUT_MS},
-		{"PUT_TIMEOUT", fmt.Sprintf("Set number of CLIENT_PUT_TIMEOUT in milliseconds (default: %d): ", config.CLIENT_PUT_TIMEOUT_MS), func(val int) { c.CLIENT_PUT_TIMEOUT_MS = val }, config.CLIENT_PUT_TIMEOUT_MS},
-		{"DATA_TIMEOUT", fmt.Sprintf("Set number of SET_DATA_TIMEOUT in ms (default: %d): ", config.SET_DATA_TIMEOUT_MS), func(val int) { c.SET_DATA_TIMEOUT_MS = val }, config.SET_DATA_TIMEOUT_MS},
+		{"CLIENT_GET_TIMEOUT_MS", fmt.Sprintf("Set number of CLIENT_GET_TIMEOUT in milliseconds (default: %d): ", config.CLIENT_GET_TIMEOUT_MS), func(val int) { c.CLIENT_GET_TIMEOUT_MS = val }, config.CLIENT_GET_TIMEOUT_MS},
+		{"CLIENT_PUT_TIMEOUT_MS", fmt.Sprintf("Set number of CLIENT_PUT_TIMEOUT in milliseconds (default: %d): ", config.CLIENT_PUT_TIMEOUT_MS), func(val int) { c.CLIENT_PUT_TIMEOUT_MS = val }, config.CLIENT_PUT_TIMEOUT_MS},
+		{"SET_DATA_TIMEOUT_MS", fmt.Sprintf("Set number of SET_DATA_TIMEOUT in ms (default: %d): ", config.SET_DATA_TIMEOUT_MS), func(val int) { c.SET_DATA_TIMEOUT_MS = val }, config.SET_DATA_TIMEOUT_MS},
 		{"N", fmt.Sprintf("Set number of N (default: %d): ", config.N), func(val int) { c.N = val }, config.N},
 		{"R", fmt.Sprintf("Set number of R (default: %d): ", config.R), func(val int) { c.R = val }, config.R},
 		{"W", fmt.Sprintf("Set number of W (default: %d): ", config.W), func(val int) { c.W = val }, config.W},
+		{"BATCH_MAX_KEYS", fmt.Sprintf("Set maximum number of keys in a batch (default: %d): ", config.BATCH_MAX_KEYS), func(val int) { c.BATCH_MAX_KEYS = val }, config.BATCH_MAX_KEYS},
+		{"STREAM_RETENTION_MS", fmt.Sprintf("Set retention of table streams in ms (default: %d): ", config.STREAM_RETENTION_MS), func(val int) { c.STREAM_RETENTION_MS = val }, config.STREAM_RETENTION_MS},
 		{"DEBUG_LEVEL", fmt.Sprintf("Set debug level (default: %d): ", config.DEBUG_LEVEL), func(val int) { c.DEBUG_LEVEL = val }, config.DEBUG_LEVEL},
 	}
 
@@ -45,46 +58,28 @@ func SetConfigs(c *config.Config, reader *bufio.Reader) {
 			input = strings.TrimSpace(input)
 
 			// Empty input uses the default value
-			if input == "" {
-				if prompt.config_type == "N" && (prompt.defaultValue > c.NUM_NODES) {
-					fmt.Printf("WARNING: You are trying to set a %s value of %d which is larger than NUM_NODES. Please enter a value where %s ≤ NUM_NODES.\n",
-						prompt.config_type, prompt.defaultValue, prompt.config_type)
-					continue
-				}
-				if (prompt.config_type == "R" || prompt.config_type == "W") && (prompt.defaultValue > c.NUM_NODES) {
-					fmt.Printf("WARNING: You are trying to set a %s value of %d which is larger than N. Please enter a value where %s ≤ N.\n",
-						prompt.config_type, prompt.defaultValue, prompt.config_type)
+			value := prompt.defaultValue
+			if input != "" {
+				var err error
+				if value, err = strconv.Atoi(input); err != nil {
+					fmt.Println("Invalid input. Please enter a number.")
 					continue
 				}
-				prompt.setter(prompt.defaultValue)
-				// fmt.Printf("set to default: %d.\n\n", prompt.defaultValue)
-				break
 			}
+			prompt.setter(value)
 
-			// Convert the string input to int
-			value, err := strconv.Atoi(input)
-
-			if err == nil && value > 0 {
-				if prompt.config_type == "N" && (value > c.NUM_NODES) {
-					fmt.Printf("WARNING: You are trying to set a %s value of %d which is larger than NUM_NODES. Please enter a value where %s ≤ NUM_NODES.\n",
-						prompt.config_type, value, prompt.config_type)
-					continue
-				}
-				if (prompt.config_type == "R" || prompt.config_type == "W") && (value > c.N) {
-					fmt.Printf("WARNING: You are trying to set a %s value of %d which is larger than N. Please enter a value where %s ≤ N.\n",
-						prompt.config_type, value, prompt.config_type)
-					continue
-				}
-				prompt.setter(value)
-				break
+			// settings are checked against those prompted before them, e.g. R against N
+			if err := c.Validate().Of(prompt.config_type).Err(); err != nil {
+				fmt.Printf("WARNING: %s. Please enter another value.\n", err)
+				continue
 			}
-
-			fmt.Println("Invalid input. Please enter a positive number.")
+			break
 		}
 	}
 
 	fmt.Println("Configuration complete!")
 	printConfig(c)
+	printWarnings(c)
 	fmt.Println("Starting system...")
 }
 
@@ -96,9 +91,18 @@ func printConfig(c *config.Config) {
 	fmt.Printf("CLIENT_PUT_TIMEOUT_MS: %d.\n\n", c.CLIENT_PUT_TIMEOUT_MS)
 	fmt.Printf("SET_DATA_TIMEOUT_MS: %d.\n\n", c.SET_DATA_TIMEOUT_MS)
 	fmt.Printf("N: %d, R: %d, W: %d\n\n", c.N, c.R, c.W)
+	fmt.Printf("BATCH_MAX_KEYS: %d.\n\n", c.BATCH_MAX_KEYS)
+	fmt.Printf("STREAM_RETENTION_MS: %d.\n\n", c.STREAM_RETENTION_MS)
 	fmt.Println("----------------------------------------")
 }
 
+// prints the caveats of the settings found by config.Validate
+func printWarnings(c *config.Config) {
+	for _, warning := range c.Validate().Warnings() {
+		fmt.Println(warning)
+	}
+}
+
 func printStatus(phy_nodes []*base.Node) {
 	fmt.Println("====== STATUS ======")
 	for _, node := range phy_nodes {
@@ -131,8 +135,148 @@ func printStatus(phy_nodes []*base.Node) {
 	}
 }
 
+func doPut(cl *client.Client, key string, value string) error {
+	if err := cl.Put(context.Background(), key, value); err != nil {
+		return err
+	}
+	fmt.Printf("COMPLETED Command=%s: (%s, %s)\n", constants.GetConstantString(constants.CLIENT_ACK_WRITE), key, value)
+	return nil
+}
+
+func doGet(cl *client.Client, key string) error {
+	value, err := cl.Get(context.Background(), key)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("COMPLETED Command=%s: (%s, %s)\n", constants.GetConstantString(constants.CLIENT_ACK_READ), key, value)
+	return nil
+}
+
+func doUpdate(cl *client.Client, key string, actions []base.UpdateAction) error {
+	attrs, err := cl.Update(context.Background(), base.Key{Partition: key}, actions...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("COMPLETED Command=%s: (%s, %v)\n", constants.GetConstantString(constants.CLIENT_REQ_UPDATE), key, attrs)
+	return nil
+}
+
+// pages through the whole table, printing live items as they are read from R replicas
+func doScan(cl *client.Client) error {
+	scan := base.Scan{Limit: 100}
+	count := 0
+	for {
+		page, err := cl.Scan(context.Background(), scan)
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			key := base.Key{Partition: item.Key, Sort: item.SortKey}
+			if item.Attrs != nil {
+				fmt.Printf("	[%s] %s\n", key, item.Attrs)
+			} else {
+				fmt.Printf("	[%s] %s\n", key, item.Data)
+			}
+		}
+		count += len(page.Items)
+		if page.LastKey == nil {
+			break
+		}
+		scan.StartKey = page.LastKey
+	}
+	fmt.Printf("COMPLETED Command=%s: %d item(s)\n", constants.GetConstantString(constants.CLIENT_REQ_SCAN), count)
+	return nil
+}
+
+// reads every shard of the stream of table from its oldest record retained, printing the records in order
+func doStream(cl *client.Client, table string) error {
+	shards, err := cl.DescribeStream(table)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, shard := range shards {
+		iterator, err := cl.GetShardIterator(table, shard.ShardId, constants.ITERATOR_TRIM_HORIZON, 0)
+		if err != nil {
+			return err
+		}
+		page, err := cl.GetRecords(iterator, 0)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("> %s\n", shard.ShardId)
+		for _, record := range page.Records {
+			fmt.Printf("	%d %s [%s] old: %s new: %s\n", record.SequenceNumber, record.EventName, record.Key, record.OldImage, record.NewImage)
+		}
+		count += len(page.Records)
+	}
+	fmt.Printf("COMPLETED stream(%s): %d record(s) in %d shard(s)\n", table, count, len(shards))
+	return nil
+}
+
+// prints the progress of an import or export at most once a second
+func printProgress(op string) func(client.BulkProgress) {
+	last := time.Now()
+	return func(progress client.BulkProgress) {
+		if time.Since(last) >= time.Second {
+			last = time.Now()
+			fmt.Printf("	%s: %d item(s), %d failed\n", op, progress.Items, progress.Failed)
+		}
+	}
+}
+
+// writes the JSON Lines or CSV file path to table through batches of BATCH_MAX_KEYS writes
+func doImport(cl *client.Client, path string, table string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	progress, err := cl.Table(table).Import(context.Background(), f, client.BulkOptions{Format: client.FormatOf(path), Progress: printProgress("import")})
+	fmt.Printf("COMPLETED import(%s): %d item(s) written, %d failed\n", path, progress.Items, progress.Failed)
+	return err
+}
+
+// writes the live items of table to the JSON Lines or CSV file path
+func doExport(cl *client.Client, path string, table string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	progress, err := cl.Table(table).Export(context.Background(), f, client.BulkOptions{Format: client.FormatOf(path), Progress: printProgress("export")})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("COMPLETED export(%s): %d item(s)\n", path, progress.Items)
+	return nil
+}
+
+// Regular expressions to match the commands
+const (
+	putRegex     = `^put\(([^,]+),([^)]+)\) (\d+)`
+	getRegex     = `^get\(([^)]+)\) (\d+)`
+	updateRegex  = `^update\(([^,]+),([^)]+)\) (\d+)`
+	scanRegex    = `^scan\(\) (\d+)`
+	streamRegex  = `^stream\(([^)]+)\) (\d+)`
+	importRegex  = `^import\(([^,)]+)(?:,([^)]+))?\) (\d+)`
+	exportRegex  = `^export\(([^,)]+)(?:,([^)]+))?\) (\d+)`
+	killRegex    = `kill\((\d+),\s?(\d+)\)`
+	revRegex     = `revive\((\d+)\)`
+	serveRegex   = `^serve\(([^)]+)\)$`
+	grpcRegex    = `^grpc\(([^)]+)\)$`
+	backupRegex  = `^backup\(([^)]+)\)$`
+	restoreRegex = `^restore\(([^)]+)\)$`
+	setRegex     = `^set\s+(\S+)\s+(\S+)$`
+	sleepRegex   = `^sleep\(\s*([^)]+?)\s*\)$`
+	expectRegex  = `^expect\s+get\(([^)]+)\)(?:\s+(\d+))?\s*(==|!=)(.*)$`
+	failsRegex   = `^expect\s+(.+?)\s+fails$`
+)
+
 // checker to ensure multiple commands only allow put() or get()
-func checkCommands(rawCommands []string, putRegex string, getRegex string) ([]string, bool) {
+func checkCommands(rawCommands []string) ([]string, bool) {
 	allCommands := make([]string, 0)
 	for i, cmd := range rawCommands {
 		if i == len(rawCommands)-1 {
@@ -151,274 +295,398 @@ func checkCommands(rawCommands []string, putRegex string, getRegex string) ([]st
 	return allCommands, true
 }
 
-func generateClient(clients map[int]*base.Client, client_id int, close_ch chan struct{}, c *config.Config) {
-	clients[client_id] = &base.Client{
-		Id:        client_id,
-		Close:     close_ch,
-		Client_ch: make(chan base.Message),
-		AwaitUids: make(map[int]*atomic.Bool)}
-	go clients[client_id].StartListening(c)
+/* CLI holds the cluster the commands run on, its clients by id and the servers started on it */
+type CLI struct {
+	c         config.Config
+	close_ch  chan struct{}
+	phy_nodes []*base.Node
+	clients   map[int]*client.Client
 
-	fmt.Printf("create client %d \n", client_id)
-}
+	// HTTP front-end, started with serve(addr)
+	server *api.Server
+	// gRPC coordinator, started with grpc(addr)
+	rpcServer *rpc.Server
 
-func main() {
-	seed := time.Now().UnixNano()
-	rand.Seed(seed)
-	fmt.Printf("Starting the application with seed %d\n", seed)
-	reader := bufio.NewReader(os.Stdin)
-
-	c := config.InstantiateConfig()
-	SetConfigs(&c, reader)
-
-	//create close_ch for goroutines
-	close_ch := make(chan struct{})
-	// client_ch := make(chan base.Message)
-	// awaitUids := make(map[int](*atomic.Bool))
-	clients := make(map[int](*base.Client))
+	// running jobId
+	jobId int
+}
 
+func newCLI(c config.Config) *CLI {
+	cli := &CLI{c: c}
 	//node and token initialization
-	phy_nodes := base.CreateNodes(close_ch, &c)
-	base.InitializeTokens(phy_nodes, &c)
+	close_ch := make(chan struct{})
+	phy_nodes := base.CreateNodes(close_ch, &cli.c)
+	base.InitializeTokens(phy_nodes, &cli.c)
+	cli.start(close_ch, phy_nodes)
+	return cli
+}
 
-	// running jobId
-	jobId := 0
+// runs the nodes of a new cluster closed by close_ch, with new clients, and points the servers at them
+func (cli *CLI) start(close_ch chan struct{}, phy_nodes []*base.Node) {
+	cli.close_ch = close_ch
+	cli.phy_nodes = phy_nodes
+	cli.clients = make(map[int]*client.Client)
+	cli.jobId = 0
 
 	//run nodes
 	for i := range phy_nodes {
 		wg.Add(1)
-		go phy_nodes[i].Start(&wg, &c)
+		go phy_nodes[i].Start(&wg)
 	}
 
-	//need to do this for every new client
-	// go base.StartListening(close_ch, client_ch, awaitUids, &c)
+	if cli.server != nil {
+		cli.server.SetNodes(phy_nodes)
+	}
+	if cli.rpcServer != nil {
+		cli.rpcServer.SetNodes(phy_nodes)
+	}
+}
 
-	for {
-		fmt.Print("\nEnter command: ")
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input) // Remove trailing newline
+func (cli *CLI) getClient(client_id int) *client.Client {
+	if cl, exists := cli.clients[client_id]; exists {
+		return cl
+	}
+	cli.clients[client_id] = client.New(cli.phy_nodes, &cli.c)
+	fmt.Printf("create client %d \n", client_id)
+	return cli.clients[client_id]
+}
 
-		rawCommands := strings.Split(input, ";")
-		// fmt.Println(rawCommands[0])
-
-		// Regular expressions to match the commands
-		putRegex := `^put\(([^,]+),([^)]+)\) (\d+)`
-		getRegex := `^get\(([^)]+)\) (\d+)`
-		killRegex := `kill\((\d+),\s?(\d+)\)`
-		revRegex := `revive\((\d+)\)`
-
-		//consider single input
-		if len(rawCommands) == 1 {
-			if input == "exit" {
-				close(close_ch)
-				break
-			} else if input == "status" {
-				printStatus(phy_nodes)
-			} else if input == "wipe" { //restart system
-				close(close_ch) //take care of old goroutines
-
-				close_ch = make(chan struct{})
-				phy_nodes = base.CreateNodes(close_ch, &c)
-				base.InitializeTokens(phy_nodes, &c)
-				clients = make(map[int]*base.Client)
-
-				// running jobId
-				jobId = 0
-
-				//run nodes
-				for i := range phy_nodes {
-					wg.Add(1)
-					go phy_nodes[i].Start(&wg, &c)
-				}
+// Returns the node of id nodeIdx, an error if the cluster has none
+func (cli *CLI) node(nodeIdx int) (*base.Node, error) {
+	if nodeIdx < 0 || nodeIdx >= len(cli.phy_nodes) {
+		return nil, fmt.Errorf("node %d does not exist, ids are 0 to %d", nodeIdx, len(cli.phy_nodes)-1)
+	}
+	return cli.phy_nodes[nodeIdx], nil
+}
 
-			} else if matched, _ := regexp.MatchString(putRegex, input); matched {
-				//put
-				key, value, client_id, err := base.ParsePutArg(putRegex, input)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				client, exists := clients[client_id]
+/*
+Executes a line of input: a command, or put() and get() commands chained with ';'. Returns the error of the
+command, or of the first expectation that does not hold.
+*/
+func (cli *CLI) execute(input string) error {
+	rawCommands := strings.Split(input, ";")
+	// fmt.Println(rawCommands[0])
+
+	//consider single input
+	if len(rawCommands) == 1 {
+		defer func() { cli.jobId++ }()
+		return cli.executeCommand(input)
+	}
 
-				if !exists {
-					generateClient(clients, client_id, close_ch, &c)
-				}
+	cmds, is_correct := checkCommands(rawCommands)
+	if !is_correct {
+		return errors.New("invalid command chain")
+	}
+	var errs []error
+	for _, input := range cmds {
+		if matched, _ := regexp.MatchString(putRegex, input); matched {
+			//put
+			key, value, client_id, err := base.ParsePutArg(putRegex, input)
+			if err == nil {
+				err = doPut(cli.getClient(client_id), key, value)
+			}
+			errs = append(errs, err)
+		} else if matched, _ := regexp.MatchString(getRegex, input); matched {
+			//get
+			key, client_id, err := base.ParseGetArg(getRegex, input)
+			if err == nil {
+				err = doGet(cli.getClient(client_id), key)
+			}
+			errs = append(errs, err)
+		}
+		cli.jobId++
+	}
+	return errors.Join(errs...)
+}
 
-				client = clients[client_id]
-				token, node := base.FindNode(key, phy_nodes, &c)
-				channel := (*node).GetChannel()
-				newJob := jobId
-
-				succ := false
-				cnt := 1
-				for !succ {
-					channel <- base.Message{
-						JobId:     newJob,
-						Key:       key,
-						Command:   constants.ALIVE_ACK,
-						Data:      value,
-						SrcID:     client_id,
-						Client_Ch: client.Client_ch}
-					succ = client.StartTimeout(newJob, constants.ALIVE_ACK, c.CLIENT_GET_TIMEOUT_MS)
-					if succ {
-						channel <- base.Message{
-							JobId:     newJob,
-							Key:       key,
-							Command:   constants.CLIENT_REQ_WRITE,
-							Data:      value,
-							SrcID:     client_id,
-							Client_Ch: client.Client_ch}
-						client.StartTimeout(newJob, constants.CLIENT_REQ_WRITE, c.CLIENT_PUT_TIMEOUT_MS)
-					} else {
-						new_node := base.FindPrefList(token, phy_nodes, cnt)
-						if new_node == nil {
-							fmt.Println("System currently busy, try again later :D")
-							return
-						}
-						channel = (*new_node).GetChannel()
-						fmt.Println("Looking for node handler...")
-						cnt++
-					}
-					jobId++
-					newJob = jobId
-				}
+func (cli *CLI) executeCommand(input string) error {
+	if input == "status" {
+		printStatus(cli.phy_nodes)
 
-			} else if matched, _ := regexp.MatchString(getRegex, input); matched {
-				//get
-				key, client_id, err := base.ParseGetArg(getRegex, input)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				client, exists := clients[client_id]
+	} else if input == "wipe" { //restart system
+		close(cli.close_ch) //take care of old goroutines
 
-				if !exists {
-					generateClient(clients, client_id, close_ch, &c)
-				}
+		close_ch := make(chan struct{})
+		phy_nodes := base.CreateNodes(close_ch, &cli.c)
+		base.InitializeTokens(phy_nodes, &cli.c)
+		cli.start(close_ch, phy_nodes)
 
-				client = clients[client_id]
-
-				_, node := base.FindNode(key, phy_nodes, &c)
-				channel := (*node).GetChannel()
-				channel <- base.Message{
-					JobId:     jobId,
-					Key:       key,
-					Command:   constants.CLIENT_REQ_READ,
-					SrcID:     client_id,
-					Client_Ch: client.Client_ch}
-				client.StartTimeout(jobId, constants.CLIENT_REQ_READ, c.CLIENT_GET_TIMEOUT_MS)
-
-			} else if matched, _ := regexp.MatchString(killRegex, input); matched {
-				nodeIdx, duration, err := base.ParseKillArg(killRegex, input)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
+	} else if matched, _ := regexp.MatchString(backupRegex, input); matched {
+		name, err := base.ParseBackupArg(backupRegex, input)
+		if err != nil {
+			return err
+		}
+		manifest, err := base.Backup(cli.phy_nodes, filepath.Join(backupDir, name))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("COMPLETED backup(%s): %d table(s), %d object(s) of %d node(s) in %s\n", name, len(manifest.Tables), manifest.Objects, manifest.NumNodes, filepath.Join(backupDir, name))
 
-				node := phy_nodes[nodeIdx]
-				channel := (*node).GetChannel()
-				channel <- base.Message{JobId: jobId, Command: constants.CLIENT_REQ_KILL, Data: duration, SrcID: -1}
-			} else if matched, _ := regexp.MatchString(revRegex, input); matched {
-				nodeIdx, err := base.ParseRevArg(revRegex, input)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
+	} else if matched, _ := regexp.MatchString(restoreRegex, input); matched { //replace the cluster with a backup
+		name, err := base.ParseBackupArg(restoreRegex, input)
+		if err != nil {
+			return err
+		}
+		restore_ch := make(chan struct{})
+		restored, manifest, err := base.Restore(filepath.Join(backupDir, name), restore_ch, &cli.c)
+		if err != nil {
+			close(restore_ch)
+			return err
+		}
+		close(cli.close_ch) //take care of old goroutines
 
-				node := phy_nodes[nodeIdx]
-				channel := (*node).GetChannel()
-				channel <- base.Message{JobId: jobId, Command: constants.CLIENT_REQ_REVIVE, SrcID: -1}
-			} else {
-				fmt.Println("Invalid input. Expected get(string) int;, put(string, string) int;, kill(int,int);, revive(int);, or exit;")
-			}
-			jobId++
-		} else {
-			cmds, is_correct := checkCommands(rawCommands, putRegex, getRegex)
-			if is_correct {
-				for _, input := range cmds {
-					if matched, _ := regexp.MatchString(putRegex, input); matched {
-						//put
-						key, value, client_id, err := base.ParsePutArg(putRegex, input)
-						if err != nil {
-							fmt.Println(err)
-							continue
-						}
-						client, exists := clients[client_id]
-
-						if !exists {
-							generateClient(clients, client_id, close_ch, &c)
-						}
-
-						client = clients[client_id]
-						token, node := base.FindNode(key, phy_nodes, &c)
-						channel := (*node).GetChannel()
-						newJob := jobId
-
-						succ := false
-						cnt := 1
-						for !succ {
-							channel <- base.Message{
-								JobId:     newJob,
-								Key:       key,
-								Command:   constants.ALIVE_ACK,
-								Data:      value,
-								SrcID:     client_id,
-								Client_Ch: client.Client_ch}
-							succ = client.StartTimeout(newJob, constants.ALIVE_ACK, c.CLIENT_GET_TIMEOUT_MS)
-							if succ {
-								channel <- base.Message{
-									JobId:     newJob,
-									Key:       key,
-									Command:   constants.CLIENT_REQ_WRITE,
-									Data:      value,
-									SrcID:     client_id,
-									Client_Ch: client.Client_ch}
-								client.StartTimeout(newJob, constants.CLIENT_REQ_WRITE, c.CLIENT_GET_TIMEOUT_MS)
-							} else {
-								new_node := base.FindPrefList(token, phy_nodes, cnt)
-								if new_node == nil {
-									fmt.Println("System currently busy, try again later :D")
-									return
-								}
-								channel = (*new_node).GetChannel()
-								fmt.Println("Looking for node handler...")
-								cnt++
-							}
-							jobId++
-							newJob = jobId
-						}
-					} else if matched, _ := regexp.MatchString(getRegex, input); matched {
-						//get
-						key, client_id, err := base.ParseGetArg(getRegex, input)
-						if err != nil {
-							fmt.Println(err)
-							continue
-						}
-						client, exists := clients[client_id]
-
-						if !exists {
-							generateClient(clients, client_id, close_ch, &c)
-						}
-
-						client = clients[client_id]
-
-						_, node := base.FindNode(key, phy_nodes, &c)
-						channel := (*node).GetChannel()
-						channel <- base.Message{
-							JobId:     jobId,
-							Key:       key,
-							Command:   constants.CLIENT_REQ_READ,
-							SrcID:     client_id,
-							Client_Ch: client.Client_ch}
-						fmt.Println("sending get to node ", node.GetID())
-						client.StartTimeout(jobId, constants.CLIENT_REQ_READ, c.CLIENT_GET_TIMEOUT_MS)
-
-					}
-					jobId++
-				}
+		cli.start(restore_ch, restored)
+		fmt.Printf("COMPLETED restore(%s): %d table(s) of a %d node, %d token cluster taken at %s\n", name, len(manifest.Tables), manifest.NumNodes, manifest.NumTokens, manifest.Created.Format(time.RFC3339))
+
+	} else if matched, _ := regexp.MatchString(setRegex, input); matched { //update a setting of the live cluster
+		name, value, err := base.ParseSetArg(setRegex, input)
+		if err != nil {
+			return err
+		}
+		updated, err := base.SetConfig(cli.phy_nodes, name, value)
+		if err != nil {
+			return err
+		}
+		cli.c = updated
+		for _, cl := range cli.clients {
+			cl.SetConfig(cli.c)
+		}
+		if cli.server != nil {
+			cli.server.SetConfig(cli.c)
+		}
+		if cli.rpcServer != nil {
+			cli.rpcServer.SetConfig(cli.c)
+		}
+		fmt.Printf("COMPLETED set %s %d\n", strings.ToUpper(name), value)
+		printWarnings(&cli.c)
+
+	} else if matched, _ := regexp.MatchString(sleepRegex, input); matched {
+		duration, err := base.ParseSleepArg(sleepRegex, input)
+		if err != nil {
+			return err
+		}
+		time.Sleep(duration)
+
+	} else if matched, _ := regexp.MatchString(failsRegex, input); matched { //the command must fail
+		command := regexp.MustCompile(failsRegex).FindStringSubmatch(input)[1]
+		err := cli.executeCommand(command)
+		if err == nil {
+			return fmt.Errorf("expected %s to fail", command)
+		}
+		fmt.Printf("COMPLETED %s: %s failed: %v\n", input, command, err)
+
+	} else if matched, _ := regexp.MatchString(expectRegex, input); matched {
+		key, client_id, equal, expected, err := base.ParseExpectArg(expectRegex, input)
+		if err != nil {
+			return err
+		}
+		value, err := cli.getClient(client_id).Get(context.Background(), key)
+		if err != nil {
+			return fmt.Errorf("get(%s) %d: %w", key, client_id, err)
+		}
+		if (value == expected) != equal {
+			return fmt.Errorf("expected %s, got get(%s) == %s", strings.TrimPrefix(input, "expect "), key, value)
+		}
+		fmt.Printf("COMPLETED %s\n", input)
+
+	} else if matched, _ := regexp.MatchString(serveRegex, input); matched {
+		if cli.server != nil {
+			return errors.New("HTTP API is already running")
+		}
+		addr := regexp.MustCompile(serveRegex).FindStringSubmatch(input)[1]
+		lis, err := net.Listen("tcp", addr) // bound before the server is kept, so a failed serve can be retried
+		if err != nil {
+			return err
+		}
+		server := api.NewServer(cli.phy_nodes, &cli.c)
+		cli.server = server
+		go func() {
+			if err := http.Serve(lis, server); err != nil {
+				fmt.Println("HTTP API stopped:", err)
 			}
+		}()
+		fmt.Printf("HTTP API listening on %s\n", lis.Addr())
+
+	} else if matched, _ := regexp.MatchString(grpcRegex, input); matched {
+		if cli.rpcServer != nil {
+			return errors.New("gRPC API is already running")
+		}
+		addr := regexp.MustCompile(grpcRegex).FindStringSubmatch(input)[1]
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		cli.rpcServer = rpc.NewServer(cli.phy_nodes, &cli.c)
+		rpc.Serve(lis, cli.rpcServer)
+		fmt.Printf("gRPC API listening on %s\n", lis.Addr())
+
+	} else if matched, _ := regexp.MatchString(putRegex, input); matched {
+		//put
+		key, value, client_id, err := base.ParsePutArg(putRegex, input)
+		if err != nil {
+			return err
+		}
+		return doPut(cli.getClient(client_id), key, value)
+
+	} else if matched, _ := regexp.MatchString(getRegex, input); matched {
+		//get
+		key, client_id, err := base.ParseGetArg(getRegex, input)
+		if err != nil {
+			return err
+		}
+		return doGet(cli.getClient(client_id), key)
+
+	} else if matched, _ := regexp.MatchString(updateRegex, input); matched {
+		//update
+		key, actions, client_id, err := base.ParseUpdateArg(updateRegex, input)
+		if err != nil {
+			return err
+		}
+		return doUpdate(cli.getClient(client_id), key, actions)
+
+	} else if matched, _ := regexp.MatchString(scanRegex, input); matched {
+		//scan
+		client_id, err := base.ParseScanArg(scanRegex, input)
+		if err != nil {
+			return err
+		}
+		return doScan(cli.getClient(client_id))
+
+	} else if matched, _ := regexp.MatchString(streamRegex, input); matched {
+		//stream
+		table, client_id, err := base.ParseStreamArg(streamRegex, input)
+		if err != nil {
+			return err
+		}
+		return doStream(cli.getClient(client_id), table)
+
+	} else if matched, _ := regexp.MatchString(importRegex, input); matched {
+		//import
+		path, table, client_id, err := base.ParseBulkArg(importRegex, input)
+		if err != nil {
+			return err
+		}
+		return doImport(cli.getClient(client_id), path, table)
+
+	} else if matched, _ := regexp.MatchString(exportRegex, input); matched {
+		//export
+		path, table, client_id, err := base.ParseBulkArg(exportRegex, input)
+		if err != nil {
+			return err
+		}
+		return doExport(cli.getClient(client_id), path, table)
+
+	} else if matched, _ := regexp.MatchString(killRegex, input); matched {
+		nodeIdx, duration, err := base.ParseKillArg(killRegex, input)
+		if err != nil {
+			return err
+		}
+		node, err := cli.node(nodeIdx)
+		if err != nil {
+			return err
+		}
+
+		channel := (*node).GetChannel()
+		channel <- base.Message{JobId: cli.jobId, Command: constants.CLIENT_REQ_KILL, Data: duration, SrcID: -1}
+	} else if matched, _ := regexp.MatchString(revRegex, input); matched {
+		nodeIdx, err := base.ParseRevArg(revRegex, input)
+		if err != nil {
+			return err
+		}
+		node, err := cli.node(nodeIdx)
+		if err != nil {
+			return err
+		}
+
+		channel := (*node).GetChannel()
+		channel <- base.Message{JobId: cli.jobId, Command: constants.CLIENT_REQ_REVIVE, SrcID: -1}
+	} else {
+		return errors.New("Invalid input. Expected get(string) int;, put(string, string) int;, update(string, actions) int;, scan() int;, stream(table) int;, import(file[,table]) int;, export(file[,table]) int;, kill(int,int);, revive(int);, set <param> <value>;, sleep(ms);, expect get(string) == value;, expect command fails;, backup(name);, restore(name);, serve(addr);, grpc(addr);, or exit;")
+	}
+	return nil
+}
+
+/*
+Runs the commands of the script path, one per line, with '#' comments and blank lines skipped. Stops at exit,
+and at the first command that fails or expectation that does not hold, whose error is returned with its line.
+*/
+func (cli *CLI) runScript(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" || strings.HasPrefix(input, "#") {
+			continue
+		}
+		if input == "exit" {
+			return nil
+		}
+		fmt.Printf("\n%s:%d> %s\n", path, line, input)
+		if err := cli.execute(input); err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", path, line, input, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func main() {
+	// settings come from -config, DYNAMO_* environment variables and flags, prompted for if none is given
+	flags := config.RegisterFlags(flag.CommandLine)
+	script := flag.String("script", "", "file of commands to run instead of reading them from stdin, exits with status 1 once one fails")
+	flag.Parse()
+
+	seed := time.Now().UnixNano()
+	rand.Seed(seed)
+	fmt.Printf("Starting the application with seed %d\n", seed)
+	reader := bufio.NewReader(os.Stdin)
+
+	c := config.InstantiateConfig()
+	configured, err := flags.Load(&c, os.LookupEnv)
+	if err != nil {
+		fmt.Println("Invalid configuration:", err)
+		os.Exit(2)
+	}
+	if configured || *script != "" { // scripts run without prompts, on the defaults if nothing is given
+		printConfig(&c)
+		printWarnings(&c)
+	} else {
+		SetConfigs(&c, reader)
+	}
+
+	cli := newCLI(c)
+
+	//need to do this for every new client
+	// go base.StartListening(close_ch, client_ch, awaitUids, &c)
+
+	if *script != "" {
+		err := cli.runScript(*script)
+		close(cli.close_ch)
+		wg.Wait()
+		if err != nil {
+			fmt.Println("FAILED", err)
+			os.Exit(1)
 		}
+		fmt.Println("PASSED", *script)
+		return
+	}
 
+	for {
+		fmt.Print("\nEnter command: ")
+		input, _ := reader.ReadString('\n')
+		input = strings.TrimSpace(input) // Remove trailing newline
+
+		if input == "exit" {
+			close(cli.close_ch)
+			break
+		}
+		if err := cli.execute(input); err != nil {
+			fmt.Println(err)
+		}
 	}
 	wg.Wait()
 	fmt.Println("exiting program...")
@@ -0,0 +1,17 @@
+package rpc
+
+import (
+	"rpc/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Dial connects to a coordinator serving the Dynamo service, close the returned connection when done
+func Dial(addr string) (pb.DynamoClient, *grpc.ClientConn, error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return pb.NewDynamoClient(conn), conn, nil
+}
@@ -0,0 +1,160 @@
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pb/dynamo.proto
+
+import (
+	"base"
+	"client"
+	"config"
+	"context"
+	"errors"
+	"net"
+	"rpc/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* Server implements the Dynamo gRPC service by forwarding requests to the physical nodes. */
+type Server struct {
+	pb.UnimplementedDynamoServer
+
+	client *client.Client
+}
+
+func NewServer(phy_nodes []*base.Node, c *config.Config) *Server {
+	return &Server{client: client.New(phy_nodes, c)}
+}
+
+// Serve registers the service on a new gRPC server and serves it on lis until it fails or is stopped
+func Serve(lis net.Listener, server *Server) (*grpc.Server, chan error) {
+	grpcServer := grpc.NewServer()
+	pb.RegisterDynamoServer(grpcServer, server)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- grpcServer.Serve(lis)
+	}()
+	return grpcServer, done
+}
+
+// SetNodes points the server at a new set of nodes, e.g. after the CLI wipes the system
+func (s *Server) SetNodes(phy_nodes []*base.Node) {
+	s.client.SetNodes(phy_nodes)
+}
+
+// SetConfig replaces the settings of the server, e.g. after the CLI updates them on a live cluster
+func (s *Server) SetConfig(c config.Config) {
+	s.client.SetConfig(c)
+}
+
+// Maps client errors onto gRPC status codes
+func statusError(err error) error {
+	if ctxErr := status.FromContextError(err); ctxErr.Code() != codes.Unknown {
+		return ctxErr.Err()
+	}
+	if errors.Is(err, client.ErrInvalidRequest) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key must not be empty")
+	}
+	value, err := s.client.Get(ctx, req.Key)
+	if errors.Is(err, client.ErrNotFound) {
+		return &pb.GetResponse{}, nil
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.GetResponse{Found: true, Value: value}, nil
+}
+
+func (s *Server) Put(ctx context.Context, req *pb.PutRequest) (*pb.PutResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key must not be empty")
+	}
+	if err := s.client.Put(ctx, req.Key, req.Value); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.PutResponse{}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key must not be empty")
+	}
+	if err := s.client.Delete(ctx, req.Key); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+/* Reads the keys with one batch per coordinator. Missing keys are left out, keys that fail are returned as unprocessed. */
+func (s *Server) BatchGet(ctx context.Context, req *pb.BatchGetRequest) (*pb.BatchGetResponse, error) {
+	keys := make([]client.BatchKey, len(req.Keys))
+	for i, key := range req.Keys {
+		keys[i] = client.BatchKey{Key: base.Key{Partition: key}}
+	}
+	result, err := s.client.BatchGet(ctx, keys)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &pb.BatchGetResponse{}
+	for _, key := range keys {
+		if value, exists := result.Items[key]; exists {
+			resp.Items = append(resp.Items, &pb.Item{Key: key.Key.Partition, Value: value.Data})
+		}
+	}
+	for _, key := range result.Unprocessed {
+		resp.UnprocessedKeys = append(resp.UnprocessedKeys, key.Key.Partition)
+	}
+	return resp, nil
+}
+
+/* Writes the items with one batch per coordinator. Items that are not acknowledged in time are returned as unprocessed. */
+func (s *Server) BatchPut(ctx context.Context, req *pb.BatchPutRequest) (*pb.BatchPutResponse, error) {
+	writes := make([]client.BatchWrite, len(req.Items))
+	for i, item := range req.Items {
+		writes[i] = client.BatchWrite{BatchKey: client.BatchKey{Key: base.Key{Partition: item.Key}}, Value: item.Value}
+	}
+	result, err := s.client.BatchWrite(ctx, writes)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &pb.BatchPutResponse{}
+	for _, write := range result.Unprocessed {
+		resp.UnprocessedKeys = append(resp.UnprocessedKeys, write.Key.Partition)
+	}
+	return resp, nil
+}
+
+// items read per page of a scan
+const scanPageSize = 100
+
+/* Streams every live item exactly once, a page at a time. Each token range is read from R replicas. */
+func (s *Server) Scan(req *pb.ScanRequest, stream pb.Dynamo_ScanServer) error {
+	scan := base.Scan{Limit: scanPageSize}
+	for {
+		page, err := s.client.Scan(stream.Context(), scan)
+		if err != nil {
+			return statusError(err)
+		}
+		for _, item := range page.Items {
+			key := base.Key{Partition: item.Key, Sort: item.SortKey}
+			if err := stream.Send(&pb.Item{Key: key.String(), Value: item.Data}); err != nil {
+				return err
+			}
+		}
+		if page.LastKey == nil {
+			return nil
+		}
+		scan.StartKey = page.LastKey
+	}
+}
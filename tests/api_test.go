@@ -0,0 +1,980 @@
+package tests
+
+import (
+	"api"
+	"bytes"
+	"config"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sends a DynamoDB JSON protocol request, returns the status code and decoded body
+func callApi(t *testing.T, url string, operation string, body interface{}) (int, map[string]interface{}) {
+	payload, _ := json.Marshal(body)
+	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+operation)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s request failed: %s", operation, err)
+	}
+	defer resp.Body.Close()
+
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+// creates the table "users" keyed by the S attribute "id"
+func createUsersTable(t *testing.T, url string) {
+	status, out := callApi(t, url, "CreateTable", map[string]interface{}{
+		"TableName":            "users",
+		"KeySchema":            []map[string]string{{"AttributeName": "id", "KeyType": "HASH"}},
+		"AttributeDefinitions": []map[string]string{{"AttributeName": "id", "AttributeType": "S"}},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("CreateTable returned status %d: %v", status, out)
+	}
+}
+
+func setUpApi(c *config.Config) (*httptest.Server, chan struct{}) {
+	phy_nodes, close_ch, _ := setUpNodes(c)
+	return httptest.NewServer(api.NewServer(phy_nodes, c)), close_ch
+}
+
+// TEST A1
+
+// TestApiPutGetDelete ensures items written with PutItem are returned
+// intact by GetItem and are gone after DeleteItem
+func TestApiPutGetDelete(t *testing.T) {
+	var tests = []struct {
+		numNodes, numTokens, nValue, rValue, wValue int
+	}{
+		{1, 1, 1, 1, 1},
+		{5, 5, 3, 1, 1},
+		{5, 10, 3, 2, 2},
+	}
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%d_nodes_%d_tokens_%d_n_%d_r_%d_w", tt.numNodes, tt.numTokens, tt.nValue, tt.rValue, tt.wValue)
+		t.Run(testname, func(t *testing.T) {
+			c := config.InstantiateConfig()
+			c.NUM_NODES = tt.numNodes
+			c.NUM_TOKENS = tt.numTokens
+			c.N = tt.nValue
+			c.R = tt.rValue
+			c.W = tt.wValue
+			c.DEBUG_LEVEL = 1
+			c.CLIENT_GET_TIMEOUT_MS = 500
+
+			server, close_ch := setUpApi(&c)
+			defer close(close_ch)
+			defer server.Close()
+
+			createUsersTable(t, server.URL)
+
+			key := map[string]interface{}{"id": map[string]string{"S": "user1"}}
+			item := map[string]interface{}{
+				"id":   map[string]string{"S": "user1"},
+				"name": map[string]string{"S": "Sudipta"},
+				"age":  map[string]string{"N": "42"},
+			}
+
+			status, _ := callApi(t, server.URL, "PutItem", map[string]interface{}{"TableName": "users", "Item": item})
+			if status != http.StatusOK {
+				t.Fatalf("PutItem returned status %d", status)
+			}
+
+			status, out := callApi(t, server.URL, "GetItem", map[string]interface{}{"TableName": "users", "Key": key})
+			if status != http.StatusOK {
+				t.Fatalf("GetItem returned status %d", status)
+			}
+			got, _ := json.Marshal(out["Item"])
+			expected, _ := json.Marshal(item)
+			if string(got) != string(expected) {
+				t.Errorf("got: %s, expected: %s", got, expected)
+			}
+
+			status, _ = callApi(t, server.URL, "DeleteItem", map[string]interface{}{"TableName": "users", "Key": key})
+			if status != http.StatusOK {
+				t.Fatalf("DeleteItem returned status %d", status)
+			}
+
+			_, out = callApi(t, server.URL, "GetItem", map[string]interface{}{"TableName": "users", "Key": key})
+			if _, exists := out["Item"]; exists {
+				t.Errorf("expected no item after DeleteItem, got %v", out["Item"])
+			}
+		})
+	}
+}
+
+// TEST A2
+
+// TestApiUpdateItem ensures SET and REMOVE actions are applied to the stored item
+func TestApiUpdateItem(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_GET_TIMEOUT_MS = 500
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+
+	key := map[string]interface{}{"id": map[string]string{"S": "user2"}}
+	callApi(t, server.URL, "PutItem", map[string]interface{}{"Item": map[string]interface{}{
+		"id":   map[string]string{"S": "user2"},
+		"name": map[string]string{"S": "old"},
+		"tmp":  map[string]string{"S": "remove me"},
+	}})
+
+	status, out := callApi(t, server.URL, "UpdateItem", map[string]interface{}{
+		"Key":                       key,
+		"UpdateExpression":          "SET #n = :name, city = :city REMOVE tmp",
+		"ExpressionAttributeNames":  map[string]string{"#n": "name"},
+		"ExpressionAttributeValues": map[string]interface{}{":name": map[string]string{"S": "new"}, ":city": map[string]string{"S": "Singapore"}},
+		"ReturnValues":              "ALL_NEW",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("UpdateItem returned status %d: %v", status, out)
+	}
+
+	_, out = callApi(t, server.URL, "GetItem", map[string]interface{}{"Key": key})
+	got, _ := json.Marshal(out["Item"])
+	expected := `{"city":{"S":"Singapore"},"id":{"S":"user2"},"name":{"S":"new"}}`
+	if string(got) != expected {
+		t.Errorf("got: %s, expected: %s", got, expected)
+	}
+}
+
+// TEST A3
+
+// TestApiErrors ensures malformed requests are rejected with DynamoDB error types
+func TestApiErrors(t *testing.T) {
+	var tests = []struct {
+		operation string
+		body      interface{}
+		errType   string
+	}{
+		{"ListBackups", map[string]interface{}{}, "com.amazon.coral.service#UnknownOperationException"},
+		{"GetItem", map[string]interface{}{"Key": map[string]interface{}{"name": map[string]string{"S": "x"}}}, "com.amazonaws.dynamodb.v20120810#ValidationException"},
+		{"PutItem", map[string]interface{}{"Item": map[string]interface{}{"id": map[string]string{"BOOL": "x"}}}, "com.amazonaws.dynamodb.v20120810#ValidationException"},
+		{"UpdateItem", map[string]interface{}{"Key": map[string]interface{}{"id": map[string]string{"S": "x"}}, "UpdateExpression": "SET a = :missing"}, "com.amazonaws.dynamodb.v20120810#ValidationException"},
+		{"PutItem", map[string]interface{}{"Item": map[string]interface{}{"id": map[string]string{"S": "x"}}, "ConditionExpression": "attribute_not_exists(name)"}, "com.amazonaws.dynamodb.v20120810#ValidationException"},
+		{"PutItem", map[string]interface{}{"Item": map[string]interface{}{"id": map[string]string{"S": "x"}, "age": map[string]string{"N": "old"}}}, "com.amazonaws.dynamodb.v20120810#ValidationException"},
+		{"PutItem", map[string]interface{}{"Item": map[string]interface{}{"id": map[string]string{"S": "x"}, "tags": map[string][]string{"SS": {}}}}, "com.amazonaws.dynamodb.v20120810#ValidationException"},
+	}
+
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.DEBUG_LEVEL = 1
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			status, out := callApi(t, server.URL, tt.operation, tt.body)
+			if status != http.StatusBadRequest {
+				t.Errorf("got status %d, expected %d", status, http.StatusBadRequest)
+			}
+			if out["__type"] != tt.errType {
+				t.Errorf("got error type %v, expected %s", out["__type"], tt.errType)
+			}
+		})
+	}
+}
+
+// TEST A4
+
+// TestApiConditionalPut ensures PutItem with attribute_not_exists only creates new items
+func TestApiConditionalPut(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+
+	put := func(name string) (int, map[string]interface{}) {
+		return callApi(t, server.URL, "PutItem", map[string]interface{}{
+			"Item": map[string]interface{}{
+				"id":   map[string]string{"S": "user3"},
+				"name": map[string]string{"S": name},
+			},
+			"ConditionExpression":      "attribute_not_exists(#k)",
+			"ExpressionAttributeNames": map[string]string{"#k": "id"},
+		})
+	}
+
+	if status, out := put("first"); status != http.StatusOK {
+		t.Fatalf("PutItem of a new item returned status %d: %v", status, out)
+	}
+	status, out := put("second")
+	if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException" {
+		t.Errorf("PutItem of an existing item returned status %d: %v, expected ConditionalCheckFailedException", status, out)
+	}
+
+	_, out = callApi(t, server.URL, "GetItem", map[string]interface{}{"Key": map[string]interface{}{"id": map[string]string{"S": "user3"}}})
+	got, _ := json.Marshal(out["Item"])
+	expected := `{"id":{"S":"user3"},"name":{"S":"first"}}`
+	if string(got) != expected {
+		t.Errorf("got: %s, expected: %s", got, expected)
+	}
+}
+
+// TEST A5
+
+// TestApiUpdateItemAdd ensures concurrent UpdateItem ADD actions on a counter are all applied
+func TestApiUpdateItemAdd(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+
+	key := map[string]interface{}{"id": map[string]string{"S": "counter"}}
+	const writers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, out := callApi(t, server.URL, "UpdateItem", map[string]interface{}{
+				"Key":                       key,
+				"UpdateExpression":          "ADD hits :one",
+				"ExpressionAttributeValues": map[string]interface{}{":one": map[string]string{"N": "1"}},
+			})
+			if status != http.StatusOK {
+				t.Errorf("UpdateItem returned status %d: %v", status, out)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, out := callApi(t, server.URL, "GetItem", map[string]interface{}{"Key": key})
+	got, _ := json.Marshal(out["Item"])
+	expected := fmt.Sprintf(`{"hits":{"N":"%d"},"id":{"S":"counter"}}`, writers)
+	if string(got) != expected {
+		t.Errorf("got: %s, expected: %s", got, expected)
+	}
+
+	status, out := callApi(t, server.URL, "UpdateItem", map[string]interface{}{
+		"Key":                       key,
+		"UpdateExpression":          "ADD id :one",
+		"ExpressionAttributeValues": map[string]interface{}{":one": map[string]string{"N": "1"}},
+	})
+	if status != http.StatusBadRequest {
+		t.Errorf("ADD on the key attribute returned status %d: %v, expected %d", status, out, http.StatusBadRequest)
+	}
+}
+
+// TEST A6
+
+// TestApiTypedAttributes ensures attributes of every DynamoDB type are returned intact
+func TestApiTypedAttributes(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.DEBUG_LEVEL = 1
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+
+	item := `{"active":{"BOOL":true},"address":{"M":{"city":{"S":"Singapore"}}},"avatar":{"B":"aGk="},"history":{"L":[{"N":"1"},{"S":"x"}]},` +
+		`"id":{"S":"user4"},"manager":{"NULL":true},"scores":{"NS":["1","2.5"]},"tags":{"SS":["a","b"]}}`
+	var body map[string]interface{}
+	json.Unmarshal([]byte(item), &body)
+	if status, out := callApi(t, server.URL, "PutItem", map[string]interface{}{"Item": body}); status != http.StatusOK {
+		t.Fatalf("PutItem returned status %d: %v", status, out)
+	}
+
+	_, out := callApi(t, server.URL, "GetItem", map[string]interface{}{"Key": map[string]interface{}{"id": map[string]string{"S": "user4"}}})
+	got, _ := json.Marshal(out["Item"])
+	if string(got) != item {
+		t.Errorf("got: %s, expected: %s", got, item)
+	}
+}
+
+// TEST A7
+
+// TestApiSortKey ensures items of a table with a sort key are addressed by both key attributes
+func TestApiSortKey(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	s := api.NewServer(phy_nodes, &c)
+	s.SortKeyAttribute = "ts"
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	for _, ts := range []string{"2", "1"} {
+		item := map[string]interface{}{"id": map[string]string{"S": "user5"}, "ts": map[string]string{"N": ts}, "event": map[string]string{"S": "event" + ts}}
+		if status, out := callApi(t, server.URL, "PutItem", map[string]interface{}{"Item": item}); status != http.StatusOK {
+			t.Fatalf("PutItem returned status %d: %v", status, out)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		key      map[string]interface{}
+		expected string
+	}{
+		{"first", map[string]interface{}{"id": map[string]string{"S": "user5"}, "ts": map[string]string{"N": "1"}}, `{"event":{"S":"event1"},"id":{"S":"user5"},"ts":{"N":"1"}}`},
+		{"second", map[string]interface{}{"id": map[string]string{"S": "user5"}, "ts": map[string]string{"N": "2"}}, `{"event":{"S":"event2"},"id":{"S":"user5"},"ts":{"N":"2"}}`},
+		{"missing", map[string]interface{}{"id": map[string]string{"S": "user5"}, "ts": map[string]string{"N": "3"}}, `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, out := callApi(t, server.URL, "GetItem", map[string]interface{}{"Key": tt.key})
+			got, _ := json.Marshal(out["Item"])
+			if string(got) != tt.expected {
+				t.Errorf("got: %s, expected: %s", got, tt.expected)
+			}
+		})
+	}
+
+	status, out := callApi(t, server.URL, "GetItem", map[string]interface{}{"Key": map[string]interface{}{"id": map[string]string{"S": "user5"}}})
+	if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#ValidationException" {
+		t.Errorf("GetItem without sort key returned status %d: %v, expected ValidationException", status, out)
+	}
+}
+
+// TEST A8
+
+// TestApiQuery ensures Query returns the items of a partition matching its KeyConditionExpression a page at a time
+func TestApiQuery(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 3
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	s := api.NewServer(phy_nodes, &c)
+	s.SortKeyAttribute = "date"
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	for _, date := range []string{"2023-02", "2023-01", "2024-01"} {
+		item := map[string]interface{}{"id": map[string]string{"S": "user6"}, "date": map[string]string{"S": date}}
+		if status, out := callApi(t, server.URL, "PutItem", map[string]interface{}{"Item": item}); status != http.StatusOK {
+			t.Fatalf("PutItem returned status %d: %v", status, out)
+		}
+	}
+
+	values := map[string]interface{}{
+		":id":   map[string]string{"S": "user6"},
+		":from": map[string]string{"S": "2023-01"},
+		":to":   map[string]string{"S": "2023-12"},
+		":year": map[string]string{"S": "2024"},
+	}
+	tests := []struct {
+		name     string
+		request  map[string]interface{}
+		expected []string
+		lastKey  string
+	}{
+		{"partition", map[string]interface{}{"KeyConditionExpression": "id = :id"}, []string{"2023-01", "2023-02", "2024-01"}, ""},
+		{"between", map[string]interface{}{"KeyConditionExpression": "id = :id AND #d BETWEEN :from AND :to", "ExpressionAttributeNames": map[string]string{"#d": "date"}}, []string{"2023-01", "2023-02"}, ""},
+		{"begins_with", map[string]interface{}{"KeyConditionExpression": "begins_with(#d, :year) AND id = :id", "ExpressionAttributeNames": map[string]string{"#d": "date"}}, []string{"2024-01"}, ""},
+		{"reverse", map[string]interface{}{"KeyConditionExpression": "id = :id AND #d > :from", "ExpressionAttributeNames": map[string]string{"#d": "date"}, "ScanIndexForward": false}, []string{"2024-01", "2023-02"}, ""},
+		{"limit", map[string]interface{}{"KeyConditionExpression": "id = :id", "Limit": 2}, []string{"2023-01", "2023-02"}, "2023-02"},
+		{"next_page", map[string]interface{}{"KeyConditionExpression": "id = :id", "Limit": 2, "ExclusiveStartKey": map[string]interface{}{"id": map[string]string{"S": "user6"}, "date": map[string]string{"S": "2023-02"}}}, []string{"2024-01"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.request["ExpressionAttributeValues"] = values
+			status, out := callApi(t, server.URL, "Query", tt.request)
+			if status != http.StatusOK {
+				t.Fatalf("Query returned status %d: %v", status, out)
+			}
+			var got []string
+			for _, item := range out["Items"].([]interface{}) {
+				got = append(got, item.(map[string]interface{})["date"].(map[string]interface{})["S"].(string))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.expected) || out["Count"] != float64(len(tt.expected)) {
+				t.Errorf("got: %v (Count %v), expected: %v", got, out["Count"], tt.expected)
+			}
+			lastKey, _ := json.Marshal(out["LastEvaluatedKey"])
+			expected := "null"
+			if tt.lastKey != "" {
+				expected = `{"date":{"S":"` + tt.lastKey + `"},"id":{"S":"user6"}}`
+			}
+			if string(lastKey) != expected {
+				t.Errorf("got LastEvaluatedKey %s, expected %s", lastKey, expected)
+			}
+		})
+	}
+
+	invalid := []string{"id < :id", "#d = :from", "id = :id AND contains(#d, :year)", "id = :id AND #d BETWEEN :from"}
+	for _, expr := range invalid {
+		status, out := callApi(t, server.URL, "Query", map[string]interface{}{"KeyConditionExpression": expr, "ExpressionAttributeNames": map[string]string{"#d": "date"}, "ExpressionAttributeValues": values})
+		if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#ValidationException" {
+			t.Errorf("Query %q returned status %d: %v, expected ValidationException", expr, status, out)
+		}
+	}
+}
+
+// TEST A9
+
+// TestApiScan ensures Scan returns every item a page at a time
+func TestApiScan(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 3
+	c.DEBUG_LEVEL = 1
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+
+	expected := []string{}
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("user%d", i)
+		callApi(t, server.URL, "PutItem", map[string]interface{}{"Item": map[string]interface{}{"id": map[string]string{"S": id}}})
+		expected = append(expected, id)
+	}
+
+	tests := []struct {
+		name    string
+		request map[string]interface{}
+		pages   int
+	}{
+		{"single_page", map[string]interface{}{}, 1},
+		{"pages", map[string]interface{}{"Limit": 2}, 3},
+		{"segment", map[string]interface{}{"Limit": 2, "Segment": 0, "TotalSegments": 1}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			pages := 0
+			for {
+				status, out := callApi(t, server.URL, "Scan", tt.request)
+				if status != http.StatusOK {
+					t.Fatalf("Scan returned status %d: %v", status, out)
+				}
+				pages++
+				for _, item := range out["Items"].([]interface{}) {
+					got = append(got, item.(map[string]interface{})["id"].(map[string]interface{})["S"].(string))
+				}
+				if out["LastEvaluatedKey"] == nil {
+					break
+				}
+				tt.request["ExclusiveStartKey"] = out["LastEvaluatedKey"]
+			}
+			sort.Strings(got)
+			if fmt.Sprint(got) != fmt.Sprint(expected) || pages != tt.pages {
+				t.Errorf("got: %v in %d pages, expected: %v in %d pages", got, pages, expected, tt.pages)
+			}
+		})
+	}
+
+	status, out := callApi(t, server.URL, "Scan", map[string]interface{}{"Segment": 1})
+	if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#ValidationException" {
+		t.Errorf("Scan with Segment only returned status %d: %v, expected ValidationException", status, out)
+	}
+}
+
+// TEST A10
+
+// TestApiTables ensures tables are created, listed, described and deleted,
+// and that items are keyed by the schema of the table they are written to
+func TestApiTables(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+
+	create := map[string]interface{}{
+		"TableName": "orders",
+		"KeySchema": []map[string]string{{"AttributeName": "customer", "KeyType": "HASH"}, {"AttributeName": "date", "KeyType": "RANGE"}},
+		"AttributeDefinitions": []map[string]string{
+			{"AttributeName": "customer", "AttributeType": "S"},
+			{"AttributeName": "date", "AttributeType": "S"},
+		},
+		"TimeToLiveSpecification": map[string]interface{}{"AttributeName": "expires", "Enabled": true},
+		"StreamSpecification":     map[string]interface{}{"StreamEnabled": true, "StreamViewType": "NEW_AND_OLD_IMAGES"},
+		"N":                       2,
+		"ConflictResolution":      "LAST_WRITER_WINS",
+	}
+	status, out := callApi(t, server.URL, "CreateTable", create)
+	if status != http.StatusOK {
+		t.Fatalf("CreateTable returned status %d: %v", status, out)
+	}
+	createUsersTable(t, server.URL)
+
+	status, out = callApi(t, server.URL, "DescribeTable", map[string]interface{}{"TableName": "orders"})
+	desc, _ := json.Marshal(out["Table"])
+	var table api.TableDescription
+	json.Unmarshal(desc, &table)
+	if status != http.StatusOK || table.TableStatus != "ACTIVE" || len(table.KeySchema) != 2 || table.N != 2 || table.R != 2 || table.W != 2 ||
+		table.ConflictResolution != "LAST_WRITER_WINS" || table.TimeToLiveSpecification == nil || table.TimeToLiveSpecification.AttributeName != "expires" ||
+		table.StreamSpecification == nil || !table.StreamSpecification.StreamEnabled {
+		t.Errorf("DescribeTable returned status %d: %s", status, desc)
+	}
+
+	_, out = callApi(t, server.URL, "ListTables", map[string]interface{}{})
+	if fmt.Sprint(out["TableNames"]) != "[orders users]" {
+		t.Errorf("ListTables got: %v", out["TableNames"])
+	}
+	_, out = callApi(t, server.URL, "ListTables", map[string]interface{}{"Limit": 1})
+	if fmt.Sprint(out["TableNames"]) != "[orders]" || out["LastEvaluatedTableName"] != "orders" {
+		t.Errorf("ListTables with Limit got: %v", out)
+	}
+
+	order := map[string]interface{}{"customer": map[string]string{"S": "alice"}, "date": map[string]string{"S": "2023-01"}}
+	if status, out := callApi(t, server.URL, "PutItem", map[string]interface{}{"TableName": "orders", "Item": order}); status != http.StatusOK {
+		t.Fatalf("PutItem returned status %d: %v", status, out)
+	}
+	_, out = callApi(t, server.URL, "GetItem", map[string]interface{}{"TableName": "orders", "Key": order})
+	if out["Item"] == nil {
+		t.Errorf("GetItem returned no item")
+	}
+
+	tests := []struct {
+		name      string
+		operation string
+		request   map[string]interface{}
+		errType   string
+	}{
+		{"wrong_schema", "GetItem", map[string]interface{}{"TableName": "users", "Key": order}, "ValidationException"},
+		{"missing_table", "GetItem", map[string]interface{}{"TableName": "missing", "Key": order}, "ResourceNotFoundException"},
+		{"existing_table", "CreateTable", create, "ResourceInUseException"},
+		{"no_hash_key", "CreateTable", map[string]interface{}{"TableName": "nokey"}, "ValidationException"},
+		{"undefined_key", "CreateTable", map[string]interface{}{"TableName": "nodef", "KeySchema": []map[string]string{{"AttributeName": "id", "KeyType": "HASH"}}}, "ValidationException"},
+		{"invalid_n", "CreateTable", map[string]interface{}{"TableName": "big", "KeySchema": []map[string]string{{"AttributeName": "id", "KeyType": "HASH"}}, "AttributeDefinitions": []map[string]string{{"AttributeName": "id", "AttributeType": "S"}}, "N": 6}, "ValidationException"},
+		{"describe_missing", "DescribeTable", map[string]interface{}{"TableName": "missing"}, "ResourceNotFoundException"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, out := callApi(t, server.URL, tt.operation, tt.request)
+			if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#"+tt.errType {
+				t.Errorf("%s returned status %d: %v, expected %s", tt.operation, status, out, tt.errType)
+			}
+		})
+	}
+
+	status, out = callApi(t, server.URL, "DeleteTable", map[string]interface{}{"TableName": "orders"})
+	if status != http.StatusOK {
+		t.Fatalf("DeleteTable returned status %d: %v", status, out)
+	}
+	status, out = callApi(t, server.URL, "GetItem", map[string]interface{}{"TableName": "orders", "Key": order})
+	if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#ResourceNotFoundException" {
+		t.Errorf("GetItem in a deleted table returned status %d: %v", status, out)
+	}
+}
+
+// TEST A11
+
+// TestApiBatch ensures BatchWriteItem puts and deletes are returned by
+// BatchGetItem per table, and malformed batches are rejected
+func TestApiBatch(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+	createUsersTable(t, server.URL)
+
+	var puts, keys []map[string]interface{}
+	for i := 0; i < 10; i++ {
+		key := map[string]interface{}{"id": map[string]string{"S": fmt.Sprintf("user%d", i)}}
+		item := map[string]interface{}{"id": key["id"], "age": map[string]string{"N": fmt.Sprint(20 + i)}}
+		puts = append(puts, map[string]interface{}{"PutRequest": map[string]interface{}{"Item": item}})
+		keys = append(keys, key)
+	}
+	status, out := callApi(t, server.URL, "BatchWriteItem", map[string]interface{}{"RequestItems": map[string]interface{}{"users": puts}})
+	if status != http.StatusOK || len(out["UnprocessedItems"].(map[string]interface{})) != 0 {
+		t.Fatalf("BatchWriteItem returned status %d: %v", status, out)
+	}
+
+	deletes := []map[string]interface{}{{"DeleteRequest": map[string]interface{}{"Key": keys[0]}}}
+	if status, out := callApi(t, server.URL, "BatchWriteItem", map[string]interface{}{"RequestItems": map[string]interface{}{"users": deletes}}); status != http.StatusOK {
+		t.Fatalf("BatchWriteItem of a delete returned status %d: %v", status, out)
+	}
+
+	status, out = callApi(t, server.URL, "BatchGetItem", map[string]interface{}{"RequestItems": map[string]interface{}{"users": map[string]interface{}{"Keys": keys}}})
+	if status != http.StatusOK {
+		t.Fatalf("BatchGetItem returned status %d: %v", status, out)
+	}
+	items := out["Responses"].(map[string]interface{})["users"].([]interface{})
+	ages := []string{}
+	for _, item := range items {
+		ages = append(ages, fmt.Sprint(item.(map[string]interface{})["age"]))
+	}
+	sort.Strings(ages)
+	if len(items) != 9 || ages[0] != "map[N:21]" || ages[8] != "map[N:29]" || len(out["UnprocessedKeys"].(map[string]interface{})) != 0 {
+		t.Errorf("BatchGetItem got: %v", out)
+	}
+
+	tests := []struct {
+		name      string
+		operation string
+		request   map[string]interface{}
+		errType   string
+	}{
+		{"empty", "BatchGetItem", map[string]interface{}{"RequestItems": map[string]interface{}{}}, "ValidationException"},
+		{"duplicate_key", "BatchGetItem", map[string]interface{}{"RequestItems": map[string]interface{}{"users": map[string]interface{}{"Keys": []interface{}{keys[1], keys[1]}}}}, "ValidationException"},
+		{"missing_table", "BatchGetItem", map[string]interface{}{"RequestItems": map[string]interface{}{"missing": map[string]interface{}{"Keys": keys[:1]}}}, "ResourceNotFoundException"},
+		{"wrong_key", "BatchWriteItem", map[string]interface{}{"RequestItems": map[string]interface{}{"users": []interface{}{map[string]interface{}{"DeleteRequest": map[string]interface{}{"Key": map[string]interface{}{"name": keys[1]["id"]}}}}}}, "ValidationException"},
+		{"put_and_delete", "BatchWriteItem", map[string]interface{}{"RequestItems": map[string]interface{}{"users": []interface{}{map[string]interface{}{"PutRequest": puts[1]["PutRequest"], "DeleteRequest": deletes[0]["DeleteRequest"]}}}}, "ValidationException"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, out := callApi(t, server.URL, tt.operation, tt.request)
+			if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#"+tt.errType {
+				t.Errorf("%s returned status %d: %v, expected %s", tt.operation, status, out, tt.errType)
+			}
+		})
+	}
+}
+
+// TEST A12
+
+// TestApiTransactWriteItems ensures TransactWriteItems applies every item or none,
+// and reports why a canceled transaction failed per item
+func TestApiTransactWriteItems(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+	createUsersTable(t, server.URL)
+
+	key := func(id string) map[string]interface{} {
+		return map[string]interface{}{"id": map[string]string{"S": id}}
+	}
+	put := func(id string, condition string) map[string]interface{} {
+		item := map[string]interface{}{"id": map[string]string{"S": id}, "age": map[string]string{"N": "30"}}
+		return map[string]interface{}{"Put": map[string]interface{}{"TableName": "users", "Item": item, "ConditionExpression": condition}}
+	}
+	transact := func(items ...map[string]interface{}) (int, map[string]interface{}) {
+		return callApi(t, server.URL, "TransactWriteItems", map[string]interface{}{"TransactItems": items})
+	}
+
+	if status, out := transact(put("user1", "attribute_not_exists(id)"), put("user2", "")); status != http.StatusOK {
+		t.Fatalf("TransactWriteItems returned status %d: %v", status, out)
+	}
+
+	update := map[string]interface{}{"Update": map[string]interface{}{
+		"TableName":                 "users",
+		"Key":                       key("user2"),
+		"UpdateExpression":          "SET age = :age",
+		"ConditionExpression":       "attribute_exists(id)",
+		"ExpressionAttributeValues": map[string]interface{}{":age": map[string]string{"N": "31"}},
+	}}
+	check := map[string]interface{}{"ConditionCheck": map[string]interface{}{"TableName": "users", "Key": key("user3"), "ConditionExpression": "attribute_exists(id)"}}
+	status, out := transact(update, put("user1", "attribute_not_exists(id)"), check)
+	if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#TransactionCanceledException" {
+		t.Fatalf("TransactWriteItems of failed conditions returned status %d: %v, expected TransactionCanceledException", status, out)
+	}
+	reasons, _ := json.Marshal(out["CancellationReasons"])
+	expected := `[{"Code":"None"},{"Code":"ConditionalCheckFailed","Message":"The conditional request failed"},{"Code":"ConditionalCheckFailed","Message":"The conditional request failed"}]`
+	if string(reasons) != expected {
+		t.Errorf("CancellationReasons got: %s, expected: %s", reasons, expected)
+	}
+
+	_, out = callApi(t, server.URL, "GetItem", map[string]interface{}{"TableName": "users", "Key": key("user2")})
+	if age, _ := json.Marshal(out["Item"].(map[string]interface{})["age"]); string(age) != `{"N":"30"}` {
+		t.Errorf("age of user2 after a canceled transaction got: %s, expected 30", age)
+	}
+
+	deleteUser1 := map[string]interface{}{"Delete": map[string]interface{}{"TableName": "users", "Key": key("user1")}}
+	if status, out := transact(update, deleteUser1); status != http.StatusOK {
+		t.Fatalf("TransactWriteItems of an update and a delete returned status %d: %v", status, out)
+	}
+	_, out = callApi(t, server.URL, "GetItem", map[string]interface{}{"TableName": "users", "Key": key("user1")})
+	if out["Item"] != nil {
+		t.Errorf("GetItem of a deleted item got: %v", out)
+	}
+	_, out = callApi(t, server.URL, "GetItem", map[string]interface{}{"TableName": "users", "Key": key("user2")})
+	if age, _ := json.Marshal(out["Item"].(map[string]interface{})["age"]); string(age) != `{"N":"31"}` {
+		t.Errorf("age of user2 got: %s, expected 31", age)
+	}
+
+	tests := []struct {
+		name    string
+		items   []map[string]interface{}
+		errType string
+	}{
+		{"empty", nil, "ValidationException"},
+		{"put_and_delete", []map[string]interface{}{{"Put": put("user4", "")["Put"], "Delete": deleteUser1["Delete"]}}, "ValidationException"},
+		{"check_without_condition", []map[string]interface{}{{"ConditionCheck": map[string]interface{}{"TableName": "users", "Key": key("user3")}}}, "ValidationException"},
+		{"unsupported_condition", []map[string]interface{}{put("user4", "age > :a")}, "ValidationException"},
+		{"missing_table", []map[string]interface{}{{"Delete": map[string]interface{}{"TableName": "missing", "Key": key("user1")}}}, "ResourceNotFoundException"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, out := transact(tt.items...)
+			if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#"+tt.errType {
+				t.Errorf("TransactWriteItems returned status %d: %v, expected %s", status, out, tt.errType)
+			}
+		})
+	}
+}
+
+// TEST A13
+
+// TestApiGlobalSecondaryIndex ensures tables created with GlobalSecondaryIndexes are queried by
+// IndexName a page at a time, and DescribeTable reports the status of their indexes
+func TestApiGlobalSecondaryIndex(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+
+	create := map[string]interface{}{
+		"TableName": "users",
+		"KeySchema": []map[string]string{{"AttributeName": "id", "KeyType": "HASH"}},
+		"AttributeDefinitions": []map[string]string{
+			{"AttributeName": "id", "AttributeType": "S"},
+			{"AttributeName": "city", "AttributeType": "S"},
+			{"AttributeName": "age", "AttributeType": "N"},
+		},
+		"GlobalSecondaryIndexes": []map[string]interface{}{{
+			"IndexName":  "by_city",
+			"KeySchema":  []map[string]string{{"AttributeName": "city", "KeyType": "HASH"}, {"AttributeName": "age", "KeyType": "RANGE"}},
+			"Projection": map[string]string{"ProjectionType": "ALL"},
+		}},
+	}
+	if status, out := callApi(t, server.URL, "CreateTable", create); status != http.StatusOK {
+		t.Fatalf("CreateTable returned status %d: %v", status, out)
+	}
+
+	for id, age := range map[string]string{"user1": "31", "user2": "25", "user3": "42"} {
+		item := map[string]interface{}{"id": map[string]string{"S": id}, "city": map[string]string{"S": "paris"}, "age": map[string]string{"N": age}}
+		if status, out := callApi(t, server.URL, "PutItem", map[string]interface{}{"TableName": "users", "Item": item}); status != http.StatusOK {
+			t.Fatalf("PutItem returned status %d: %v", status, out)
+		}
+	}
+
+	query := map[string]interface{}{
+		"TableName":                 "users",
+		"IndexName":                 "by_city",
+		"KeyConditionExpression":    "city = :city AND age > :age",
+		"ExpressionAttributeValues": map[string]interface{}{":city": map[string]string{"S": "paris"}, ":age": map[string]string{"N": "20"}},
+		"Limit":                     2,
+	}
+	var out map[string]interface{}
+	deadline := time.Now().Add(time.Second)
+	for {
+		var status int
+		if status, out = callApi(t, server.URL, "Query", query); status != http.StatusOK {
+			t.Fatalf("Query returned status %d: %v", status, out)
+		}
+		if out["Count"] == float64(2) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	var ids []string
+	for _, item := range out["Items"].([]interface{}) {
+		ids = append(ids, item.(map[string]interface{})["id"].(map[string]interface{})["S"].(string))
+	}
+	lastKey, _ := json.Marshal(out["LastEvaluatedKey"])
+	if fmt.Sprint(ids) != "[user2 user1]" || string(lastKey) != `{"age":{"N":"31"},"city":{"S":"paris"},"id":{"S":"user1"}}` {
+		t.Fatalf("first page got: %v, LastEvaluatedKey %s", ids, lastKey)
+	}
+
+	query["ExclusiveStartKey"] = out["LastEvaluatedKey"]
+	_, out = callApi(t, server.URL, "Query", query)
+	if items, _ := out["Items"].([]interface{}); len(items) != 1 || out["LastEvaluatedKey"] != nil {
+		t.Errorf("second page got: %v, expected user3", out)
+	}
+
+	_, out = callApi(t, server.URL, "DescribeTable", map[string]interface{}{"TableName": "users"})
+	desc, _ := json.Marshal(out["Table"])
+	var table api.TableDescription
+	json.Unmarshal(desc, &table)
+	if len(table.GlobalSecondaryIndexes) != 1 || table.GlobalSecondaryIndexes[0].IndexName != "by_city" || table.GlobalSecondaryIndexes[0].IndexStatus != "ACTIVE" ||
+		len(table.GlobalSecondaryIndexes[0].KeySchema) != 2 || table.GlobalSecondaryIndexes[0].PendingUpdates != 0 {
+		t.Errorf("DescribeTable got: %s", desc)
+	}
+
+	tests := []struct {
+		name    string
+		op      string
+		request map[string]interface{}
+	}{
+		{"missing_index", "Query", map[string]interface{}{"TableName": "users", "IndexName": "missing", "KeyConditionExpression": "city = :city", "ExpressionAttributeValues": query["ExpressionAttributeValues"]}},
+		{"table_key_condition", "Query", map[string]interface{}{"TableName": "users", "IndexName": "by_city", "KeyConditionExpression": "id = :city", "ExpressionAttributeValues": query["ExpressionAttributeValues"]}},
+		{"undefined_attribute", "CreateTable", map[string]interface{}{"TableName": "other", "KeySchema": create["KeySchema"], "AttributeDefinitions": create["AttributeDefinitions"],
+			"GlobalSecondaryIndexes": []map[string]interface{}{{"IndexName": "by_name", "KeySchema": []map[string]string{{"AttributeName": "name", "KeyType": "HASH"}}}}}},
+		{"keys_only_projection", "CreateTable", map[string]interface{}{"TableName": "other", "KeySchema": create["KeySchema"], "AttributeDefinitions": create["AttributeDefinitions"],
+			"GlobalSecondaryIndexes": []map[string]interface{}{{"IndexName": "by_city", "KeySchema": []map[string]string{{"AttributeName": "city", "KeyType": "HASH"}}, "Projection": map[string]string{"ProjectionType": "KEYS_ONLY"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, out := callApi(t, server.URL, tt.op, tt.request)
+			if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#ValidationException" {
+				t.Errorf("%s returned status %d: %v, expected ValidationException", tt.op, status, out)
+			}
+		})
+	}
+}
+
+// TEST A14
+
+// TestApiVersionConditions ensures PutItem, UpdateItem and DeleteItem conditioned on the version returned
+// by GetItem and PutItem only apply to that version, and unsupported conditions are rejected
+func TestApiVersionConditions(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	server, close_ch := setUpApi(&c)
+	defer close(close_ch)
+	defer server.Close()
+
+	key := map[string]interface{}{"id": map[string]string{"S": "user4"}}
+	ifVersion := func(request map[string]interface{}, version interface{}) map[string]interface{} {
+		request["ConditionExpression"] = "#v = :v"
+		request["ExpressionAttributeNames"] = map[string]string{"#v": "$version"}
+		request["ExpressionAttributeValues"] = map[string]interface{}{":v": map[string]interface{}{"S": version}}
+		return request
+	}
+	put := func(name string, version interface{}) (int, map[string]interface{}) {
+		request := map[string]interface{}{"Item": map[string]interface{}{"id": key["id"], "name": map[string]string{"S": name}}}
+		if version != nil {
+			request = ifVersion(request, version)
+		}
+		return callApi(t, server.URL, "PutItem", request)
+	}
+	failed := func(op string, status int, out map[string]interface{}) {
+		t.Helper()
+		if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException" {
+			t.Errorf("%s of a stale version returned status %d: %v, expected ConditionalCheckFailedException", op, status, out)
+		}
+	}
+
+	status, out := put("first", nil)
+	first := out["Version"]
+	if status != http.StatusOK || first == nil {
+		t.Fatalf("PutItem returned status %d: %v, expected a Version", status, out)
+	}
+	if _, out = callApi(t, server.URL, "GetItem", map[string]interface{}{"Key": key}); out["Version"] != first {
+		t.Fatalf("GetItem returned version %v, expected the version written %v", out["Version"], first)
+	}
+
+	status, out = put("second", first)
+	second := out["Version"]
+	if status != http.StatusOK || second == nil || second == first {
+		t.Fatalf("PutItem of the current version returned status %d: %v", status, out)
+	}
+	status, out = put("third", first)
+	failed("PutItem", status, out)
+
+	update := func(version interface{}) (int, map[string]interface{}) {
+		request := ifVersion(map[string]interface{}{"Key": key, "UpdateExpression": "SET age = :age"}, version)
+		request["ExpressionAttributeValues"].(map[string]interface{})[":age"] = map[string]string{"N": "30"}
+		return callApi(t, server.URL, "UpdateItem", request)
+	}
+	status, out = update(first)
+	failed("UpdateItem", status, out)
+	if status, out = update(second); status != http.StatusOK {
+		t.Fatalf("UpdateItem of the current version returned status %d: %v", status, out)
+	}
+
+	_, out = callApi(t, server.URL, "GetItem", map[string]interface{}{"Key": key})
+	got, _ := json.Marshal(out["Item"])
+	expected := `{"age":{"N":"30"},"id":{"S":"user4"},"name":{"S":"second"}}`
+	if string(got) != expected {
+		t.Errorf("got: %s, expected: %s", got, expected)
+	}
+	current := out["Version"]
+
+	status, out = callApi(t, server.URL, "DeleteItem", ifVersion(map[string]interface{}{"Key": key}, second))
+	failed("DeleteItem", status, out)
+	if status, out = callApi(t, server.URL, "DeleteItem", ifVersion(map[string]interface{}{"Key": key}, current)); status != http.StatusOK {
+		t.Fatalf("DeleteItem of the current version returned status %d: %v", status, out)
+	}
+	status, out = callApi(t, server.URL, "DeleteItem", map[string]interface{}{"Key": key, "ConditionExpression": "attribute_exists(id)"})
+	if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException" {
+		t.Errorf("DeleteItem of a deleted item with attribute_exists returned status %d: %v, expected ConditionalCheckFailedException", status, out)
+	}
+
+	var unsupported = []map[string]interface{}{
+		{"ConditionExpression": "#n = :v", "ExpressionAttributeNames": map[string]string{"#n": "name"}},
+		{"ConditionExpression": "#v > :v", "ExpressionAttributeNames": map[string]string{"#v": "$version"}},
+		{"ConditionExpression": "#v = :v", "ExpressionAttributeNames": map[string]string{"#v": "$version"}, "ExpressionAttributeValues": map[string]interface{}{":v": map[string]string{"S": "one"}}},
+		{"ConditionExpression": "attribute_exists(id) AND #v = :v", "ExpressionAttributeNames": map[string]string{"#v": "$version"}},
+	}
+	for _, request := range unsupported {
+		request["Key"] = key
+		status, out = callApi(t, server.URL, "DeleteItem", request)
+		if status != http.StatusBadRequest || out["__type"] != "com.amazonaws.dynamodb.v20120810#ValidationException" {
+			t.Errorf("DeleteItem with ConditionExpression %v returned status %d: %v, expected ValidationException", request["ConditionExpression"], status, out)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// starts the nodes of a restored cluster
+func startNodes(phy_nodes []*base.Node) {
+	var wg sync.WaitGroup
+	for i := range phy_nodes {
+		wg.Add(1)
+		go phy_nodes[i].Start(&wg)
+	}
+}
+
+// TEST P1
+
+// TestBackupRestore ensures a backup of the tables, items, tombstones and hinted handoff backups of a
+// cluster is restored onto the same layout and onto another NUM_NODES and NUM_TOKENS
+func TestBackupRestore(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+	c.SET_DATA_TIMEOUT_MS = 50
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	if _, err := cl.CreateTable(base.Table{Name: "orders", N: 5, R: 3, W: 3, ConflictResolution: constants.CONFLICT_LAST_WRITER_WINS, Stream: true,
+		Indexes: []base.Index{{Name: "by_status", PartitionAttribute: "status"}}}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	orders := cl.Table("orders")
+	for i := 0; i < 10; i++ {
+		if err := cl.Put(ctx, fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i)); err != nil {
+			t.Fatalf("Put key%d failed: %v", i, err)
+		}
+		key := base.Key{Partition: "customer", Sort: fmt.Sprintf("order%d", i)}
+		if err := orders.PutItem(ctx, key, base.Item{"status": base.S("new")}); err != nil {
+			t.Fatalf("PutItem %s failed: %v", key, err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := cl.Update(ctx, base.Key{Partition: "views"}, client.Add("count", 1)); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	if err := orders.DeleteItem(ctx, base.Key{Partition: "customer", Sort: "order0"}); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+
+	// the second owner of handoff is down, its write is held as a hinted handoff backup by another node
+	token, _ := base.FindNode("handoff", phy_nodes, &c)
+	down := phy_nodes[phy_nodes[0].GetPrefList()[token][1].Token.GetPID()]
+	down.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+	time.Sleep(50 * time.Millisecond)
+	if err := cl.Put(ctx, "handoff", "hinted"); err != nil {
+		t.Fatalf("Put handoff failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	backups := 0
+	for _, node := range phy_nodes {
+		backups += len(node.GetAllBackup()[down.GetID()])
+	}
+	if backups == 0 {
+		t.Fatalf("no node holds a backup for node %d", down.GetID())
+	}
+
+	dir := filepath.Join(t.TempDir(), "snapshot")
+	manifest, err := base.Backup(phy_nodes, dir)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if manifest.NumNodes != 5 || manifest.NumTokens != 10 || len(manifest.Tables) != 1 || manifest.Objects == 0 {
+		t.Errorf("Backup got manifest: %+v", manifest)
+	}
+	if _, err := base.Backup(phy_nodes, dir); err == nil {
+		t.Errorf("Backup to an existing directory succeeded")
+	}
+
+	layouts := []struct {
+		numNodes, numTokens, n int
+	}{
+		{5, 10, 3},
+		{3, 7, 3},
+		{8, 16, 2},
+	}
+	for _, tt := range layouts {
+		t.Run(fmt.Sprintf("%d_nodes_%d_tokens_%d_n", tt.numNodes, tt.numTokens, tt.n), func(t *testing.T) {
+			rc := c
+			rc.NUM_NODES = tt.numNodes
+			rc.NUM_TOKENS = tt.numTokens
+			rc.N = tt.n
+			rc.R = 1
+			rc.W = 1
+			restore_ch := make(chan struct{})
+			defer close(restore_ch)
+			restored, _, err := base.Restore(dir, restore_ch, &rc)
+			if err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+			startNodes(restored)
+			rcl := client.New(restored, &rc)
+
+			// every owner of a key holds its latest version
+			key := base.StorageKey("", "handoff", "")
+			token, _ := base.FindNode("handoff", restored, &rc)
+			for _, owner := range restored[0].GetPrefList()[token][:tt.n] {
+				if data := restored[owner.Token.GetPID()].GetData(key).GetData(); data != "hinted" {
+					t.Errorf("owner %d of handoff holds %q", owner.Token.GetPID(), data)
+				}
+			}
+			for i := 0; i < 10; i++ {
+				if value, err := rcl.Get(ctx, fmt.Sprintf("key%d", i)); err != nil || value != fmt.Sprintf("value%d", i) {
+					t.Errorf("Get key%d got: %q, %v", i, value, err)
+				}
+			}
+			if item, err := rcl.GetItem(ctx, base.Key{Partition: "views"}); err != nil || item["count"].String() != "3" {
+				t.Errorf("GetItem views got: %v, %v, expected count 3", item, err)
+			}
+
+			expectedN := 5
+			if tt.numNodes < expectedN {
+				expectedN = tt.numNodes
+			}
+			table, err := rcl.DescribeTable("orders")
+			if err != nil || table.N != expectedN || table.R > expectedN || table.W > expectedN || len(table.Indexes) != 1 {
+				t.Errorf("DescribeTable got: %+v, %v, expected N %d", table, err, expectedN)
+			}
+			restoredOrders := rcl.Table("orders")
+			if _, err := restoredOrders.GetItem(ctx, base.Key{Partition: "customer", Sort: "order0"}); !errors.Is(err, client.ErrNotFound) {
+				t.Errorf("GetItem of a deleted item got: %v, expected ErrNotFound", err)
+			}
+			page, err := restoredOrders.Query(ctx, "customer", base.Query{})
+			if err != nil || len(page.Items) != 9 {
+				t.Errorf("Query got %d item(s), %v, expected 9", len(page.Items), err)
+			}
+			awaitIndexQuery(t, restoredOrders, "new", base.Query{Index: "by_status", Limit: 3}, []string{"customer", "customer", "customer"})
+			if shards, err := rcl.DescribeStream("orders"); err != nil || len(shards) != 0 {
+				t.Errorf("DescribeStream got: %+v, %v, expected an empty stream", shards, err)
+			}
+
+			// writes after the restore supersede the restored versions
+			if err := rcl.Put(ctx, "key1", "after"); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if value, err := rcl.Get(ctx, "key1"); err != nil || value != "after" {
+				t.Errorf("Get after the restore got: %q, %v", value, err)
+			}
+		})
+	}
+}
+
+// TEST P2
+
+// TestRestoreInvalid ensures missing and incomplete backups are not restored
+func TestRestoreInvalid(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.DEBUG_LEVEL = 1
+
+	dir := t.TempDir()
+	if _, _, err := base.Restore(filepath.Join(dir, "missing"), make(chan struct{}), &c); err == nil {
+		t.Errorf("Restore of a missing backup succeeded")
+	}
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	if _, err := base.Backup(phy_nodes, filepath.Join(dir, "incomplete")); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "incomplete", "manifest.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := base.Restore(filepath.Join(dir, "incomplete"), make(chan struct{}), &c); err == nil {
+		t.Errorf("Restore of a backup without a manifest succeeded")
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TEST B1
+
+// TestBatchGetWrite ensures batch writes of values, items and deletes across
+// tables are read back by a batch get, and invalid batches are rejected
+func TestBatchGetWrite(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+	c.BATCH_MAX_KEYS = 40
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	if _, err := cl.CreateTable(base.Table{Name: "orders", N: 2, R: 1, W: 1}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+
+	var writes []client.BatchWrite
+	var keys []client.BatchKey
+	for i := 0; i < 10; i++ {
+		value := client.BatchWrite{BatchKey: client.BatchKey{Key: base.Key{Partition: fmt.Sprintf("value%d", i)}}, Value: fmt.Sprint(i)}
+		item := client.BatchWrite{BatchKey: client.BatchKey{Table: "orders", Key: base.Key{Partition: "user1", Sort: fmt.Sprintf("order%d", i)}}, Item: base.Item{"total": base.N(fmt.Sprint(i))}}
+		writes = append(writes, value, item)
+		keys = append(keys, value.BatchKey, item.BatchKey)
+	}
+
+	result, err := cl.BatchWrite(ctx, writes)
+	if err != nil || len(result.Unprocessed) != 0 {
+		t.Fatalf("BatchWrite got: %v, unprocessed: %v", err, result.Unprocessed)
+	}
+
+	missing := client.BatchKey{Table: "orders", Key: base.Key{Partition: "missing"}}
+	got, err := cl.BatchGet(ctx, append(keys, missing))
+	if err != nil || len(got.Unprocessed) != 0 || len(got.Items) != len(keys) {
+		t.Fatalf("BatchGet got %d items: %v, unprocessed: %v", len(got.Items), err, got.Unprocessed)
+	}
+	for i := 0; i < 10; i++ {
+		if value := got.Items[keys[2*i]]; value.Data != fmt.Sprint(i) || value.Attrs != nil {
+			t.Errorf("BatchGet of %s got: %+v, expected %d", keys[2*i], value, i)
+		}
+		if item := got.Items[keys[2*i+1]].Attrs; item["total"].String() != fmt.Sprint(i) {
+			t.Errorf("BatchGet of %s got: %s, expected total %d", keys[2*i+1], item, i)
+		}
+	}
+
+	deletes := make([]client.BatchWrite, len(keys))
+	for i, key := range keys {
+		deletes[i] = client.BatchWrite{BatchKey: key, Delete: true}
+	}
+	if result, err := cl.BatchWrite(ctx, deletes); err != nil || len(result.Unprocessed) != 0 {
+		t.Fatalf("BatchWrite of deletes got: %v, unprocessed: %v", err, result.Unprocessed)
+	}
+	if got, err := cl.BatchGet(ctx, keys); err != nil || len(got.Items) != 0 {
+		t.Errorf("BatchGet of deleted keys got: %v, %v, expected no items", got.Items, err)
+	}
+
+	tooMany := make([]client.BatchKey, c.BATCH_MAX_KEYS+1)
+	for i := range tooMany {
+		tooMany[i] = client.BatchKey{Key: base.Key{Partition: fmt.Sprint(i)}}
+	}
+	invalid := []struct {
+		name     string
+		keys     []client.BatchKey
+		writes   []client.BatchWrite
+		expected error
+	}{
+		{"empty", []client.BatchKey{}, nil, client.ErrInvalidRequest},
+		{"too_many", tooMany, nil, client.ErrInvalidRequest},
+		{"duplicate_key", []client.BatchKey{keys[0], keys[1], keys[0]}, nil, client.ErrInvalidRequest},
+		{"missing_table", []client.BatchKey{keys[0], {Table: "missing", Key: base.Key{Partition: "k"}}}, nil, client.ErrTableNotFound},
+		{"invalid_item", nil, []client.BatchWrite{{BatchKey: keys[0], Item: base.Item{"n": base.N("abc")}}}, client.ErrInvalidRequest},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.writes != nil {
+				_, err = cl.BatchWrite(ctx, tt.writes)
+			} else {
+				_, err = cl.BatchGet(ctx, tt.keys)
+			}
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("got: %v, expected %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+// TEST B2
+
+// TestBatchUnprocessed ensures writes that miss their write quorum are
+// returned as unprocessed and succeed when retried
+func TestBatchUnprocessed(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.N = 3
+	c.R = 1
+	c.W = 3
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_GET_TIMEOUT_MS = 300
+	c.CLIENT_PUT_TIMEOUT_MS = 1000
+	c.SET_DATA_TIMEOUT_MS = 200
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	var writes []client.BatchWrite
+	for i := 0; i < 10; i++ {
+		writes = append(writes, client.BatchWrite{BatchKey: client.BatchKey{Key: base.Key{Partition: fmt.Sprintf("key%d", i)}}, Value: fmt.Sprint(i)})
+	}
+
+	phy_nodes[0].GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+	time.Sleep(100 * time.Millisecond)
+
+	result, err := cl.BatchWrite(ctx, writes)
+	if err != nil || len(result.Unprocessed) != len(writes) {
+		t.Fatalf("BatchWrite with a node down got: %v, %d unprocessed, expected all %d", err, len(result.Unprocessed), len(writes))
+	}
+
+	phy_nodes[0].GetChannel() <- base.Message{Command: constants.CLIENT_REQ_REVIVE, SrcID: -1}
+	time.Sleep(100 * time.Millisecond)
+
+	result, err = cl.BatchWrite(ctx, result.Unprocessed)
+	if err != nil || len(result.Unprocessed) != 0 {
+		t.Fatalf("BatchWrite retry got: %v, unprocessed: %v", err, result.Unprocessed)
+	}
+	for _, write := range writes {
+		if value, err := cl.Get(ctx, write.Key.Partition); err != nil || value != write.Value {
+			t.Errorf("Get %s got: %s, %v, expected %s", write.Key.Partition, value, err, write.Value)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"base"
+	"bytes"
+	"client"
+	"config"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// returns the live items of table by key, read through a scan
+func scanTable(t *testing.T, table *client.Table) map[base.Key]base.QueryItem {
+	t.Helper()
+	items := make(map[base.Key]base.QueryItem)
+	scan := base.Scan{}
+	for {
+		page, err := table.Scan(context.Background(), scan)
+		if err != nil {
+			t.Fatalf("Scan of %q failed: %v", table.Name(), err)
+		}
+		for _, item := range page.Items {
+			item.Version = nil
+			items[base.Key{Partition: item.Key, Sort: item.SortKey}] = item
+		}
+		if page.LastKey == nil {
+			return items
+		}
+		scan.StartKey = page.LastKey
+	}
+}
+
+// TEST F1
+
+// TestImportExport ensures items and plain values imported from JSON Lines and CSV are written
+// in file order, and exported back to both formats
+func TestImportExport(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	for _, name := range []string{"users", "copy_jsonl", "copy_csv"} {
+		if _, err := cl.CreateTable(base.Table{Name: name}); err != nil {
+			t.Fatalf("CreateTable %s failed: %v", name, err)
+		}
+	}
+
+	var input strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&input, `{"Key":"user%d","Item":{"age":{"N":"%d"},"city":{"S":"paris"},"admin":{"BOOL":false}}}`+"\n", i, 20+i)
+	}
+	input.WriteString(`{"Key":"plain","SortKey":"a","Value":"hello"}` + "\n\n")
+	input.WriteString(`{"Key":"user0","Item":{"age":{"N":"99"},"city":{"S":"rome"},"note":{"NULL":true}}}` + "\n") // written after user0
+
+	users := cl.Table("users")
+	var calls []client.BulkProgress
+	progress, err := users.Import(ctx, strings.NewReader(input.String()), client.BulkOptions{BatchSize: 8, Concurrency: 3, Progress: func(p client.BulkProgress) {
+		calls = append(calls, p)
+	}})
+	if err != nil || progress.Items != 52 || progress.Failed != 0 {
+		t.Fatalf("Import got: %+v, %v, expected 52 items", progress, err)
+	}
+	if len(calls) < 7 || calls[len(calls)-1] != progress {
+		t.Errorf("Progress got: %+v", calls)
+	}
+	if item, err := users.GetItem(ctx, base.Key{Partition: "user0"}); err != nil || item["city"].String() != `"rome"` || item["age"].String() != "99" {
+		t.Errorf("GetItem user0 got: %v, %v, expected the last record of user0", item, err)
+	}
+	if value, err := users.Read(ctx, base.Key{Partition: "plain", Sort: "a"}); err != nil || value.Data != "hello" || value.Attrs != nil {
+		t.Errorf("Read plain got: %+v, %v", value, err)
+	}
+	expected := scanTable(t, users)
+	if len(expected) != 51 {
+		t.Fatalf("users holds %d items, expected 51", len(expected))
+	}
+
+	formats := []struct {
+		format, table string
+	}{
+		{client.FormatJSONLines, "copy_jsonl"},
+		{client.FormatCSV, "copy_csv"},
+	}
+	for _, tt := range formats {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			progress, err := users.Export(ctx, &out, client.BulkOptions{Format: tt.format, BatchSize: 10, Concurrency: 2})
+			if err != nil || progress.Items != 51 {
+				t.Fatalf("Export got: %+v, %v, expected 51 items", progress, err)
+			}
+			if tt.format == client.FormatCSV && !strings.HasPrefix(out.String(), "key,sort_key,value,admin,age,city,note\n") {
+				t.Errorf("CSV header got: %q", strings.SplitN(out.String(), "\n", 2)[0])
+			}
+			if _, err := cl.Table(tt.table).Import(ctx, &out, client.BulkOptions{Format: tt.format}); err != nil {
+				t.Fatalf("Import of the export failed: %v", err)
+			}
+			if got := scanTable(t, cl.Table(tt.table)); !reflect.DeepEqual(got, expected) {
+				t.Errorf("%s holds %d items: %v, expected %v", tt.table, len(got), got, expected)
+			}
+		})
+	}
+
+	// rows are typed as the CLI types values, empty cells are absent attributes
+	csvInput := "key,sort_key,value,name,score\nann,,,\"\"\"42\"\"\",7\nbob,x,v,,\n"
+	if _, err := cl.Table("").Import(ctx, strings.NewReader(csvInput), client.BulkOptions{Format: client.FormatCSV}); err != nil {
+		t.Fatalf("Import CSV failed: %v", err)
+	}
+	if item, err := cl.GetItem(ctx, base.Key{Partition: "ann"}); err != nil || item["name"].Type() != "S" || item["score"].Type() != "N" {
+		t.Errorf("GetItem ann got: %v, %v, expected a string name and a number score", item, err)
+	}
+	if value, err := cl.Read(ctx, base.Key{Partition: "bob", Sort: "x"}); err != nil || value.Data != "v" || value.Attrs != nil {
+		t.Errorf("Read bob got: %+v, %v, expected a plain value", value, err)
+	}
+}
+
+// TEST F2
+
+// TestImportInvalid ensures malformed files stop an import at their first invalid record, and items that
+// cannot be written to CSV are not exported
+func TestImportInvalid(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+	if _, err := cl.CreateTable(base.Table{Name: "users"}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	users := cl.Table("users")
+
+	invalid := []struct {
+		name, format, input, line string
+	}{
+		{"json", client.FormatJSONLines, "{\"Key\":\"a\",\"Value\":\"1\"}\n{\"Key\":\n", "line 2"},
+		{"empty_key", client.FormatJSONLines, "{\"Key\":\"a\",\"Value\":\"1\"}\n\n{\"Value\":\"2\"}\n", "line 3"},
+		{"value_and_item", client.FormatJSONLines, "{\"Key\":\"a\",\"Value\":\"1\",\"Item\":{\"x\":{\"N\":\"1\"}}}\n", "line 1"},
+		{"no_key_column", client.FormatCSV, "id,value\na,1\n", "line 1"},
+		{"fields", client.FormatCSV, "key,value\na,1\nb\n", "line 3"},
+		{"value_and_attribute", client.FormatCSV, "key,value,x\na,1,2\n", "line 2"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := users.Import(ctx, strings.NewReader(tt.input), client.BulkOptions{Format: tt.format})
+			if !errors.Is(err, client.ErrInvalidRequest) || !strings.Contains(err.Error(), tt.line) {
+				t.Errorf("Import got: %v, expected ErrInvalidRequest at %s", err, tt.line)
+			}
+		})
+	}
+	if value, err := users.Read(ctx, base.Key{Partition: "a"}); err != nil || value.Data != "1" {
+		t.Errorf("Read of the record before a malformed one got: %+v, %v", value, err)
+	}
+
+	if _, err := cl.Table("missing").Import(ctx, strings.NewReader(""), client.BulkOptions{}); !errors.Is(err, client.ErrTableNotFound) {
+		t.Errorf("Import to a missing table got: %v, expected ErrTableNotFound", err)
+	}
+	if _, err := users.Import(ctx, strings.NewReader(""), client.BulkOptions{Format: "xml"}); !errors.Is(err, client.ErrInvalidRequest) {
+		t.Errorf("Import of an unknown format got: %v, expected ErrInvalidRequest", err)
+	}
+	if err := users.PutItem(ctx, base.Key{Partition: "tags"}, base.Item{"tags": base.SS("a", "b")}); err != nil {
+		t.Fatalf("PutItem failed: %v", err)
+	}
+	if _, err := users.Export(ctx, &bytes.Buffer{}, client.BulkOptions{Format: client.FormatCSV}); !errors.Is(err, client.ErrInvalidRequest) {
+		t.Errorf("Export of a set to CSV got: %v, expected ErrInvalidRequest", err)
+	}
+	if client.FormatOf("users.CSV") != client.FormatCSV || client.FormatOf("users.jsonl") != client.FormatJSONLines {
+		t.Errorf("FormatOf got: %s, %s", client.FormatOf("users.CSV"), client.FormatOf("users.jsonl"))
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"base"
 	"config"
 	"constants"
+	"errors"
 	"fmt"
 	"sync"
-	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -15,11 +15,7 @@ func setupClients(numClients int, close_ch chan struct{}, c *config.Config) map[
 	clients := make(map[int](*base.Client))
 
 	for j := 0; j < numClients; j++ {
-		clients[j] = &base.Client{
-			Id:        j,
-			Close:     close_ch,
-			Client_ch: make(chan base.Message),
-			AwaitUids: make(map[int]*atomic.Bool)}
+		clients[j] = base.NewClient(j, close_ch)
 		go clients[j].StartListening(c)
 	}
 
@@ -73,7 +69,7 @@ func TestSingleClientGet(t *testing.T) {
 			var wg sync.WaitGroup
 			for i := range phy_nodes {
 				wg.Add(1)
-				go phy_nodes[i].Start(&wg, &c)
+				go phy_nodes[i].Start(&wg)
 			}
 
 			//NOTE: if this time.Sleep is excluded, data may not be fully replicated before the read
@@ -152,7 +148,7 @@ func TestMultipleClientMultiplePutSingleGet(t *testing.T) {
 			var wg sync.WaitGroup
 			for i := range phy_nodes {
 				wg.Add(1)
-				go phy_nodes[i].Start(&wg, &c)
+				go phy_nodes[i].Start(&wg)
 			}
 
 			for i, key := range keys {
@@ -242,7 +238,7 @@ func TestMultipleClientMultiplePutMultipleGet(t *testing.T) {
 			var wg sync.WaitGroup
 			for i := range phy_nodes {
 				wg.Add(1)
-				go phy_nodes[i].Start(&wg, &c)
+				go phy_nodes[i].Start(&wg)
 			}
 
 			//this put is sequential but technically it is how the client is working as well
@@ -294,3 +290,150 @@ func TestMultipleClientMultiplePutMultipleGet(t *testing.T) {
 		})
 	}
 }
+
+// TEST C3
+
+// TestClientPipelinedRequests ensures a single client can have many requests
+// in flight, each future completing with the reply of its own job id
+func TestClientPipelinedRequests(t *testing.T) {
+	var tests = []struct {
+		numNodes, numTokens, nValue, rAndWValue, numKeys int
+	}{
+		{1, 1, 1, 1, 20},
+		{5, 10, 3, 2, 50},
+		{10, 20, 5, 3, 100},
+	}
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%d_nodes_%d_tokens_%d_n_%d_rAndW_%d_keys", tt.numNodes, tt.numTokens, tt.nValue, tt.rAndWValue, tt.numKeys)
+		t.Run(testname, func(t *testing.T) {
+			c := config.InstantiateConfig()
+			c.NUM_NODES = tt.numNodes
+			c.NUM_TOKENS = tt.numTokens
+			c.N = tt.nValue
+			c.R = tt.rAndWValue
+			c.W = tt.rAndWValue
+			c.DEBUG_LEVEL = 1
+			c.CLIENT_GET_TIMEOUT_MS = 5_000
+			c.CLIENT_PUT_TIMEOUT_MS = 5_000
+
+			phy_nodes, close_ch, _ := setUpNodes(&c)
+			defer close(close_ch)
+			client := setupClients(1, close_ch, &c)[0]
+
+			keyValuePairs := generateRandomKeyValuePairs(20, 100, tt.numKeys)
+			keys := generateOrderedKeys(keyValuePairs)
+
+			// all puts are in flight before any reply is awaited
+			puts := []*base.Future{}
+			for i, key := range keys {
+				_, node := base.FindNode(key, phy_nodes, &c)
+				msg := base.Message{JobId: i, Key: key, Command: constants.CLIENT_REQ_WRITE, Data: keyValuePairs[key]}
+				puts = append(puts, client.Send(node.GetChannel(), msg, c.CLIENT_PUT_TIMEOUT_MS))
+			}
+			_, errs := base.WaitAll(puts)
+			for i, err := range errs {
+				if err != nil {
+					t.Fatalf("put(%s) failed: %s", keys[i], err)
+				}
+			}
+
+			gets := []*base.Future{}
+			for i, key := range keys {
+				_, node := base.FindNode(key, phy_nodes, &c)
+				msg := base.Message{JobId: len(keys) + i, Key: key, Command: constants.CLIENT_REQ_READ}
+				gets = append(gets, client.Send(node.GetChannel(), msg, c.CLIENT_GET_TIMEOUT_MS))
+			}
+			// wait in reverse order, replies arrive in any order
+			for i := len(gets) - 1; i >= 0; i-- {
+				reply, err := gets[i].Wait()
+				if err != nil || reply.JobId != gets[i].JobId || reply.Data != keyValuePairs[keys[i]] {
+					t.Errorf("get(%s) got: %s (%v), expected: %s", keys[i], reply.Data, err, keyValuePairs[keys[i]])
+				}
+			}
+			if client.Pending() != 0 {
+				t.Errorf("%d requests still pending after all futures completed", client.Pending())
+			}
+		})
+	}
+}
+
+// TEST C4
+
+// TestClientFutureErrors ensures futures fail with typed errors
+func TestClientFutureErrors(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 1
+	c.NUM_TOKENS = 1
+	c.N = 1
+	c.R = 1
+	c.W = 1
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	client := setupClients(1, close_ch, &c)[0]
+	node_ch := phy_nodes[0].GetChannel()
+	node_ch <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+
+	t.Run("timeout", func(t *testing.T) {
+		future := client.Send(node_ch, base.Message{JobId: 0, Key: "hello", Command: constants.CLIENT_REQ_READ}, 100)
+		if _, err := future.Wait(); !errors.Is(err, base.ErrRequestTimeout) {
+			t.Errorf("got: %v, expected ErrRequestTimeout", err)
+		}
+	})
+
+	t.Run("duplicate_job_id", func(t *testing.T) {
+		first := client.Send(node_ch, base.Message{JobId: 1, Key: "hello", Command: constants.CLIENT_REQ_READ}, 5_000)
+		second := client.Send(node_ch, base.Message{JobId: 1, Key: "hello", Command: constants.CLIENT_REQ_READ}, 5_000)
+		if _, err := second.Wait(); !errors.Is(err, base.ErrDuplicateJob) {
+			t.Errorf("got: %v, expected ErrDuplicateJob", err)
+		}
+
+		close(close_ch)
+		if _, err := first.Wait(); !errors.Is(err, base.ErrClientClosed) {
+			t.Errorf("got: %v, expected ErrClientClosed", err)
+		}
+	})
+}
+
+// TEST C5
+
+// TestClientUnclaimedReplies ensures replies nobody waits on, and job ids that timed out without a reply,
+// are dropped once older than the client timeouts, while replies processed before their await are claimed
+func TestClientUnclaimedReplies(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_GET_TIMEOUT_MS = 100
+	c.CLIENT_PUT_TIMEOUT_MS = 100
+
+	close_ch := make(chan struct{})
+	defer close(close_ch)
+	client := setupClients(1, close_ch, &c)[0]
+
+	// a reply processed before its await completes it
+	client.Client_ch <- base.Message{JobId: 0, Command: constants.CLIENT_ACK_WRITE, Key: "k"}
+	if !client.StartTimeout(0, constants.CLIENT_REQ_WRITE, 100) {
+		t.Errorf("StartTimeout of a reply processed before it failed")
+	}
+
+	// requests that time out, and replies to job ids nobody waits on
+	node_ch := make(chan base.Message, 10)
+	for i := 1; i <= 10; i++ {
+		if _, err := client.Send(node_ch, base.Message{JobId: i, Command: constants.CLIENT_REQ_READ}, 10).Wait(); !errors.Is(err, base.ErrRequestTimeout) {
+			t.Fatalf("Send got: %v, expected ErrRequestTimeout", err)
+		}
+	}
+	for i := 100; i < 200; i++ {
+		client.Client_ch <- base.Message{JobId: i, Command: constants.CLIENT_ACK_WRITE, Key: "late"}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if client.Unclaimed() != 110 {
+		t.Errorf("Unclaimed got: %d, expected 110", client.Unclaimed())
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	client.Client_ch <- base.Message{JobId: 200, Command: constants.CLIENT_ACK_WRITE, Key: "late"}
+	time.Sleep(10 * time.Millisecond)
+	if client.Unclaimed() != 1 {
+		t.Errorf("Unclaimed after the client timeouts got: %d, expected 1", client.Unclaimed())
+	}
+}
@@ -0,0 +1,276 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// returns a lookup of the environment variables env
+func lookupEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, exists := env[name]
+		return value, exists
+	}
+}
+
+// TEST O1
+
+// TestConfigSources ensures settings are loaded from JSON, YAML and TOML files, environment
+// variables and flags, each overriding the previous ones
+func TestConfigSources(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"cluster.json": `{"NUM_NODES": 7, "num_tokens": 14, "N": 3}`,
+		"cluster.yaml": "---\n# cluster\nNUM_NODES: 7\nnum_tokens: \"14\" # quoted\nN: 3\n",
+		"cluster.toml": "# cluster\nNUM_NODES = 7\nnum_tokens = 14\n\nN = 3\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags := config.RegisterFlags(fs)
+			if err := fs.Parse([]string{"-config", path, "-r", "1"}); err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			c := config.InstantiateConfig()
+			configured, err := flags.Load(&c, lookupEnv(map[string]string{"DYNAMO_N": "2", "DYNAMO_W": "2", "DYNAMO_R": "2"}))
+			if err != nil || !configured {
+				t.Fatalf("Load got: %v, %v", configured, err)
+			}
+			if c.NUM_NODES != 7 || c.NUM_TOKENS != 14 || c.N != 2 || c.W != 2 || c.R != 1 || c.BATCH_MAX_KEYS != config.BATCH_MAX_KEYS {
+				t.Errorf("Load got: %+v", c)
+			}
+		})
+	}
+
+	// without a file, variable or flag the settings are left to the caller
+	c := config.InstantiateConfig()
+	if configured, err := config.RegisterFlags(flag.NewFlagSet("test", flag.ContinueOnError)).Load(&c, lookupEnv(nil)); configured || err != nil {
+		t.Errorf("Load without a source got: %v, %v", configured, err)
+	}
+}
+
+// TEST O2
+
+// TestConfigInvalid ensures unknown settings, malformed values and settings out of range are rejected
+func TestConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+	invalid := []struct {
+		name, file, content string
+		args                []string
+		env                 map[string]string
+		expected            string
+	}{
+		{"unknown_setting", "c.yaml", "REPLICAS: 3\n", nil, nil, `unknown setting "REPLICAS"`},
+		{"not_integer", "c.toml", "N = three\n", nil, nil, "not an integer"},
+		{"no_separator", "c.toml", "N 3\n", nil, nil, "c.toml:1: expected name = value"},
+		{"section", "c.toml", "N = 3\n[cluster]\nR = 2\n", nil, nil, "c.toml:2: sections are not supported"},
+		{"indented", "c.yaml", "cluster:\n  N: 3\n", nil, nil, "c.yaml:1: multi-line values are not supported"},
+		{"nested", "c.yaml", "N: 3\n  R: 2\n", nil, nil, "c.yaml:2: indented lines are not supported"},
+		{"list", "c.yaml", "N: 3\n- R: 2\n", nil, nil, "c.yaml:2: lists are not supported"},
+		{"block", "c.yaml", "N: |\n  3\n", nil, nil, "c.yaml:1: multi-line values are not supported"},
+		{"inline_list", "c.toml", "N = [3]\n", nil, nil, "c.toml:1: lists and tables are not supported"},
+		{"quoted_hash", "c.yaml", "N: \"3 # not a comment\"\n", nil, nil, `c.yaml:1: setting N: "3 # not a comment" is not an integer`},
+		{"open_quote", "c.toml", "N = \"3\n", nil, nil, "c.toml:1: quoted values must end on their line"},
+		{"json", "c.json", `{"N": 1.5}`, nil, nil, "not an integer"},
+		{"format", "c.ini", "N=3\n", nil, nil, "unknown config format"},
+		{"env", "", "", nil, map[string]string{"DYNAMO_R": "x"}, "DYNAMO_R"},
+		{"n_above_nodes", "", "", []string{"-num_nodes", "3", "-n", "4"}, nil, "N must be at most NUM_NODES=3"},
+		{"r_above_n", "", "", []string{"-n", "2", "-r", "3", "-w", "1"}, nil, "R must be at most N=2"},
+		{"not_positive", "", "", []string{"-set_data_timeout_ms", "0"}, nil, "SET_DATA_TIMEOUT_MS must be positive"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				path := filepath.Join(dir, tt.file)
+				if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				args = append(args, "-config", path)
+			}
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags := config.RegisterFlags(fs)
+			if err := fs.Parse(args); err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			c := config.InstantiateConfig()
+			if _, err := flags.Load(&c, lookupEnv(tt.env)); err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Load got: %v, expected %q", err, tt.expected)
+			}
+		})
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&strings.Builder{})
+	config.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-n", "many"}); err == nil {
+		t.Errorf("Parse of a flag that is not an integer succeeded")
+	}
+}
+
+// TEST O3
+
+// TestConfigValidate ensures Validate reports every setting out of range as a typed error, and
+// settings weakening consistency or availability as typed warnings
+func TestConfigValidate(t *testing.T) {
+	if problems := (&config.Config{}).Validate(); len(problems) != 10 || problems.Err() == nil {
+		t.Errorf("Validate of a zero config got %d problem(s): %v", len(problems), problems)
+	}
+	c := config.InstantiateConfig()
+	if problems := c.Validate(); len(problems) != 0 {
+		t.Errorf("Validate of the defaults got: %v", problems)
+	}
+
+	tests := []struct {
+		name     string
+		set      func(c *config.Config)
+		setting  string
+		expected error
+		warning  bool
+	}{
+		{"negative_debug_level", func(c *config.Config) { c.DEBUG_LEVEL = -1 }, "DEBUG_LEVEL", config.ErrNotPositive, false},
+		{"zero_tokens", func(c *config.Config) { c.NUM_TOKENS = 0 }, "NUM_TOKENS", config.ErrNotPositive, false},
+		{"n_above_nodes", func(c *config.Config) { c.NUM_NODES = 4 }, "N", config.ErrNAboveNodes, false},
+		{"r_above_n", func(c *config.Config) { c.R = 6 }, "R", config.ErrQuorumAboveN, false},
+		{"w_above_n", func(c *config.Config) { c.W = 6 }, "W", config.ErrQuorumAboveN, false},
+		{"weak_consistency", func(c *config.Config) { c.R, c.W = 2, 3 }, "W", config.WarnWeakConsistency, true},
+		{"n_above_tokens", func(c *config.Config) { c.NUM_TOKENS = 4 }, "N", config.WarnNAboveTokens, true},
+		{"replication_timeout", func(c *config.Config) { c.SET_DATA_TIMEOUT_MS = c.CLIENT_PUT_TIMEOUT_MS }, "SET_DATA_TIMEOUT_MS", config.WarnReplicationTimeout, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config.InstantiateConfig()
+			tt.set(&c)
+			problems := c.Validate()
+			if len(problems) != 1 || !errors.Is(problems[0], tt.expected) || problems[0].Setting != tt.setting || problems[0].Warning != tt.warning {
+				t.Fatalf("Validate got: %+v, expected a %v of %s", problems, tt.expected, tt.setting)
+			}
+			if err := problems.Err(); (err == nil) != tt.warning || (err != nil && !errors.Is(err, tt.expected)) {
+				t.Errorf("Err got: %v", err)
+			}
+			if len(problems.Warnings()) != len(problems.Of(tt.setting).Warnings()) {
+				t.Errorf("Warnings got: %v", problems.Warnings())
+			}
+		})
+	}
+}
+
+// TEST O4
+
+// TestUpdateConfig ensures settings updated on a live cluster reach every node, killed ones included, while
+// clients write, and tables that did not set them, and that settings of the ring and invalid combinations are rejected
+func TestUpdateConfig(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	other := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	// writes keep succeeding while R and W change under them
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 50; i++ {
+			if err := other.Put(ctx, fmt.Sprintf("key%d", i), "value"); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for i := 0; i < 10; i++ {
+		if _, err := cl.UpdateConfig("R", 1+i%3); err != nil {
+			t.Fatalf("UpdateConfig R failed: %v", err)
+		}
+		if _, err := cl.UpdateConfig("w", 1+i%2); err != nil {
+			t.Fatalf("UpdateConfig W failed: %v", err)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Put while updating the config failed: %v", err)
+	}
+
+	phy_nodes[0].GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+	time.Sleep(10 * time.Millisecond)
+	updated, err := cl.UpdateConfig("CLIENT_GET_TIMEOUT_MS", 2000)
+	if err != nil || updated.CLIENT_GET_TIMEOUT_MS != 2000 || updated.R != 1 || updated.W != 2 {
+		t.Fatalf("UpdateConfig got: %+v, %v", updated, err)
+	}
+	for _, n := range phy_nodes {
+		if *n.Config() != updated {
+			t.Errorf("node %d got: %+v, expected %+v", n.GetID(), *n.Config(), updated)
+		}
+	}
+	if c.R != 2 || c.CLIENT_GET_TIMEOUT_MS != config.CLIENT_GET_TIMEOUT_MS {
+		t.Errorf("UpdateConfig changed the config the cluster was created with: %+v", c)
+	}
+
+	invalid := []struct {
+		name     string
+		value    int
+		expected error
+	}{
+		{"R", 4, config.ErrQuorumAboveN},
+		{"SET_DATA_TIMEOUT_MS", 0, config.ErrNotPositive},
+		{"N", 2, config.ErrNotLive},
+		{"NUM_NODES", 6, config.ErrNotLive},
+		{"REPLICAS", 3, client.ErrInvalidRequest},
+	}
+	for _, tt := range invalid {
+		if _, err := cl.UpdateConfig(tt.name, tt.value); !errors.Is(err, client.ErrInvalidRequest) || !errors.Is(err, tt.expected) {
+			t.Errorf("UpdateConfig %s=%d got: %v, expected %v", tt.name, tt.value, err, tt.expected)
+		}
+	}
+	if *phy_nodes[1].Config() != updated {
+		t.Errorf("rejected updates changed the config to: %+v", *phy_nodes[1].Config())
+	}
+
+	phy_nodes[0].GetChannel() <- base.Message{Command: constants.CLIENT_REQ_REVIVE, SrcID: -1}
+	if err := cl.Put(ctx, "revived", "value"); err != nil {
+		t.Errorf("Put after reviving failed: %v", err)
+	}
+	if value, err := other.Get(ctx, "revived"); err != nil || value != "value" {
+		t.Errorf("Get got: %q, %v", value, err)
+	}
+
+	// tables created without R follow the cluster, tables created with one keep it
+	for _, table := range []base.Table{{Name: "follows"}, {Name: "pinned", R: 1}} {
+		if _, err := cl.CreateTable(table); err != nil {
+			t.Fatalf("CreateTable %s failed: %v", table.Name, err)
+		}
+	}
+	if _, err := cl.UpdateConfig("R", 3); err != nil {
+		t.Fatalf("UpdateConfig R failed: %v", err)
+	}
+	if desc, err := cl.DescribeTable("follows"); err != nil || desc.R != 3 || desc.W != 2 {
+		t.Errorf("DescribeTable follows got: %+v, %v, expected R 3 and W 2", desc, err)
+	}
+	if desc, err := cl.DescribeTable("pinned"); err != nil || desc.R != 1 {
+		t.Errorf("DescribeTable pinned got: %+v, %v, expected R 1", desc, err)
+	}
+	if err := cl.Table("follows").PutItem(ctx, base.Key{Partition: "item"}, base.Item{}); err != nil {
+		t.Errorf("PutItem follows failed: %v", err)
+	}
+}
@@ -19,13 +19,18 @@ func setUpNodes(c *config.Config) ([]*base.Node, chan struct{}, chan base.Messag
 	close_ch := make(chan struct{})
 	client_ch := make(chan base.Message)
 
+	// tests may run configs with errors on purpose, the nodes clamp them and print the errors
+	for _, warning := range c.Validate().Warnings() {
+		fmt.Println(warning)
+	}
+
 	//node and token initialization
 	phy_nodes := base.CreateNodes(close_ch, c)
 	base.InitializeTokens(phy_nodes, c)
 	fmt.Println("Setup nodes completed..")
 	for i := range phy_nodes {
 		wg.Add(1)
-		go phy_nodes[i].Start(&wg, c)
+		go phy_nodes[i].Start(&wg)
 	}
 
 	return phy_nodes, close_ch, client_ch
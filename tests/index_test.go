@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// returns the partition keys of the items of the index query, waiting up to a second for them to be expected
+func awaitIndexQuery(t *testing.T, table *client.Table, partitionKey string, q base.Query, expected []string) {
+	t.Helper()
+	var got []string
+	deadline := time.Now().Add(time.Second)
+	for {
+		page, err := table.Query(context.Background(), partitionKey, q)
+		if err != nil {
+			t.Fatalf("Query of index %s partition %s failed: %v", q.Index, partitionKey, err)
+		}
+		got = nil
+		for _, item := range page.Items {
+			got = append(got, item.Key)
+		}
+		if reflect.DeepEqual(got, expected) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Query of index %s partition %s got: %v, expected: %v", q.Index, partitionKey, got, expected)
+	}
+}
+
+// TEST N1
+
+// TestIndexQuery ensures global secondary indexes follow the puts, updates, deletes and
+// transactions of their table, and are queried in index sort order a page at a time
+func TestIndexQuery(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	invalid := [][]base.Index{
+		{{Name: "x", PartitionAttribute: "city"}},
+		{{Name: "by_city"}},
+		{{Name: "by_city", PartitionAttribute: "city"}, {Name: "by_city", PartitionAttribute: "age"}},
+	}
+	for _, indexes := range invalid {
+		if _, err := cl.CreateTable(base.Table{Name: "users", Indexes: indexes}); !errors.Is(err, client.ErrInvalidRequest) {
+			t.Errorf("CreateTable with indexes %+v got: %v, expected ErrInvalidRequest", indexes, err)
+		}
+	}
+
+	_, err := cl.CreateTable(base.Table{Name: "users", Indexes: []base.Index{
+		{Name: "by_city", PartitionAttribute: "city", SortAttribute: "age"},
+		{Name: "by_email", PartitionAttribute: "email"},
+	}})
+	if err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	users := cl.Table("users")
+
+	for key, item := range map[string]base.Item{
+		"ann":  {"city": base.S("paris"), "age": base.N("31"), "email": base.S("ann@example.com")},
+		"bob":  {"city": base.S("paris"), "age": base.N("25"), "email": base.S("bob@example.com")},
+		"carl": {"city": base.S("paris"), "age": base.N("42")},
+		"dora": {"city": base.S("rome"), "age": base.N("25")},
+		"eve":  {"age": base.N("50")}, // not indexed
+	} {
+		if err := users.PutItem(ctx, base.Key{Partition: key}, item); err != nil {
+			t.Fatalf("PutItem %s failed: %v", key, err)
+		}
+	}
+
+	byCity := base.Query{Index: "by_city"}
+	awaitIndexQuery(t, users, "paris", byCity, []string{"bob", "ann", "carl"})
+	awaitIndexQuery(t, users, "rome", byCity, []string{"dora"})
+	awaitIndexQuery(t, users, "ann@example.com", base.Query{Index: "by_email"}, []string{"ann"})
+
+	page, err := users.Query(ctx, "paris", base.Query{Index: "by_city", Condition: &base.SortKeyCondition{Op: constants.QUERY_GE, Value: "31"}, Reverse: true})
+	if err != nil || len(page.Items) != 2 || page.Items[0].Key != "carl" || page.Items[0].Attrs["age"].String() != "42" {
+		t.Errorf("Query by age got: %+v, %v, expected carl then ann", page.Items, err)
+	}
+
+	// pages of an index resume after the entry of their last item
+	page, err = users.Query(ctx, "paris", base.Query{Index: "by_city", Limit: 2})
+	if err != nil || len(page.Items) != 2 || page.LastKey != base.IndexStartKey("31", base.Key{Partition: "ann"}) {
+		t.Fatalf("first page got: %+v, LastKey %q, %v", page.Items, page.LastKey, err)
+	}
+	awaitIndexQuery(t, users, "paris", base.Query{Index: "by_city", StartKey: page.LastKey}, []string{"carl"})
+
+	// entries move with their item, and are removed with it
+	if _, err := users.Update(ctx, base.Key{Partition: "bob"}, client.Set("city", base.S("rome"))); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := users.DeleteItem(ctx, base.Key{Partition: "ann"}); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+	err = cl.TransactWrite(ctx, []client.TransactItem{
+		{BatchKey: client.BatchKey{Table: "users", Key: base.Key{Partition: "eve"}}, Op: constants.CLIENT_REQ_UPDATE, Update: []base.UpdateAction{client.Set("city", base.S("rome"))}},
+	})
+	if err != nil {
+		t.Fatalf("TransactWrite failed: %v", err)
+	}
+	awaitIndexQuery(t, users, "paris", byCity, []string{"carl"})
+	awaitIndexQuery(t, users, "rome", byCity, []string{"bob", "dora", "eve"})
+	awaitIndexQuery(t, users, "ann@example.com", base.Query{Index: "by_email"}, nil)
+
+	if status, err := cl.DescribeIndex("users", "by_city"); err != nil || status.Pending != 0 {
+		t.Errorf("DescribeIndex got: %+v, %v, expected no pending update", status, err)
+	}
+	if _, err := cl.DescribeIndex("users", "missing"); !errors.Is(err, client.ErrInvalidRequest) {
+		t.Errorf("DescribeIndex of a missing index got: %v, expected ErrInvalidRequest", err)
+	}
+	if _, err := users.Query(ctx, "paris", base.Query{Index: "missing"}); !errors.Is(err, client.ErrInvalidRequest) {
+		t.Errorf("Query of a missing index got: %v, expected ErrInvalidRequest", err)
+	}
+
+	// entries are dropped with their table
+	if _, err := cl.DeleteTable("users"); err != nil {
+		t.Fatalf("DeleteTable failed: %v", err)
+	}
+	for _, node := range phy_nodes {
+		if len(node.GetAllData()) != 0 {
+			t.Errorf("node %d holds data of a deleted table: %v", node.GetID(), node.GetAllData())
+		}
+	}
+}
+
+// TEST N2
+
+// TestIndexLag ensures index updates that do not reach W owners of their entry are
+// reported by DescribeIndex, and are applied once the owners are back
+func TestIndexLag(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 1
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+	c.SET_DATA_TIMEOUT_MS = 50
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	if _, err := cl.CreateTable(base.Table{Name: "users", N: 2, R: 1, W: 2, Indexes: []base.Index{{Name: "by_city", PartitionAttribute: "city"}}}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	users := cl.Table("users")
+
+	// the first owner of the index partition misses the update, the write of the item is handed off
+	_, owner := base.FindNode(base.IndexRoutingKey("users", "by_city", "paris"), phy_nodes, &c)
+	owner.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := users.PutItem(ctx, base.Key{Partition: "ann"}, base.Item{"city": base.S("paris")}); err != nil {
+		t.Fatalf("PutItem failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	status, err := cl.DescribeIndex("users", "by_city")
+	if err != nil || status.Pending != 1 || status.Lag < 100*time.Millisecond {
+		t.Errorf("DescribeIndex with an owner down got: %+v, %v, expected a pending update", status, err)
+	}
+
+	owner.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_REVIVE, SrcID: -1}
+	deadline := time.Now().Add(time.Second)
+	for status.Pending > 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		status, _ = cl.DescribeIndex("users", "by_city")
+	}
+	if status.Pending != 0 || status.Lag != 0 {
+		t.Errorf("DescribeIndex after the revival got: %+v, expected no pending update", status)
+	}
+	awaitIndexQuery(t, users, "paris", base.Query{Index: "by_city"}, []string{"ann"})
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TEST T1
+
+// TestTypedItemRoundTrip ensures items of every attribute type are
+// returned intact by the client library and formatted for the CLI
+func TestTypedItemRoundTrip(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	item := base.Item{
+		"name":    base.S("bob"),
+		"empty":   base.S(""),
+		"age":     base.N("42"),
+		"avatar":  base.B([]byte("hi")),
+		"active":  base.Bool(false),
+		"manager": base.Null(),
+		"tags":    base.SS("a", "b"),
+		"scores":  base.NS("1", "2.5"),
+		"history": base.L(base.N("1"), base.S("x"), base.L()),
+		"address": base.M(map[string]base.AttributeValue{"city": base.S("Singapore"), "zip": base.N("123")}),
+	}
+	if err := cl.PutItem(ctx, base.Key{Partition: "user"}, item); err != nil {
+		t.Fatalf("PutItem failed: %v", err)
+	}
+
+	got, err := cl.GetItem(ctx, base.Key{Partition: "user"})
+	if err != nil {
+		t.Fatalf("GetItem failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, item) {
+		t.Errorf("got: %s, expected: %s", got, item)
+	}
+
+	expected := `{"active": false, "address": {"city": "Singapore", "zip": 123}, "age": 42, "avatar": b64:aGk=, "empty": "", ` +
+		`"history": [1, "x", []], "manager": null, "name": "bob", "scores": <<1, 2.5>>, "tags": <<"a", "b">>}`
+	if got.String() != expected {
+		t.Errorf("got: %s, expected: %s", got, expected)
+	}
+}
+
+// TEST T2
+
+// TestTypedItemValidation ensures malformed attribute values are
+// rejected by the coordinator with ErrInvalidRequest
+func TestTypedItemValidation(t *testing.T) {
+	two := "2"
+	var tests = []struct {
+		name string
+		item base.Item
+	}{
+		{"no_type", base.Item{"a": {}}},
+		{"two_types", base.Item{"a": {S: &two, N: &two}}},
+		{"invalid_number", base.Item{"a": base.N("12abc")}},
+		{"empty_string_set", base.Item{"a": base.SS()}},
+		{"duplicate_number_set", base.Item{"a": base.NS("1", "1")}},
+		{"invalid_number_set", base.Item{"a": base.NS("x")}},
+		{"invalid_list_element", base.Item{"a": base.L(base.N("x"))}},
+		{"invalid_map_value", base.Item{"a": base.M(map[string]base.AttributeValue{"b": {}})}},
+		{"empty_name", base.Item{"": base.S("x")}},
+	}
+
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := cl.PutItem(ctx, base.Key{Partition: tt.name}, tt.item); !errors.Is(err, client.ErrInvalidRequest) {
+				t.Errorf("PutItem got: %v, expected ErrInvalidRequest", err)
+			}
+			for name, value := range tt.item {
+				if _, err := cl.Update(ctx, base.Key{Partition: tt.name}, client.Set(name, value)); !errors.Is(err, client.ErrInvalidRequest) {
+					t.Errorf("Update got: %v, expected ErrInvalidRequest", err)
+				}
+			}
+			if _, err := cl.GetItem(ctx, base.Key{Partition: tt.name}); !errors.Is(err, client.ErrNotFound) {
+				t.Errorf("GetItem got: %v, expected ErrNotFound", err)
+			}
+		})
+	}
+}
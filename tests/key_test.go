@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TEST K1
+
+// TestCompositeKeys ensures items sharing a partition key are stored
+// together on the same replicas ordered by sort key, and are read and
+// deleted independently
+func TestCompositeKeys(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 3
+	c.W = 3
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	sortKeys := []string{"2023-03", "2023-01", "2023-02"}
+	for _, sortKey := range sortKeys {
+		key := base.Key{Partition: "user1", Sort: sortKey}
+		if err := cl.PutItem(ctx, key, base.Item{"month": base.S(sortKey)}); err != nil {
+			t.Fatalf("PutItem %s failed: %v", key, err)
+		}
+	}
+	if err := cl.Put(ctx, "user1", "plain"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	replicas := 0
+	for _, node := range phy_nodes {
+		objs := node.GetPartition("", "user1")
+		if len(objs) == 0 {
+			continue
+		}
+		replicas++
+		var got []string
+		for _, obj := range objs {
+			got = append(got, obj.GetSortKey())
+		}
+		if len(got) != 3 || got[0] != "2023-01" || got[1] != "2023-02" || got[2] != "2023-03" {
+			t.Errorf("node %d stores sort keys %v, expected [2023-01 2023-02 2023-03]", node.GetID(), got)
+		}
+	}
+	if replicas != c.N {
+		t.Errorf("partition stored on %d nodes, expected %d", replicas, c.N)
+	}
+
+	tests := []struct {
+		name    string
+		key     base.Key
+		deleted bool
+	}{
+		{"first", base.Key{Partition: "user1", Sort: "2023-01"}, false},
+		{"deleted", base.Key{Partition: "user1", Sort: "2023-02"}, true},
+		{"last", base.Key{Partition: "user1", Sort: "2023-03"}, false},
+	}
+
+	for _, tt := range tests {
+		if tt.deleted {
+			if err := cl.DeleteItem(ctx, tt.key); err != nil {
+				t.Fatalf("DeleteItem %s failed: %v", tt.key, err)
+			}
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := cl.GetItem(ctx, tt.key)
+			if tt.deleted {
+				if !errors.Is(err, client.ErrNotFound) {
+					t.Errorf("GetItem got: %v, expected ErrNotFound", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetItem failed: %v", err)
+			}
+			if expected := `{"month": "` + tt.key.Sort + `"}`; item.String() != expected {
+				t.Errorf("GetItem got: %s, expected %s", item, expected)
+			}
+		})
+	}
+
+	if value, err := cl.Get(ctx, "user1"); err != nil || value != "plain" {
+		t.Errorf("Get got: %q, %v, expected the value without sort key", value, err)
+	}
+}
+
+// TEST K2
+
+// TestQuery ensures a query returns the live items of a partition matching
+// its sort key condition, in order, a page at a time
+func TestQuery(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 3
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	for _, sortKey := range []string{"2023-03", "2023-01", "2023-02", "2024-01", "2024-02", "deleted"} {
+		key := base.Key{Partition: "orders", Sort: sortKey}
+		if err := cl.PutItem(ctx, key, base.Item{"month": base.S(sortKey)}); err != nil {
+			t.Fatalf("PutItem %s failed: %v", key, err)
+		}
+	}
+	if err := cl.PutItem(ctx, base.Key{Partition: "other", Sort: "2023-01"}, base.Item{}); err != nil {
+		t.Fatalf("PutItem failed: %v", err)
+	}
+	if err := cl.DeleteItem(ctx, base.Key{Partition: "orders", Sort: "deleted"}); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		query    base.Query
+		expected []string
+		lastKey  string
+	}{
+		{"all", base.Query{}, []string{"2023-01", "2023-02", "2023-03", "2024-01", "2024-02"}, ""},
+		{"eq", base.Query{Condition: &base.SortKeyCondition{Op: constants.QUERY_EQ, Value: "2023-02"}}, []string{"2023-02"}, ""},
+		{"lt", base.Query{Condition: &base.SortKeyCondition{Op: constants.QUERY_LT, Value: "2023-02"}}, []string{"2023-01"}, ""},
+		{"le", base.Query{Condition: &base.SortKeyCondition{Op: constants.QUERY_LE, Value: "2023-02"}}, []string{"2023-01", "2023-02"}, ""},
+		{"gt", base.Query{Condition: &base.SortKeyCondition{Op: constants.QUERY_GT, Value: "2024-01"}}, []string{"2024-02"}, ""},
+		{"ge", base.Query{Condition: &base.SortKeyCondition{Op: constants.QUERY_GE, Value: "2024-01"}}, []string{"2024-01", "2024-02"}, ""},
+		{"between", base.Query{Condition: &base.SortKeyCondition{Op: constants.QUERY_BETWEEN, Value: "2023-02", Upper: "2024-01"}}, []string{"2023-02", "2023-03", "2024-01"}, ""},
+		{"begins_with", base.Query{Condition: &base.SortKeyCondition{Op: constants.QUERY_BEGINS_WITH, Value: "2024"}}, []string{"2024-01", "2024-02"}, ""},
+		{"no_match", base.Query{Condition: &base.SortKeyCondition{Op: constants.QUERY_BEGINS_WITH, Value: "2025"}}, nil, ""},
+		{"reverse", base.Query{Reverse: true, Condition: &base.SortKeyCondition{Op: constants.QUERY_BEGINS_WITH, Value: "2023"}}, []string{"2023-03", "2023-02", "2023-01"}, ""},
+		{"limit", base.Query{Limit: 2}, []string{"2023-01", "2023-02"}, "2023-02"},
+		{"next_page", base.Query{Limit: 2, StartKey: "2023-02"}, []string{"2023-03", "2024-01"}, "2024-01"},
+		{"last_page", base.Query{Limit: 2, StartKey: "2024-01"}, []string{"2024-02"}, ""},
+		{"reverse_page", base.Query{Reverse: true, Limit: 2, StartKey: "2024-01"}, []string{"2023-03", "2023-02"}, "2023-02"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := cl.Query(ctx, "orders", tt.query)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			var got []string
+			for _, item := range page.Items {
+				got = append(got, item.SortKey)
+				if item.Attrs["month"].String() != `"`+item.SortKey+`"` {
+					t.Errorf("item %s has attributes %s", item.SortKey, item.Attrs)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got: %v, expected: %v", got, tt.expected)
+			}
+			if page.LastKey != tt.lastKey {
+				t.Errorf("got LastKey %q, expected %q", page.LastKey, tt.lastKey)
+			}
+		})
+	}
+
+	invalid := []base.Query{
+		{Condition: &base.SortKeyCondition{Op: "contains", Value: "2023"}},
+		{Condition: &base.SortKeyCondition{Op: constants.QUERY_BETWEEN, Value: "2024", Upper: "2023"}},
+		{Limit: -1},
+	}
+	for _, q := range invalid {
+		if _, err := cl.Query(ctx, "orders", q); !errors.Is(err, client.ErrInvalidRequest) {
+			t.Errorf("Query %+v got: %v, expected ErrInvalidRequest", q, err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TEST L1
+
+// TestLibraryPutGet ensures values written with the client library are read back
+func TestLibraryPutGet(t *testing.T) {
+	var tests = []struct {
+		numNodes, numTokens, nValue, rAndWValue, numKeys int
+	}{
+		{1, 1, 1, 1, 5},
+		{5, 5, 3, 2, 20},
+		{10, 20, 5, 3, 20},
+	}
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%d_nodes_%d_tokens_%d_n_%d_rAndW_%d_keys", tt.numNodes, tt.numTokens, tt.nValue, tt.rAndWValue, tt.numKeys)
+		t.Run(testname, func(t *testing.T) {
+			c := config.InstantiateConfig()
+			c.NUM_NODES = tt.numNodes
+			c.NUM_TOKENS = tt.numTokens
+			c.N = tt.nValue
+			c.R = tt.rAndWValue
+			c.W = tt.rAndWValue
+			c.DEBUG_LEVEL = 1
+
+			phy_nodes, close_ch, _ := setUpNodes(&c)
+			defer close(close_ch)
+			cl := client.New(phy_nodes, &c)
+			ctx := context.Background()
+
+			keyValuePairs := generateRandomKeyValuePairs(20, 100, tt.numKeys)
+			for key, value := range keyValuePairs {
+				if err := cl.Put(ctx, key, value); err != nil {
+					t.Fatalf("put(%s) failed: %s", key, err)
+				}
+			}
+			for key, value := range keyValuePairs {
+				got, err := cl.Get(ctx, key)
+				if err != nil || got != value {
+					t.Errorf("got: %s (%v), expected: %s", got, err, value)
+				}
+			}
+		})
+	}
+}
+
+// TEST L2
+
+// TestLibraryCoordinatorDown ensures requests fall back along the
+// preference list when the coordinator of a key is down
+func TestLibraryCoordinatorDown(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 1
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_GET_TIMEOUT_MS = 500
+	c.SET_DATA_TIMEOUT_MS = 200
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	key := "hello"
+	_, coordinator := base.FindNode(key, phy_nodes, &c)
+	coordinator.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := cl.Put(ctx, key, "world"); err != nil {
+		t.Fatalf("put failed with coordinator %d down: %s", coordinator.GetID(), err)
+	}
+	got, err := cl.Get(ctx, key)
+	if err != nil || got != "world" {
+		t.Errorf("got: %s (%v), expected: world", got, err)
+	}
+}
+
+// TEST L3
+
+// TestLibraryErrors ensures failed requests return typed errors
+func TestLibraryErrors(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.N = 3
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_GET_TIMEOUT_MS = 200
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+
+	for _, node := range phy_nodes {
+		node.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("no_coordinator", func(t *testing.T) {
+		err := cl.Put(context.Background(), "hello", "world")
+		var reqErr *client.RequestError
+		if !errors.Is(err, client.ErrNoCoordinator) || !errors.As(err, &reqErr) || reqErr.Key != "hello" {
+			t.Errorf("got: %v, expected ErrNoCoordinator for key hello", err)
+		}
+	})
+
+	t.Run("context_deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := cl.Get(ctx, "hello")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got: %v, expected context.DeadlineExceeded", err)
+		}
+		if time.Since(start) > time.Duration(c.CLIENT_GET_TIMEOUT_MS)*time.Millisecond {
+			t.Errorf("get returned after %v, expected it to stop at the context deadline", time.Since(start))
+		}
+	})
+
+	t.Run("stalled_node", func(t *testing.T) {
+		// a node that is not started never drains its full channel
+		sc := c
+		sc.NUM_NODES, sc.NUM_TOKENS, sc.N = 1, 1, 1
+		stall_ch := make(chan struct{})
+		defer close(stall_ch)
+		stalled := base.CreateNodes(stall_ch, &sc)
+		base.InitializeTokens(stalled, &sc)
+		node_ch := stalled[0].GetChannel()
+		for len(node_ch) < cap(node_ch) {
+			node_ch <- base.Message{}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := client.New(stalled, &sc).Put(ctx, "hello", "world")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got: %v, expected context.DeadlineExceeded", err)
+		}
+		if time.Since(start) > time.Duration(c.CLIENT_GET_TIMEOUT_MS)*time.Millisecond {
+			t.Errorf("put returned after %v, expected it to stop at the context deadline", time.Since(start))
+		}
+	})
+}
+
+// TEST L4
+
+// TestLibraryFailureResponses ensures NACKs and missing keys are
+// returned as errors instead of timeouts
+func TestLibraryFailureResponses(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 1
+	c.W = 3
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_GET_TIMEOUT_MS = 300
+	c.SET_DATA_TIMEOUT_MS = 200
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+
+	t.Run("key_not_found", func(t *testing.T) {
+		_, err := cl.Get(context.Background(), "missing")
+		var reqErr *client.RequestError
+		if !errors.Is(err, client.ErrNotFound) || !errors.As(err, &reqErr) || reqErr.Attempts != 1 {
+			t.Errorf("got: %v, expected ErrNotFound after a single attempt", err)
+		}
+	})
+
+	t.Run("write_nack", func(t *testing.T) {
+		key := "hello"
+		_, coordinator := base.FindNode(key, phy_nodes, &c)
+		for _, node := range phy_nodes {
+			if node != coordinator {
+				node.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		err := cl.Put(context.Background(), key, "world")
+		var nack *base.NackError
+		if !errors.As(err, &nack) || nack.Command != constants.CLIENT_NACK_WRITE || nack.Replicas != 1 || nack.Quorum != 3 {
+			t.Errorf("got: %v, expected CLIENT_NACK_WRITE with 1/3 replicas", err)
+		}
+	})
+}
+
+// TEST L5
+
+// TestLibraryConditionalPut ensures only one of several concurrent
+// PutIfAbsent calls succeeds and PutIfVersion detects lost updates
+func TestLibraryConditionalPut(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	const writers = 5
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		go func(i int) {
+			_, err := cl.PutIfAbsent(ctx, "lock", fmt.Sprintf("owner%d", i))
+			errs <- err
+		}(i)
+	}
+	succeeded := 0
+	for i := 0; i < writers; i++ {
+		err := <-errs
+		if err == nil {
+			succeeded++
+		} else if !errors.Is(err, client.ErrConditionFailed) {
+			t.Errorf("got: %v, expected ErrConditionFailed", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d PutIfAbsent calls succeeded, expected 1", succeeded)
+	}
+
+	value, version, err := cl.GetVersion(ctx, "lock")
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	newVersion, err := cl.PutIfVersion(ctx, "lock", value+"-renewed", version)
+	if err != nil {
+		t.Fatalf("PutIfVersion with the current version failed: %v", err)
+	}
+	if _, err := cl.PutIfVersion(ctx, "lock", "stale", version); !errors.Is(err, client.ErrConditionFailed) {
+		t.Errorf("PutIfVersion with a stale version got: %v, expected ErrConditionFailed", err)
+	}
+	if _, err := cl.PutIfVersion(ctx, "lock", value+"-renewed-again", newVersion); err != nil {
+		t.Errorf("PutIfVersion with the version returned by the last write failed: %v", err)
+	}
+}
+
+// TEST L6
+
+// TestLibraryUpdate ensures concurrent Add calls are not lost and
+// invalid updates return ErrInvalidRequest
+func TestLibraryUpdate(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+	page := base.Key{Partition: "page"}
+
+	if err := cl.PutItem(ctx, page, base.Item{"title": base.S("home")}); err != nil {
+		t.Fatalf("PutItem failed: %v", err)
+	}
+
+	const writers = 10
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		go func() {
+			_, err := cl.Update(ctx, page, client.Add("views", 1))
+			errs <- err
+		}()
+	}
+	for i := 0; i < writers; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Update failed: %v", err)
+		}
+	}
+
+	expected := fmt.Sprintf(`{"owner": "bob", "views": %d}`, writers)
+	item, err := cl.Update(ctx, page, client.Set("owner", base.S("bob")), client.Remove("title"))
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if item.String() != expected {
+		t.Errorf("got: %s, expected %s", item, expected)
+	}
+	if item, _ := cl.GetItem(ctx, page); item.String() != expected {
+		t.Errorf("GetItem got: %s, expected %s", item, expected)
+	}
+
+	if _, err := cl.Update(ctx, page, client.Add("owner", 1)); !errors.Is(err, client.ErrInvalidRequest) {
+		t.Errorf("got: %v, expected ErrInvalidRequest", err)
+	}
+}
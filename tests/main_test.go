@@ -0,0 +1,17 @@
+package tests
+
+import (
+	"os"
+	"runtime/debug"
+	"testing"
+)
+
+/*
+TestMain caps the heap the tests keep mapped. The clusters of 1000 nodes of TestNodeCreation and TestTokenCreation
+allocate ~35GB of receive channel buffers, mostly never written. Without a limit, the runtime reuses their pages once
+they are garbage, paging them in, which stalls the timing sensitive tests after them or gets the run killed.
+*/
+func TestMain(m *testing.M) {
+	debug.SetMemoryLimit(1 << 30)
+	os.Exit(m.Run())
+}
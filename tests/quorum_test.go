@@ -245,12 +245,17 @@ func TestQuorumWriteWithHighFailures(t *testing.T) {
 					panic(fmt.Sprintf("wrong key! expected: %s got :%s", key, ack.Key))
 				}
 
-				fmt.Println("Value stored: ", value, " with key: ", key)
-				t.Error("Test failed. Value stored when not supposed to.")
-				return
-			case <-time.After(time.Duration(c.CLIENT_PUT_TIMEOUT_MS) * time.Millisecond): // timeout reached
+				if ack.Command != constants.CLIENT_NACK_WRITE || ack.Replicas >= ack.Quorum {
+					fmt.Println("Value stored: ", value, " with key: ", key)
+					t.Errorf("Test failed. Value stored when not supposed to, got %s with %d/%d replicas.", constants.GetConstantString(ack.Command), ack.Replicas, ack.Quorum)
+					return
+				}
+				fmt.Println("Expected result: Put NACKed:", ack.Reason)
+			// the NACK comes once the handoff rounds, one SET_DATA_TIMEOUT_MS each, cannot reach W any more
+			case <-time.After(time.Duration(c.CLIENT_PUT_TIMEOUT_MS+c.NUM_NODES*c.SET_DATA_TIMEOUT_MS) * time.Millisecond):
 				fmt.Println("Put Timeout reached")
-				fmt.Println("Expected result: Put timeout reached.")
+				t.Error("Put timeout reached, expected CLIENT_NACK_WRITE")
+				return
 			}
 
 			close(close_ch)
@@ -399,3 +404,186 @@ func TestHighQuorumRead(t *testing.T) {
 		})
 	}
 }
+
+// Test Q7
+
+// TestQuorumReadFailure ensures a read that cannot reach R replicas
+// is NACKed with the number of replicas that answered
+func TestQuorumReadFailure(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.W = 3
+	c.R = 3
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_PUT_TIMEOUT_MS = 5_000
+	c.CLIENT_GET_TIMEOUT_MS = 500
+
+	phy_nodes, close_ch, client_ch := setUpNodes(&c)
+	defer close(close_ch)
+
+	key := generateRandomString(10)
+	_, node := base.FindNode(key, phy_nodes, &c)
+	channel := node.GetChannel()
+
+	channel <- base.Message{Key: key, Command: constants.CLIENT_REQ_WRITE, Data: "value", Client_Ch: client_ch}
+	select {
+	case <-client_ch:
+	case <-time.After(time.Duration(c.CLIENT_PUT_TIMEOUT_MS) * time.Millisecond):
+		t.Fatal("Put timeout reached. Test failed prematurely.")
+	}
+
+	// only the coordinator is left to answer
+	for _, n := range phy_nodes {
+		if n != node {
+			n.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	channel <- base.Message{JobId: 1, Key: key, Command: constants.CLIENT_REQ_READ, SrcID: -1, Client_Ch: client_ch}
+	select {
+	case msg := <-client_ch:
+		if msg.Command != constants.CLIENT_NACK_READ || msg.Replicas != 1 || msg.Quorum != 3 {
+			t.Errorf("got %s with %d/%d replicas, expected CLIENT_NACK_READ with 1/3 replicas", constants.GetConstantString(msg.Command), msg.Replicas, msg.Quorum)
+		}
+	case <-time.After(time.Duration(2*c.CLIENT_GET_TIMEOUT_MS) * time.Millisecond):
+		t.Error("Get timeout reached, expected CLIENT_NACK_READ")
+	}
+}
+
+// Test Q8
+
+// TestReadMissingKey ensures reads of keys that were never written
+// or were deleted are answered with KEY_NOT_FOUND
+func TestReadMissingKey(t *testing.T) {
+	var tests = []struct {
+		numNodes, numTokens, nValue, rAndWValue int
+	}{
+		{1, 1, 1, 1},
+		{5, 5, 3, 1},
+		{5, 10, 3, 2},
+	}
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%d_nodes_%d_tokens_%d_n_%d_rAndW", tt.numNodes, tt.numTokens, tt.nValue, tt.rAndWValue)
+		t.Run(testname, func(t *testing.T) {
+			c := config.InstantiateConfig()
+			c.NUM_NODES = tt.numNodes
+			c.NUM_TOKENS = tt.numTokens
+			c.N = tt.nValue
+			c.R = tt.rAndWValue
+			c.W = tt.rAndWValue
+			c.DEBUG_LEVEL = 1
+
+			phy_nodes, close_ch, client_ch := setUpNodes(&c)
+			defer close(close_ch)
+
+			request := func(command int, key string) base.Message {
+				_, node := base.FindNode(key, phy_nodes, &c)
+				node.GetChannel() <- base.Message{Key: key, Command: command, Data: "value", SrcID: -1, Client_Ch: client_ch}
+				select {
+				case msg := <-client_ch:
+					return msg
+				case <-time.After(time.Duration(c.CLIENT_GET_TIMEOUT_MS) * time.Millisecond):
+					t.Fatalf("%s(%s) timeout reached", constants.GetConstantString(command), key)
+					return base.Message{}
+				}
+			}
+
+			if msg := request(constants.CLIENT_REQ_READ, "missing"); msg.Command != constants.KEY_NOT_FOUND {
+				t.Errorf("get(missing) got %s, expected KEY_NOT_FOUND", constants.GetConstantString(msg.Command))
+			}
+
+			request(constants.CLIENT_REQ_WRITE, "deleted")
+			request(constants.CLIENT_REQ_DELETE, "deleted")
+			if msg := request(constants.CLIENT_REQ_READ, "deleted"); msg.Command != constants.KEY_NOT_FOUND {
+				t.Errorf("get(deleted) got %s, expected KEY_NOT_FOUND", constants.GetConstantString(msg.Command))
+			}
+		})
+	}
+}
+
+// Test Q9
+
+// TestConditionalWrite ensures conditional writes are checked against the
+// value reconciled from R replicas and fail with CONDITION_FAILED
+func TestConditionalWrite(t *testing.T) {
+	var tests = []struct {
+		numNodes, numTokens, nValue, rAndWValue int
+	}{
+		{1, 1, 1, 1},
+		{5, 5, 3, 2},
+		{5, 10, 3, 3},
+	}
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%d_nodes_%d_tokens_%d_n_%d_rAndW", tt.numNodes, tt.numTokens, tt.nValue, tt.rAndWValue)
+		t.Run(testname, func(t *testing.T) {
+			c := config.InstantiateConfig()
+			c.NUM_NODES = tt.numNodes
+			c.NUM_TOKENS = tt.numTokens
+			c.N = tt.nValue
+			c.R = tt.rAndWValue
+			c.W = tt.rAndWValue
+			c.DEBUG_LEVEL = 1
+
+			phy_nodes, close_ch, client_ch := setUpNodes(&c)
+			defer close(close_ch)
+
+			key := generateRandomString(10)
+			_, node := base.FindNode(key, phy_nodes, &c)
+			request := func(msg base.Message) base.Message {
+				msg.Key, msg.SrcID, msg.Client_Ch = key, -1, client_ch
+				node.GetChannel() <- msg
+				select {
+				case reply := <-client_ch:
+					return reply
+				case <-time.After(time.Duration(c.CLIENT_PUT_TIMEOUT_MS) * time.Millisecond):
+					t.Fatalf("%s timeout reached", constants.GetConstantString(msg.Command))
+					return base.Message{}
+				}
+			}
+
+			// absent key
+			if reply := request(base.Message{Command: constants.CLIENT_REQ_WRITE, Data: "v1", Condition: constants.COND_VERSION_EQUALS, Version: make([]int, c.NUM_NODES)}); reply.Command != constants.CONDITION_FAILED {
+				t.Errorf("version equals on absent key got %s, expected CONDITION_FAILED", constants.GetConstantString(reply.Command))
+			}
+			first := request(base.Message{Command: constants.CLIENT_REQ_WRITE, Data: "v1", Condition: constants.COND_NOT_EXISTS})
+			if first.Command != constants.CLIENT_ACK_WRITE {
+				t.Fatalf("not exists on absent key got %s, expected CLIENT_ACK_WRITE", constants.GetConstantString(first.Command))
+			}
+
+			// existing key
+			if reply := request(base.Message{Command: constants.CLIENT_REQ_WRITE, Data: "v2", Condition: constants.COND_NOT_EXISTS}); reply.Command != constants.CONDITION_FAILED || fmt.Sprint(reply.Version) != fmt.Sprint(first.Version) {
+				t.Errorf("not exists on existing key got %s with version %v, expected CONDITION_FAILED with version %v", constants.GetConstantString(reply.Command), reply.Version, first.Version)
+			}
+			read := request(base.Message{Command: constants.CLIENT_REQ_READ})
+			if read.Data != "v1" || fmt.Sprint(read.Version) != fmt.Sprint(first.Version) {
+				t.Errorf("read got %s with version %v, expected v1 with version %v", read.Data, read.Version, first.Version)
+			}
+			second := request(base.Message{Command: constants.CLIENT_REQ_WRITE, Data: "v2", Condition: constants.COND_VERSION_EQUALS, Version: read.Version})
+			if second.Command != constants.CLIENT_ACK_WRITE {
+				t.Errorf("version equals on current version got %s, expected CLIENT_ACK_WRITE", constants.GetConstantString(second.Command))
+			}
+			if reply := request(base.Message{Command: constants.CLIENT_REQ_WRITE, Data: "v3", Condition: constants.COND_VERSION_EQUALS, Version: read.Version}); reply.Command != constants.CONDITION_FAILED {
+				t.Errorf("version equals on stale version got %s, expected CONDITION_FAILED", constants.GetConstantString(reply.Command))
+			}
+
+			// deleted key
+			request(base.Message{Command: constants.CLIENT_REQ_DELETE})
+			if reply := request(base.Message{Command: constants.CLIENT_REQ_WRITE, Data: "v4", Condition: constants.COND_NOT_EXISTS}); reply.Command != constants.CLIENT_ACK_WRITE {
+				t.Errorf("not exists on deleted key got %s, expected CLIENT_ACK_WRITE", constants.GetConstantString(reply.Command))
+			}
+			if read := request(base.Message{Command: constants.CLIENT_REQ_READ}); read.Data != "v4" {
+				t.Errorf("read got %s, expected v4", read.Data)
+			}
+
+			// a client that never takes its reply does not keep later writes to the key waiting
+			node.GetChannel() <- base.Message{Key: key, Command: constants.CLIENT_REQ_WRITE, Data: "v5", SrcID: -1, Client_Ch: make(chan base.Message)}
+			time.Sleep(50 * time.Millisecond)
+			if reply := request(base.Message{Command: constants.CLIENT_REQ_WRITE, Data: "v6", Condition: constants.COND_VERSION_EQUALS, Version: make([]int, c.NUM_NODES)}); reply.Command != constants.CONDITION_FAILED {
+				t.Errorf("write after an unclaimed write got %s, expected CONDITION_FAILED", constants.GetConstantString(reply.Command))
+			}
+		})
+	}
+}
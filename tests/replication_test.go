@@ -137,11 +137,14 @@ func TestSinglePutReplicationZeroNegative(t *testing.T) {
 				if ack.Key != key {
 					panic(fmt.Sprintf("wrong key! expected: %s got :%s", key, ack.Key))
 				}
-
-				t.Error("Unexpected behaviour! Value stored when not supposed to")
-				return
+				if ack.Command != constants.CLIENT_NACK_WRITE || ack.Replicas != 0 {
+					t.Errorf("Unexpected behaviour! Got %s with %d replicas, expected CLIENT_NACK_WRITE with 0 replicas", constants.GetConstantString(ack.Command), ack.Replicas)
+					return
+				}
+				fmt.Println("Expected behaviour: Put NACKed:", ack.Reason)
 			case <-time.After(time.Duration(c.CLIENT_PUT_TIMEOUT_MS) * time.Millisecond): // timeout reached
-				fmt.Println("Expected behaviour: Put Timeout reached")
+				t.Error("Put timeout reached, expected CLIENT_NACK_WRITE")
+				return
 			}
 
 			close(close_ch)
@@ -278,7 +281,7 @@ func TestMultipleOverwritePutReplication(t *testing.T) {
 			var wg sync.WaitGroup
 			for i := range phy_nodes {
 				wg.Add(1)
-				go phy_nodes[i].Start(&wg, &c)
+				go phy_nodes[i].Start(&wg)
 			}
 
 			for updateCnt := 0; updateCnt < tt.updates; updateCnt++ {
@@ -329,3 +332,123 @@ func TestMultipleOverwritePutReplication(t *testing.T) {
 		})
 	}
 }
+
+// Test R5
+
+// TestConcurrentPutReplication checks that many concurrent puts replicating
+// to overlapping preference lists are all acknowledged and replicated
+// exactly once to every node in their preference list
+func TestConcurrentPutReplication(t *testing.T) {
+	var tests = []struct {
+		numNodes, numTokens, nValue, numKeyValuePairs int
+	}{
+		{3, 3, 3, 50},
+		{5, 5, 3, 100},
+		{5, 10, 5, 100},
+	}
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%d_nodes_%d_tokens_%d_n_%d_keyValuePairs", tt.numNodes, tt.numTokens, tt.nValue, tt.numKeyValuePairs)
+		t.Run(testname, func(t *testing.T) {
+			expectedReplicas := calculateExpectedTotalReplications(tt.numNodes, tt.numTokens, tt.nValue) - 1
+
+			c := config.InstantiateConfig()
+			c.NUM_NODES = tt.numNodes
+			c.NUM_TOKENS = tt.numTokens
+			c.N = tt.nValue
+			c.W = tt.nValue
+			c.DEBUG_LEVEL = 1
+			c.CLIENT_PUT_TIMEOUT_MS = 5_000
+
+			phy_nodes, close_ch, _ := setUpNodes(&c)
+			defer close(close_ch)
+			keyValuePairs := generateRandomKeyValuePairs(20, 100, tt.numKeyValuePairs)
+
+			// every put uses JobId 0, nodes must not rely on client job ids to track ACKs
+			client_ch := make(chan base.Message, tt.numKeyValuePairs)
+			for key, value := range keyValuePairs {
+				_, node := base.FindNode(key, phy_nodes, &c)
+				node.GetChannel() <- base.Message{Key: key, Command: constants.CLIENT_REQ_WRITE, Data: value, Client_Ch: client_ch}
+			}
+
+			for i := 0; i < tt.numKeyValuePairs; i++ {
+				select {
+				case <-client_ch:
+				case <-time.After(time.Duration(c.CLIENT_PUT_TIMEOUT_MS) * time.Millisecond):
+					t.Fatalf("Put timeout reached after %d/%d puts. Test failed.", i, tt.numKeyValuePairs)
+				}
+			}
+
+			for key, value := range keyValuePairs {
+				ori := 0
+				repCnt := 0
+				hashedKey := base.ComputeMD5(key)
+				for _, n := range phy_nodes {
+					if val, ok := n.GetAllData()[hashedKey]; ok && val.GetData() == value {
+						if val.IsReplica() {
+							repCnt++
+						} else {
+							ori++
+						}
+					}
+					if len(n.GetAllBackup()) != 0 {
+						t.Errorf("Node %d holds hinted handoff backups with no node down", n.GetID())
+					}
+				}
+				if repCnt != expectedReplicas || ori != 1 {
+					t.Errorf("Key '%s' has %d originals and %d replicas; expected 1 and %d", key, ori, repCnt, expectedReplicas)
+				}
+			}
+		})
+	}
+}
+
+// Test R6
+
+// TestReplicaAckNotSharedAcrossPuts checks that an ACK for one put does not
+// complete another put's pending replication to the same node. A put whose
+// replica was dropped by a killed node must still be handed off and restored.
+func TestReplicaAckNotSharedAcrossPuts(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+	c.SET_DATA_TIMEOUT_MS = 1000
+	c.CLIENT_PUT_TIMEOUT_MS = 5_000
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+
+	// two keys of the same token, so they share coordinator and preference list
+	key1 := generateRandomString(20)
+	token, coordinator := base.FindNode(key1, phy_nodes, &c)
+	key2 := generateRandomString(20)
+	for tok, _ := base.FindNode(key2, phy_nodes, &c); tok != token || key2 == key1; tok, _ = base.FindNode(key2, phy_nodes, &c) {
+		key2 = generateRandomString(20)
+	}
+	replica := phy_nodes[coordinator.GetPrefList()[token][1].Token.GetPID()]
+
+	// replica drops the first put's SET_DATA, then revives in time to ACK the second put
+	replica.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "300", SrcID: -1}
+	time.Sleep(50 * time.Millisecond)
+
+	client_ch := make(chan base.Message, 2)
+	for i, key := range []string{key1, key2} {
+		coordinator.GetChannel() <- base.Message{Key: key, Command: constants.CLIENT_REQ_WRITE, Data: key, Client_Ch: client_ch}
+		select {
+		case <-client_ch:
+		case <-time.After(time.Duration(c.CLIENT_PUT_TIMEOUT_MS) * time.Millisecond):
+			t.Fatalf("Put timeout reached for key %d. Test failed.", i+1)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	// the first put's replication to the revived node times out, is handed off and restored
+	time.Sleep(time.Duration(2*c.SET_DATA_TIMEOUT_MS) * time.Millisecond)
+	for _, key := range []string{key1, key2} {
+		if obj, ok := replica.GetAllData()[base.ComputeMD5(key)]; !ok || obj.GetData() != key {
+			t.Errorf("Node %d is missing its replica of key '%s'", replica.GetID(), key)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"config"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"rpc"
+	"rpc/pb"
+	"sort"
+	"testing"
+	"time"
+)
+
+func setUpRpc(t *testing.T, c *config.Config) (pb.DynamoClient, func()) {
+	phy_nodes, close_ch, _ := setUpNodes(c)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer, _ := rpc.Serve(lis, rpc.NewServer(phy_nodes, c))
+
+	client, conn, err := rpc.Dial(lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, func() {
+		conn.Close()
+		grpcServer.Stop()
+		close(close_ch)
+	}
+}
+
+// TEST G1
+
+// TestRpcPutGetDelete ensures a single key round trips through the gRPC API
+func TestRpcPutGetDelete(t *testing.T) {
+	var tests = []struct {
+		numNodes, numTokens, nValue, rValue, wValue int
+	}{
+		{1, 1, 1, 1, 1},
+		{5, 5, 3, 1, 1},
+		{5, 10, 3, 2, 2},
+	}
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%d_nodes_%d_tokens_%d_n_%d_r_%d_w", tt.numNodes, tt.numTokens, tt.nValue, tt.rValue, tt.wValue)
+		t.Run(testname, func(t *testing.T) {
+			c := config.InstantiateConfig()
+			c.NUM_NODES = tt.numNodes
+			c.NUM_TOKENS = tt.numTokens
+			c.N = tt.nValue
+			c.R = tt.rValue
+			c.W = tt.wValue
+			c.DEBUG_LEVEL = 1
+			c.CLIENT_GET_TIMEOUT_MS = 500
+
+			client, teardown := setUpRpc(t, &c)
+			defer teardown()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			if _, err := client.Put(ctx, &pb.PutRequest{Key: "hello", Value: "world"}); err != nil {
+				t.Fatalf("Put failed: %s", err)
+			}
+
+			resp, err := client.Get(ctx, &pb.GetRequest{Key: "hello"})
+			if err != nil || !resp.Found || resp.Value != "world" {
+				t.Fatalf("got: %v %v, expected: world", resp, err)
+			}
+
+			if _, err := client.Delete(ctx, &pb.DeleteRequest{Key: "hello"}); err != nil {
+				t.Fatalf("Delete failed: %s", err)
+			}
+
+			resp, err = client.Get(ctx, &pb.GetRequest{Key: "hello"})
+			if err != nil || resp.Found {
+				t.Errorf("expected key to be deleted, got: %v %v", resp, err)
+			}
+		})
+	}
+}
+
+// TEST G2
+
+// TestRpcBatchAndScan ensures batch writes are readable with a batch
+// read and that scan streams every written item exactly once
+func TestRpcBatchAndScan(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	client, teardown := setUpRpc(t, &c)
+	defer teardown()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	keyValuePairs := generateRandomKeyValuePairs(20, 100, 20)
+	batch := &pb.BatchPutRequest{}
+	keys := []string{}
+	for key, value := range keyValuePairs {
+		batch.Items = append(batch.Items, &pb.Item{Key: key, Value: value})
+		keys = append(keys, key)
+	}
+
+	putResp, err := client.BatchPut(ctx, batch)
+	if err != nil || len(putResp.UnprocessedKeys) != 0 {
+		t.Fatalf("BatchPut failed: %v, unprocessed keys: %v", err, putResp.GetUnprocessedKeys())
+	}
+
+	getResp, err := client.BatchGet(ctx, &pb.BatchGetRequest{Keys: keys})
+	if err != nil || len(getResp.UnprocessedKeys) != 0 {
+		t.Fatalf("BatchGet failed: %v, unprocessed keys: %v", err, getResp.GetUnprocessedKeys())
+	}
+	for _, item := range getResp.Items {
+		if keyValuePairs[item.Key] != item.Value {
+			t.Errorf("got from key %s: %s, expected: %s", item.Key, item.Value, keyValuePairs[item.Key])
+		}
+	}
+
+	stream, err := client.Scan(ctx, &pb.ScanRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanned := []string{}
+	for {
+		item, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanned = append(scanned, item.Key)
+	}
+
+	sort.Strings(keys)
+	sort.Strings(scanned)
+	if fmt.Sprint(keys) != fmt.Sprint(scanned) {
+		t.Errorf("scanned keys: %v, expected: %v", scanned, keys)
+	}
+}
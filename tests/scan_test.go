@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writes plain values and items with sort keys, deletes one of each, returns the keys left
+func fillTable(t *testing.T, cl *client.Client) []string {
+	ctx := context.Background()
+	var keys []string
+	for key, value := range generateRandomKeyValuePairs(20, 20, 30) {
+		if err := cl.Put(ctx, key, value); err != nil {
+			t.Fatalf("Put %s failed: %v", key, err)
+		}
+		keys = append(keys, key)
+	}
+	for _, sortKey := range []string{"a", "b", "c"} {
+		key := base.Key{Partition: "orders", Sort: sortKey}
+		if err := cl.PutItem(ctx, key, base.Item{"sort": base.S(sortKey)}); err != nil {
+			t.Fatalf("PutItem %s failed: %v", key, err)
+		}
+		keys = append(keys, key.String())
+	}
+
+	if err := cl.Delete(ctx, keys[0]); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := cl.DeleteItem(ctx, base.Key{Partition: "orders", Sort: "b"}); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+	keys = keys[1:]
+	for i, key := range keys {
+		if key == "orders/b" {
+			keys = append(keys[:i], keys[i+1:]...)
+			break
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scans a segment page by page, returns the keys read
+func scanSegment(ctx context.Context, table *client.Table, scan base.Scan) ([]string, int, error) {
+	var keys []string
+	pages := 0
+	for {
+		page, err := table.Scan(ctx, scan)
+		if err != nil {
+			return nil, pages, err
+		}
+		pages++
+		for _, item := range page.Items {
+			keys = append(keys, base.Key{Partition: item.Key, Sort: item.SortKey}.String())
+		}
+		if page.LastKey == nil {
+			return keys, pages, nil
+		}
+		scan.StartKey = page.LastKey
+	}
+}
+
+// TEST S1
+
+// TestScan ensures a paginated scan returns every live value and item exactly once
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		pages int
+	}{
+		{"single_page", 0, 1},
+		{"pages", 7, 5},
+		{"exact_page", 31, 1},
+	}
+
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 3
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	expected := fillTable(t, cl)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, pages, err := scanSegment(context.Background(), cl.Table(""), base.Scan{Limit: tt.limit})
+			if err != nil {
+				t.Fatalf("Scan failed: %v", err)
+			}
+			sort.Strings(keys)
+			if fmt.Sprint(keys) != fmt.Sprint(expected) {
+				t.Errorf("scanned keys: %v, expected: %v", keys, expected)
+			}
+			if pages != tt.pages {
+				t.Errorf("scanned %d pages, expected %d", pages, tt.pages)
+			}
+		})
+	}
+}
+
+// TEST S2
+
+// TestScanSegments ensures segments scanned in parallel are disjoint and cover the table,
+// also with a node down
+func TestScanSegments(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 3
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_GET_TIMEOUT_MS = 200
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	expected := fillTable(t, cl)
+
+	tests := []struct {
+		name          string
+		totalSegments int
+		killed        bool
+	}{
+		{"one", 1, false},
+		{"four", 4, false},
+		{"more_than_tokens", 16, false},
+		{"node_down", 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.killed {
+				phy_nodes[0].GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "1000000000", SrcID: -1}
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			var lock sync.Mutex
+			var wg sync.WaitGroup
+			var keys []string
+			for segment := 0; segment < tt.totalSegments; segment++ {
+				wg.Add(1)
+				go func(segment int) {
+					defer wg.Done()
+					segmentKeys, _, err := scanSegment(context.Background(), cl.Table(""), base.Scan{Segment: segment, TotalSegments: tt.totalSegments, Limit: 5})
+					if err != nil {
+						t.Errorf("Scan of segment %d failed: %v", segment, err)
+					}
+					lock.Lock()
+					keys = append(keys, segmentKeys...)
+					lock.Unlock()
+				}(segment)
+			}
+			wg.Wait()
+
+			sort.Strings(keys)
+			if fmt.Sprint(keys) != fmt.Sprint(expected) {
+				t.Errorf("scanned keys: %v, expected: %v", keys, expected)
+			}
+		})
+	}
+
+	invalid := []base.Scan{{Segment: 4, TotalSegments: 4}, {Segment: -1, TotalSegments: 4}, {Limit: -1}}
+	for _, scan := range invalid {
+		if _, err := cl.Scan(context.Background(), scan); !errors.Is(err, client.ErrInvalidRequest) {
+			t.Errorf("Scan %+v got: %v, expected ErrInvalidRequest", scan, err)
+		}
+	}
+}
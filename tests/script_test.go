@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TEST V1
+
+// TestScript ensures scripts run by main.go -script exit with status 0 once every command succeeded and every
+// expectation held, and with status 1 at the first command that fails or expectation that does not hold
+func TestScript(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed:", err)
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "dynamo")
+	if out, err := exec.Command("go", "build", "-o", bin, "../main.go").CombinedOutput(); err != nil {
+		t.Fatalf("build of main.go failed: %v\n%s", err, out)
+	}
+
+	scripts := []struct {
+		name, script string
+		status       int
+		expected     string
+	}{
+		{"scenario", `# replicas answer while a node is down
+put(a,1) 1
+expect get(a) == 1
+expect get(a) 2 != 2
+kill(0, 60000)
+sleep(50)
+put(b,2) 1; get(b) 2;
+set R 1
+revive(0)
+sleep(20ms)
+expect get(b) 2 == 2
+expect get(missing) 1 fails
+expect kill(9, 10) fails
+exit
+expect get(a) == 2
+`, 0, "PASSED"},
+		{"no_coordinator", "kill(0, 60000)\nkill(1, 60000)\nkill(2, 60000)\nkill(3, 60000)\nkill(4, 60000)\nexpect put(c,1) 1 fails\n", 0, "PASSED"},
+		{"expectation", "put(a,1) 1\n\nexpect get(a) == 2\nput(b,1) 1\n", 1, "expectation.txt:3: expect get(a) == 2: expected get(a) == 2, got get(a) == 1"},
+		{"command", "put(a,1) 1\nsleep(soon)\n", 1, "command.txt:2: sleep(soon): invalid sleep command format"},
+		{"unknown", "putt(a,1) 1\n", 1, "Invalid input"},
+		{"serve", "expect serve(256.0.0.1:80) fails\nserve(127.0.0.1:0)\nexpect serve(127.0.0.1:0) fails\n", 0, "PASSED"},
+		{"not_failing", "put(a,1) 1\nexpect get(a) 1 fails\n", 1, "expected get(a) 1 to fail"},
+	}
+	for _, tt := range scripts {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".txt")
+			if err := os.WriteFile(path, []byte(tt.script), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command(bin, "-script", path, "-num_nodes", "5", "-num_tokens", "10", "-n", "3", "-r", "2", "-w", "2",
+				"-client_put_timeout_ms", "500", "-set_data_timeout_ms", "100", "-debug_level", "0")
+			cmd.Dir = dir
+			out, err := cmd.CombinedOutput()
+			status := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				status = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.status || !strings.Contains(string(out), tt.expected) {
+				t.Errorf("script exited with status %d, expected %d and %q in:\n%s", status, tt.status, tt.expected, out)
+			}
+		})
+	}
+}
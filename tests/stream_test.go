@@ -0,0 +1,235 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// returns the records of every shard of the stream of table from its oldest record retained, by partition key
+func readStream(t *testing.T, cl *client.Client, table string) map[string][]base.StreamRecord {
+	t.Helper()
+	shards, err := cl.DescribeStream(table)
+	if err != nil {
+		t.Fatalf("DescribeStream failed: %v", err)
+	}
+	records := make(map[string][]base.StreamRecord)
+	for _, shard := range shards {
+		iterator, err := cl.GetShardIterator(table, shard.ShardId, constants.ITERATOR_TRIM_HORIZON, 0)
+		if err != nil {
+			t.Fatalf("GetShardIterator %s failed: %v", shard.ShardId, err)
+		}
+		// pages of one record walk the shard through NextIterator
+		for {
+			page, err := cl.GetRecords(iterator, 1)
+			if err != nil {
+				t.Fatalf("GetRecords %s failed: %v", shard.ShardId, err)
+			}
+			if len(page.Records) == 0 {
+				break
+			}
+			record := page.Records[0]
+			if record.SequenceNumber < shard.StartingSequenceNumber || record.SequenceNumber > shard.EndingSequenceNumber {
+				t.Errorf("record %d out of the range of %+v", record.SequenceNumber, shard)
+			}
+			records[record.Key.Partition] = append(records[record.Key.Partition], record)
+			iterator = page.NextIterator
+		}
+	}
+	return records
+}
+
+// TEST E1
+
+// TestStreamRecords ensures the puts, updates, deletes and transactions of a table with a stream
+// are recorded in order with their old and new images, and are read through shard iterators
+func TestStreamRecords(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	if _, err := cl.CreateTable(base.Table{Name: "orders", Stream: true}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	if _, err := cl.CreateTable(base.Table{Name: "plain"}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	orders := cl.Table("orders")
+
+	for i := 0; i < 5; i++ {
+		key := base.Key{Partition: fmt.Sprintf("order%d", i)}
+		if err := orders.PutItem(ctx, key, base.Item{"status": base.S("new")}); err != nil {
+			t.Fatalf("PutItem %s failed: %v", key, err)
+		}
+		if _, err := orders.Update(ctx, key, client.Set("status", base.S("paid"))); err != nil {
+			t.Fatalf("Update %s failed: %v", key, err)
+		}
+	}
+	if err := orders.DeleteItem(ctx, base.Key{Partition: "order0"}); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+	if err := orders.DeleteItem(ctx, base.Key{Partition: "missing"}); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+	err := cl.TransactWrite(ctx, []client.TransactItem{
+		{BatchKey: client.BatchKey{Table: "orders", Key: base.Key{Partition: "order1"}}, Op: constants.CLIENT_REQ_UPDATE, Update: []base.UpdateAction{client.Set("status", base.S("shipped"))}},
+	})
+	if err != nil {
+		t.Fatalf("TransactWrite failed: %v", err)
+	}
+
+	records := readStream(t, cl, "orders")
+	if _, exists := records["missing"]; exists || len(records) != 5 {
+		t.Errorf("stream holds records of %d keys: %v, expected order0 to order4", len(records), records)
+	}
+	var events []string
+	for _, record := range records["order0"] {
+		events = append(events, record.EventName)
+	}
+	if !reflect.DeepEqual(events, []string{constants.STREAM_INSERT, constants.STREAM_MODIFY, constants.STREAM_REMOVE}) {
+		t.Errorf("events of order0 got: %v", events)
+	}
+	if order0 := records["order0"]; len(order0) == 3 {
+		if order0[0].OldImage != nil || order0[0].NewImage["status"].String() != `"new"` || order0[1].OldImage["status"].String() != `"new"` ||
+			order0[1].NewImage["status"].String() != `"paid"` || order0[2].OldImage["status"].String() != `"paid"` || order0[2].NewImage != nil {
+			t.Errorf("images of order0 got: %+v", order0)
+		}
+		if !(order0[0].SequenceNumber < order0[1].SequenceNumber && order0[1].SequenceNumber < order0[2].SequenceNumber) {
+			t.Errorf("sequence numbers of order0 are out of order: %+v", order0)
+		}
+	}
+	if order1 := records["order1"]; len(order1) != 3 || order1[2].EventName != constants.STREAM_MODIFY || order1[2].NewImage["status"].String() != `"shipped"` {
+		t.Errorf("records of order1 got: %+v, expected the transaction last", order1)
+	}
+
+	// an iterator at LATEST reads the records written after it, AT_SEQUENCE_NUMBER reads them again
+	last := records["order2"][len(records["order2"])-1]
+	var shardId string
+	shards, _ := cl.DescribeStream("orders")
+	for _, shard := range shards {
+		if shard.EndingSequenceNumber >= last.SequenceNumber && shard.StartingSequenceNumber <= last.SequenceNumber {
+			if iterator, _ := cl.GetShardIterator("orders", shard.ShardId, constants.ITERATOR_AT_SEQUENCE_NUMBER, last.SequenceNumber); iterator != "" {
+				if page, err := cl.GetRecords(iterator, 1); err == nil && len(page.Records) == 1 && page.Records[0].SequenceNumber == last.SequenceNumber {
+					shardId = shard.ShardId
+				}
+			}
+		}
+	}
+	if shardId == "" {
+		t.Fatalf("no shard holds record %d of order2", last.SequenceNumber)
+	}
+	latest, err := cl.GetShardIterator("orders", shardId, constants.ITERATOR_LATEST, 0)
+	if err != nil {
+		t.Fatalf("GetShardIterator LATEST failed: %v", err)
+	}
+	if page, err := cl.GetRecords(latest, 0); err != nil || len(page.Records) != 0 || page.NextIterator != latest {
+		t.Errorf("GetRecords at LATEST got: %+v, %v, expected no record", page, err)
+	}
+	if _, err := orders.Update(ctx, base.Key{Partition: "order2"}, client.Set("status", base.S("refunded"))); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if page, err := cl.GetRecords(latest, 0); err != nil || len(page.Records) != 1 || page.Records[0].OldImage["status"].String() != `"paid"` {
+		t.Errorf("GetRecords after LATEST got: %+v, %v, expected the refund", page, err)
+	}
+
+	invalid := []struct {
+		name     string
+		call     func() error
+		expected error
+	}{
+		{"no_stream", func() error { _, err := cl.DescribeStream("plain"); return err }, client.ErrInvalidRequest},
+		{"missing_table", func() error { _, err := cl.DescribeStream("missing"); return err }, client.ErrTableNotFound},
+		{"missing_shard", func() error {
+			_, err := cl.GetShardIterator("orders", "shard-missing", constants.ITERATOR_LATEST, 0)
+			return err
+		}, client.ErrInvalidRequest},
+		{"iterator_type", func() error { _, err := cl.GetShardIterator("orders", shardId, "OLDEST", 0); return err }, client.ErrInvalidRequest},
+		{"iterator", func() error { _, err := cl.GetRecords("orders/1", 0); return err }, client.ErrInvalidRequest},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.expected) {
+				t.Errorf("got: %v, expected %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+// TEST E2
+
+// TestStreamRetention ensures records older than STREAM_RETENTION_MS are trimmed, iterators
+// pointing before them fail with ErrTrimmedData, and iterators of a deleted table are rejected
+func TestStreamRetention(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+	c.STREAM_RETENTION_MS = 100
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	if _, err := cl.CreateTable(base.Table{Name: "events", Stream: true}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	events := cl.Table("events")
+	key := base.Key{Partition: "e1"}
+	if err := events.PutItem(ctx, key, base.Item{"n": base.N("1")}); err != nil {
+		t.Fatalf("PutItem failed: %v", err)
+	}
+	shards, err := cl.DescribeStream("events")
+	if err != nil || len(shards) != 1 {
+		t.Fatalf("DescribeStream got: %+v, %v", shards, err)
+	}
+	iterator, err := cl.GetShardIterator("events", shards[0].ShardId, constants.ITERATOR_TRIM_HORIZON, 0)
+	if err != nil {
+		t.Fatalf("GetShardIterator failed: %v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, err := cl.GetRecords(iterator, 0); !errors.Is(err, client.ErrTrimmedData) {
+		t.Errorf("GetRecords of a trimmed record got: %v, expected ErrTrimmedData", err)
+	}
+	if _, err := cl.GetShardIterator("events", shards[0].ShardId, constants.ITERATOR_AT_SEQUENCE_NUMBER, shards[0].EndingSequenceNumber); !errors.Is(err, client.ErrTrimmedData) {
+		t.Errorf("GetShardIterator at a trimmed record got: %v, expected ErrTrimmedData", err)
+	}
+	iterator, err = cl.GetShardIterator("events", shards[0].ShardId, constants.ITERATOR_TRIM_HORIZON, 0)
+	if err != nil {
+		t.Fatalf("GetShardIterator failed: %v", err)
+	}
+	if page, err := cl.GetRecords(iterator, 0); err != nil || len(page.Records) != 0 {
+		t.Errorf("GetRecords from TRIM_HORIZON got: %+v, %v, expected no record", page, err)
+	}
+
+	// a recreated table starts a new stream
+	if _, err := cl.DeleteTable("events"); err != nil {
+		t.Fatalf("DeleteTable failed: %v", err)
+	}
+	if _, err := cl.CreateTable(base.Table{Name: "events", Stream: true}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	if _, err := cl.GetRecords(iterator, 0); !errors.Is(err, client.ErrTableNotFound) {
+		t.Errorf("GetRecords of a deleted table got: %v, expected ErrTableNotFound", err)
+	}
+}
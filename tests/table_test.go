@@ -0,0 +1,272 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// returns the number of nodes storing key of table, tombstones included
+func countReplicas(phy_nodes []*base.Node, table string, key base.Key) int {
+	storageKey := base.StorageKey(table, key.Partition, key.Sort)
+	count := 0
+	for _, node := range phy_nodes {
+		if _, exists := node.GetAllData()[storageKey]; exists {
+			count++
+		}
+	}
+	return count
+}
+
+// TEST M1
+
+// TestTables ensures named tables are created, listed, described and deleted,
+// and that their items are isolated and replicated with their own N
+func TestTables(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	invalid := []base.Table{
+		{Name: "ab"},
+		{Name: "bad name"},
+		{Name: "too_many", N: 6},
+		{Name: "r_above_n", N: 2, R: 3},
+		{Name: "w_negative", W: -1},
+		{Name: "unknown_mode", ConflictResolution: "RANDOM"},
+	}
+	for _, table := range invalid {
+		if _, err := cl.CreateTable(table); !errors.Is(err, client.ErrInvalidRequest) {
+			t.Errorf("CreateTable %+v got: %v, expected ErrInvalidRequest", table, err)
+		}
+	}
+
+	tables := []struct {
+		table    base.Table
+		replicas int
+	}{
+		{base.Table{Name: "orders"}, 3},
+		{base.Table{Name: "archive", N: 1}, 1},
+		{base.Table{Name: "everywhere", N: 5, R: 5, W: 5}, 5},
+	}
+	for _, tt := range tables {
+		if _, err := cl.CreateTable(tt.table); err != nil {
+			t.Fatalf("CreateTable %s failed: %v", tt.table.Name, err)
+		}
+	}
+	if _, err := cl.CreateTable(base.Table{Name: "orders"}); !errors.Is(err, client.ErrTableExists) {
+		t.Errorf("CreateTable of an existing table got: %v, expected ErrTableExists", err)
+	}
+	if names := cl.ListTables(); !reflect.DeepEqual(names, []string{"archive", "everywhere", "orders"}) {
+		t.Errorf("ListTables got: %v", names)
+	}
+	desc, err := cl.DescribeTable("archive")
+	if err != nil || desc.N != 1 || desc.R != 1 || desc.W != 1 || desc.ConflictResolution != constants.CONFLICT_VECTOR_CLOCK {
+		t.Errorf("DescribeTable got: %+v, %v, expected N=R=W=1 with vector clocks", desc, err)
+	}
+
+	// the same key holds a different item in every table
+	key := base.Key{Partition: "user1"}
+	if err := cl.PutItem(ctx, key, base.Item{"table": base.S("")}); err != nil {
+		t.Fatalf("PutItem failed: %v", err)
+	}
+	for _, tt := range tables {
+		if err := cl.Table(tt.table.Name).PutItem(ctx, key, base.Item{"table": base.S(tt.table.Name)}); err != nil {
+			t.Fatalf("PutItem in %s failed: %v", tt.table.Name, err)
+		}
+	}
+
+	for _, tt := range tables {
+		t.Run(tt.table.Name, func(t *testing.T) {
+			item, err := cl.Table(tt.table.Name).GetItem(ctx, key)
+			if err != nil || item["table"].String() != `"`+tt.table.Name+`"` {
+				t.Errorf("GetItem got: %s, %v", item, err)
+			}
+			if replicas := countReplicas(phy_nodes, tt.table.Name, key); replicas != tt.replicas {
+				t.Errorf("item stored on %d nodes, expected %d", replicas, tt.replicas)
+			}
+		})
+	}
+
+	if _, err := cl.Table("missing").GetItem(ctx, key); !errors.Is(err, client.ErrTableNotFound) {
+		t.Errorf("GetItem in a missing table got: %v, expected ErrTableNotFound", err)
+	}
+
+	if _, err := cl.DeleteTable("orders"); err != nil {
+		t.Fatalf("DeleteTable failed: %v", err)
+	}
+	if _, err := cl.DeleteTable("orders"); !errors.Is(err, client.ErrTableNotFound) {
+		t.Errorf("DeleteTable of a deleted table got: %v, expected ErrTableNotFound", err)
+	}
+	if err := cl.Table("orders").PutItem(ctx, key, base.Item{}); !errors.Is(err, client.ErrTableNotFound) {
+		t.Errorf("PutItem in a deleted table got: %v, expected ErrTableNotFound", err)
+	}
+	if replicas := countReplicas(phy_nodes, "orders", key); replicas != 0 {
+		t.Errorf("deleted table still stored on %d nodes", replicas)
+	}
+	if item, err := cl.GetItem(ctx, key); err != nil || item["table"].String() != `""` {
+		t.Errorf("GetItem in the default keyspace got: %s, %v", item, err)
+	}
+
+	// a table created again under the same name starts empty
+	if _, err := cl.CreateTable(base.Table{Name: "orders"}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	if _, err := cl.Table("orders").GetItem(ctx, key); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("GetItem in a recreated table got: %v, expected ErrNotFound", err)
+	}
+}
+
+// TEST M2
+
+// TestTableConflictResolution ensures replicas of a LAST_WRITER_WINS table keep
+// the version written last when an older version arrives after it
+func TestTableConflictResolution(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 3
+	c.W = 3
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	desc, err := cl.CreateTable(base.Table{Name: "sessions", ConflictResolution: constants.CONFLICT_LAST_WRITER_WINS})
+	if err != nil || desc.ConflictResolution != constants.CONFLICT_LAST_WRITER_WINS {
+		t.Fatalf("CreateTable got: %+v, %v", desc, err)
+	}
+	table := cl.Table("sessions")
+	key := base.Key{Partition: "session1"}
+	storageKey := base.StorageKey("sessions", key.Partition, "")
+
+	if err := table.PutItem(ctx, key, base.Item{"state": base.S("old")}); err != nil {
+		t.Fatalf("PutItem failed: %v", err)
+	}
+	stale := map[int]*base.Object{}
+	for _, node := range phy_nodes {
+		if obj, exists := node.GetAllData()[storageKey]; exists {
+			stale[node.GetID()] = obj
+		}
+	}
+	if err := table.PutItem(ctx, key, base.Item{"state": base.S("new")}); err != nil {
+		t.Fatalf("PutItem failed: %v", err)
+	}
+
+	// replay the old version to every replica, as a delayed replication would
+	for id, obj := range stale {
+		phy_nodes[id].GetChannel() <- base.Message{Command: constants.SET_DATA, Key: storageKey, ObjData: obj, SrcID: id}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for id := range stale {
+		if state := phy_nodes[id].GetData(storageKey).GetAttrs()["state"].String(); state != `"new"` {
+			t.Errorf("node %d stores state %s, expected \"new\"", id, state)
+		}
+	}
+	if item, err := table.GetItem(ctx, key); err != nil || item["state"].String() != `"new"` {
+		t.Errorf("GetItem got: %s, %v, expected state \"new\"", item, err)
+	}
+}
+
+// TEST M3
+
+// TestTableTTL ensures items whose TTL attribute is in the past are hidden
+// from reads, queries and scans, and can be written again as absent
+func TestTableTTL(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	if _, err := cl.CreateTable(base.Table{Name: "cache", TTLAttribute: "expires"}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	table := cl.Table("cache")
+
+	past := base.N(fmt.Sprint(time.Now().Add(-time.Hour).Unix()))
+	future := base.N(fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+	items := []struct {
+		sortKey string
+		item    base.Item
+		live    bool
+	}{
+		{"expired", base.Item{"expires": past}, false},
+		{"live", base.Item{"expires": future}, true},
+		{"no_ttl", base.Item{}, true},
+		{"not_a_number", base.Item{"expires": base.S("0")}, true},
+	}
+	for _, tt := range items {
+		if err := table.PutItem(ctx, base.Key{Partition: "p", Sort: tt.sortKey}, tt.item); err != nil {
+			t.Fatalf("PutItem %s failed: %v", tt.sortKey, err)
+		}
+	}
+	// the default keyspace has no TTL attribute
+	if err := cl.PutItem(ctx, base.Key{Partition: "p", Sort: "expired"}, base.Item{"expires": past}); err != nil {
+		t.Fatalf("PutItem failed: %v", err)
+	}
+
+	var expected []string
+	for _, tt := range items {
+		t.Run(tt.sortKey, func(t *testing.T) {
+			_, err := table.GetItem(ctx, base.Key{Partition: "p", Sort: tt.sortKey})
+			if tt.live && err != nil {
+				t.Errorf("GetItem failed: %v", err)
+			}
+			if !tt.live && !errors.Is(err, client.ErrNotFound) {
+				t.Errorf("GetItem got: %v, expected ErrNotFound", err)
+			}
+		})
+		if tt.live {
+			expected = append(expected, tt.sortKey)
+		}
+	}
+
+	page, err := table.Query(ctx, "p", base.Query{})
+	var queried []string
+	for _, item := range page.Items {
+		queried = append(queried, item.SortKey)
+	}
+	if err != nil || !reflect.DeepEqual(queried, expected) {
+		t.Errorf("Query got: %v, %v, expected %v", queried, err, expected)
+	}
+
+	scanned, _, err := scanSegment(ctx, cl.Table("cache"), base.Scan{})
+	if err != nil || len(scanned) != len(expected) {
+		t.Errorf("Scan got: %v, %v, expected %d items", scanned, err, len(expected))
+	}
+
+	if _, err := cl.GetItem(ctx, base.Key{Partition: "p", Sort: "expired"}); err != nil {
+		t.Errorf("GetItem in the default keyspace failed: %v", err)
+	}
+	if _, err := table.PutItemIfAbsent(ctx, base.Key{Partition: "p", Sort: "expired"}, base.Item{}); err != nil {
+		t.Errorf("PutItemIfAbsent over an expired item failed: %v", err)
+	}
+}
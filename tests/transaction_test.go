@@ -0,0 +1,265 @@
+package tests
+
+import (
+	"base"
+	"client"
+	"config"
+	"constants"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TEST X1
+
+// TestTransactCommit ensures the puts, updates, deletes and condition checks of a
+// transaction across partitions and tables are all applied, and invalid transactions are rejected
+func TestTransactCommit(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	if _, err := cl.CreateTable(base.Table{Name: "accounts", N: 2, R: 1, W: 1}); err != nil {
+		t.Fatalf("CreateTable failed: %v", err)
+	}
+	accounts := cl.Table("accounts")
+	alice := client.BatchKey{Table: "accounts", Key: base.Key{Partition: "alice"}}
+	bob := client.BatchKey{Table: "accounts", Key: base.Key{Partition: "bob"}}
+	for _, key := range []client.BatchKey{alice, bob} {
+		if _, err := accounts.Update(ctx, key.Key, client.Add("balance", 100)); err != nil {
+			t.Fatalf("Update %s failed: %v", key, err)
+		}
+	}
+	if err := cl.Put(ctx, "stale", "x"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	err := cl.TransactWrite(ctx, []client.TransactItem{
+		{BatchKey: alice, Op: constants.CLIENT_REQ_UPDATE, Update: []base.UpdateAction{client.Add("balance", -30)}, Condition: constants.COND_EXISTS},
+		{BatchKey: bob, Op: constants.CLIENT_REQ_UPDATE, Update: []base.UpdateAction{client.Add("balance", 30)}},
+		{BatchKey: client.BatchKey{Key: base.Key{Partition: "transfer1"}}, Op: constants.CLIENT_REQ_WRITE, Value: "alice->bob", Condition: constants.COND_NOT_EXISTS},
+		{BatchKey: client.BatchKey{Key: base.Key{Partition: "stale"}}, Op: constants.CLIENT_REQ_DELETE},
+		{BatchKey: client.BatchKey{Table: "accounts", Key: base.Key{Partition: "audit"}}, Op: constants.CLIENT_REQ_CHECK, Condition: constants.COND_NOT_EXISTS},
+	})
+	if err != nil {
+		t.Fatalf("TransactWrite failed: %v", err)
+	}
+
+	for key, expected := range map[client.BatchKey]string{alice: "70", bob: "130"} {
+		if item, err := accounts.GetItem(ctx, key.Key); err != nil || item["balance"].String() != expected {
+			t.Errorf("GetItem %s got: %s, %v, expected balance %s", key, item, err, expected)
+		}
+	}
+	if value, err := cl.Get(ctx, "transfer1"); err != nil || value != "alice->bob" {
+		t.Errorf("Get transfer1 got: %s, %v", value, err)
+	}
+	if _, err := cl.Get(ctx, "stale"); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Get of a key deleted by the transaction got: %v, expected ErrNotFound", err)
+	}
+	if _, err := accounts.GetItem(ctx, base.Key{Partition: "audit"}); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("GetItem of a condition check got: %v, expected ErrNotFound", err)
+	}
+
+	// locks are released, the keys accept plain writes
+	if err := cl.Put(ctx, "transfer1", "done"); err != nil {
+		t.Errorf("Put after the transaction got: %v", err)
+	}
+
+	invalid := []struct {
+		name     string
+		items    []client.TransactItem
+		expected error
+	}{
+		{"empty", nil, client.ErrInvalidRequest},
+		{"duplicate_key", []client.TransactItem{{BatchKey: alice, Op: constants.CLIENT_REQ_DELETE}, {BatchKey: alice, Op: constants.CLIENT_REQ_CHECK}}, client.ErrInvalidRequest},
+		{"unknown_op", []client.TransactItem{{BatchKey: alice, Op: constants.CLIENT_REQ_READ}}, client.ErrInvalidRequest},
+		{"missing_table", []client.TransactItem{{BatchKey: client.BatchKey{Table: "missing", Key: base.Key{Partition: "k"}}, Op: constants.CLIENT_REQ_DELETE}}, client.ErrTableNotFound},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := cl.TransactWrite(ctx, tt.items); !errors.Is(err, tt.expected) {
+				t.Errorf("got: %v, expected %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+// TEST X2
+
+// TestTransactCanceled ensures a transaction with a failed condition writes nothing,
+// and reports the reason of every item
+func TestTransactCanceled(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 10
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	if err := cl.Put(ctx, "taken", "v1"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var items []client.TransactItem
+	for i := 0; i < 5; i++ {
+		items = append(items, client.TransactItem{BatchKey: client.BatchKey{Key: base.Key{Partition: fmt.Sprintf("key%d", i)}}, Op: constants.CLIENT_REQ_WRITE, Value: fmt.Sprint(i)})
+	}
+	items = append(items, client.TransactItem{BatchKey: client.BatchKey{Key: base.Key{Partition: "taken"}}, Op: constants.CLIENT_REQ_WRITE, Value: "v2", Condition: constants.COND_NOT_EXISTS})
+
+	err := cl.TransactWrite(ctx, items)
+	var canceled *base.TransactionCanceledError
+	if !errors.Is(err, client.ErrTransactionCanceled) || !errors.Is(err, client.ErrConditionFailed) || !errors.As(err, &canceled) {
+		t.Fatalf("TransactWrite got: %v, expected a canceled transaction with a failed condition", err)
+	}
+	for i, reason := range canceled.Reasons {
+		if (i == len(items)-1) != (reason != nil) {
+			t.Errorf("reason of item %d got: %v", i, reason)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := cl.Get(ctx, fmt.Sprintf("key%d", i)); !errors.Is(err, client.ErrNotFound) {
+			t.Errorf("Get key%d of a canceled transaction got: %v, expected ErrNotFound", i, err)
+		}
+	}
+	if value, err := cl.Get(ctx, "taken"); err != nil || value != "v1" {
+		t.Errorf("Get taken got: %s, %v, expected v1", value, err)
+	}
+
+	// the locks of the canceled transaction are released
+	items[len(items)-1].Condition = constants.COND_EXISTS
+	if err := cl.TransactWrite(ctx, items); err != nil {
+		t.Errorf("TransactWrite retry got: %v", err)
+	}
+	if value, err := cl.Get(ctx, "taken"); err != nil || value != "v2" {
+		t.Errorf("Get taken got: %s, %v, expected v2", value, err)
+	}
+}
+
+// TEST X3
+
+// TestTransactConflict ensures keys locked by a transaction in progress reject plain writes and
+// other transactions, and a node revived from a kill releases locks of transactions unknown to their coordinator
+func TestTransactConflict(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+	c.SET_DATA_TIMEOUT_MS = 100
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	// every node owns every key, lock the key on all of them for a transaction coordinated by node 0
+	key := base.StorageKey("", "locked", "")
+	for _, node := range phy_nodes {
+		node.GetChannel() <- base.Message{Command: constants.TX_PREPARE, Key: key, TxId: "0.999", SrcID: 0}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := cl.Put(ctx, "locked", "v"); !errors.Is(err, client.ErrTransactionConflict) {
+		t.Errorf("Put of a locked key got: %v, expected ErrTransactionConflict", err)
+	}
+	err := cl.TransactWrite(ctx, []client.TransactItem{{BatchKey: client.BatchKey{Key: base.Key{Partition: "locked"}}, Op: constants.CLIENT_REQ_WRITE, Value: "v"}})
+	if !errors.Is(err, client.ErrTransactionCanceled) || !errors.Is(err, client.ErrTransactionConflict) {
+		t.Errorf("TransactWrite of a locked key got: %v, expected a canceled transaction with a conflict", err)
+	}
+
+	// node 0 never coordinated the transaction, the revived nodes abort it
+	for _, node := range phy_nodes {
+		node.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "10", SrcID: -1}
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if err := cl.Put(ctx, "locked", "v"); err != nil {
+		t.Errorf("Put after recovery got: %v", err)
+	}
+}
+
+// TEST X4
+
+// TestTransactRecovery ensures an owner killed between the prepare and the commit of a
+// transaction applies its write once revived, and an owner killed before the prepare too
+func TestTransactRecovery(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.N = 3
+	c.R = 2
+	c.W = 2
+	c.DEBUG_LEVEL = 1
+	c.CLIENT_GET_TIMEOUT_MS = 500
+	c.SET_DATA_TIMEOUT_MS = 100
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	cl := client.New(phy_nodes, &c)
+	ctx := context.Background()
+
+	// a key coordinated by node 0
+	var name string
+	for i := 0; ; i++ {
+		name = fmt.Sprintf("key%d", i)
+		if _, node := base.FindNode(name, phy_nodes, &c); node.GetID() == 0 {
+			break
+		}
+	}
+
+	// node 1 does not vote, node 0 waits for it while node 2 holds the lock, and commits without it
+	phy_nodes[1].GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+	done := make(chan error)
+	go func() {
+		done <- cl.TransactWrite(ctx, []client.TransactItem{{BatchKey: client.BatchKey{Key: base.Key{Partition: name}}, Op: constants.CLIENT_REQ_WRITE, Value: "committed"}})
+	}()
+	time.Sleep(250 * time.Millisecond)
+	phy_nodes[2].GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+
+	if err := <-done; err != nil {
+		t.Fatalf("TransactWrite got: %v", err)
+	}
+	key := base.StorageKey("", name, "")
+	if obj := phy_nodes[2].GetData(key); obj.GetData() != "" {
+		t.Fatalf("killed node 2 got the commit: %s", obj.ToString())
+	}
+
+	phy_nodes[2].GetChannel() <- base.Message{Command: constants.CLIENT_REQ_REVIVE, SrcID: -1}
+	deadline := time.Now().Add(time.Second)
+	for phy_nodes[2].GetData(key).GetData() != "committed" && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if obj := phy_nodes[2].GetData(key); obj.GetData() != "committed" {
+		t.Errorf("revived node 2 got: %s, expected the committed write", obj.ToString())
+	}
+
+	// node 1 holds no lock of the transaction, the coordinator resends it the commit
+	phy_nodes[1].GetChannel() <- base.Message{Command: constants.CLIENT_REQ_REVIVE, SrcID: -1}
+	deadline = time.Now().Add(time.Second)
+	for phy_nodes[1].GetData(key).GetData() != "committed" && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if obj := phy_nodes[1].GetData(key); obj.GetData() != "committed" {
+		t.Errorf("revived node 1 got: %s, expected the committed write", obj.ToString())
+	}
+}
@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"base"
+	"config"
+	"constants"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TEST U1
+
+// TestUpdateActions ensures SET, REMOVE and ADD actions are applied by the
+// coordinator on top of the value read from R replicas
+func TestUpdateActions(t *testing.T) {
+	var tests = []struct {
+		numNodes, numTokens, nValue, rAndWValue int
+	}{
+		{1, 1, 1, 1},
+		{5, 5, 3, 2},
+		{5, 10, 3, 3},
+	}
+	for _, tt := range tests {
+		testname := fmt.Sprintf("%d_nodes_%d_tokens_%d_n_%d_rAndW", tt.numNodes, tt.numTokens, tt.nValue, tt.rAndWValue)
+		t.Run(testname, func(t *testing.T) {
+			c := config.InstantiateConfig()
+			c.NUM_NODES = tt.numNodes
+			c.NUM_TOKENS = tt.numTokens
+			c.N = tt.nValue
+			c.R = tt.rAndWValue
+			c.W = tt.rAndWValue
+			c.DEBUG_LEVEL = 1
+
+			phy_nodes, close_ch, client_ch := setUpNodes(&c)
+			defer close(close_ch)
+
+			key := generateRandomString(10)
+			_, node := base.FindNode(key, phy_nodes, &c)
+			update := func(actions ...base.UpdateAction) base.Message {
+				node.GetChannel() <- base.Message{Key: key, Command: constants.CLIENT_REQ_UPDATE, Update: actions, SrcID: -1, Client_Ch: client_ch}
+				select {
+				case reply := <-client_ch:
+					return reply
+				case <-time.After(time.Duration(c.CLIENT_PUT_TIMEOUT_MS) * time.Millisecond):
+					t.Fatal("Update timeout reached")
+					return base.Message{}
+				}
+			}
+
+			update(base.UpdateAction{Op: constants.UPDATE_SET, Name: "name", Value: base.S("bob")}, base.UpdateAction{Op: constants.UPDATE_SET, Name: "tmp", Value: base.S("x")}, base.UpdateAction{Op: constants.UPDATE_ADD, Name: "hits", Delta: 5})
+			reply := update(base.UpdateAction{Op: constants.UPDATE_REMOVE, Name: "tmp"}, base.UpdateAction{Op: constants.UPDATE_ADD, Name: "hits", Delta: -2})
+			if reply.Command != constants.CLIENT_ACK_WRITE || reply.Attrs.String() != `{"hits": 3, "name": "bob"}` {
+				t.Errorf(`got %s with %s, expected CLIENT_ACK_WRITE with {"hits": 3, "name": "bob"}`, constants.GetConstantString(reply.Command), reply.Attrs)
+			}
+
+			node.GetChannel() <- base.Message{Key: key, Command: constants.CLIENT_REQ_READ, SrcID: -1, Client_Ch: client_ch}
+			select {
+			case read := <-client_ch:
+				if read.Attrs.String() != `{"hits": 3, "name": "bob"}` {
+					t.Errorf(`read got %s, expected {"hits": 3, "name": "bob"}`, read.Attrs)
+				}
+			case <-time.After(time.Duration(c.CLIENT_GET_TIMEOUT_MS) * time.Millisecond):
+				t.Fatal("Get timeout reached")
+			}
+
+			if reply := update(base.UpdateAction{Op: constants.UPDATE_ADD, Name: "name", Delta: 1}); reply.Command != constants.INVALID_REQUEST {
+				t.Errorf("ADD on a SET attribute got %s, expected INVALID_REQUEST", constants.GetConstantString(reply.Command))
+			}
+		})
+	}
+}
+
+// TEST U2
+
+// TestCounterMerge ensures increments applied concurrently by different
+// coordinators are merged by the replicas and by quorum reads
+func TestCounterMerge(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 5
+	c.NUM_TOKENS = 5
+	c.N = 3
+	c.R = 3
+	c.W = 3
+	c.DEBUG_LEVEL = 1
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+
+	key := generateRandomString(10)
+	token, _ := base.FindNode(key, phy_nodes, &c)
+
+	// every node of the preference list coordinates increments of the same counter
+	const increments = 5
+	var wg sync.WaitGroup
+	for i := 0; i < c.N; i++ {
+		coordinator := base.FindPrefList(token, phy_nodes, i)
+		for j := 0; j < increments; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				reply_ch := make(chan base.Message, 1)
+				coordinator.GetChannel() <- base.Message{Key: key, Command: constants.CLIENT_REQ_UPDATE, Update: []base.UpdateAction{{Op: constants.UPDATE_ADD, Name: "hits", Delta: 1}}, SrcID: -1, Client_Ch: reply_ch}
+				select {
+				case reply := <-reply_ch:
+					if reply.Command != constants.CLIENT_ACK_WRITE {
+						t.Errorf("got %s, expected CLIENT_ACK_WRITE", constants.GetConstantString(reply.Command))
+					}
+				case <-time.After(time.Duration(c.CLIENT_PUT_TIMEOUT_MS) * time.Millisecond):
+					t.Error("Update timeout reached")
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	expected := fmt.Sprint(c.N * increments)
+	for i := 0; i < c.N; i++ {
+		coordinator := base.FindPrefList(token, phy_nodes, i)
+		reply_ch := make(chan base.Message, 1)
+		coordinator.GetChannel() <- base.Message{Key: key, Command: constants.CLIENT_REQ_READ, SrcID: -1, Client_Ch: reply_ch}
+		select {
+		case read := <-reply_ch:
+			if read.Attrs["hits"].String() != expected {
+				t.Errorf("read from node %d got hits=%s, expected %s", coordinator.GetID(), read.Attrs["hits"], expected)
+			}
+		case <-time.After(time.Duration(c.CLIENT_GET_TIMEOUT_MS) * time.Millisecond):
+			t.Error("Get timeout reached")
+		}
+	}
+}
+
+// TEST U3
+
+// TestConcurrentVersions ensures versions written by coordinators that did not see each other's write, whose
+// vector clocks are concurrent, are both kept by merging their counters instead of one replacing the other
+func TestConcurrentVersions(t *testing.T) {
+	c := config.InstantiateConfig()
+	c.NUM_NODES = 3
+	c.NUM_TOKENS = 3
+	c.N = 3
+	c.R = 1
+	c.W = 1
+	c.DEBUG_LEVEL = 1
+	c.SET_DATA_TIMEOUT_MS = 100
+
+	phy_nodes, close_ch, _ := setUpNodes(&c)
+	defer close(close_ch)
+	key := generateRandomString(10)
+	token, home := base.FindNode(key, phy_nodes, &c)
+	second, third := base.FindPrefList(token, phy_nodes, 1), base.FindPrefList(token, phy_nodes, 2)
+
+	update := func(coordinator *base.Node, delta int64) {
+		reply_ch := make(chan base.Message, 1)
+		coordinator.GetChannel() <- base.Message{Key: key, Command: constants.CLIENT_REQ_UPDATE, Update: []base.UpdateAction{{Op: constants.UPDATE_ADD, Name: "hits", Delta: delta}}, SrcID: -1, Client_Ch: reply_ch}
+		select {
+		case reply := <-reply_ch:
+			if reply.Command != constants.CLIENT_ACK_WRITE {
+				t.Fatalf("update through node %d got %s, expected CLIENT_ACK_WRITE", coordinator.GetID(), constants.GetConstantString(reply.Command))
+			}
+		case <-time.After(time.Duration(c.CLIENT_PUT_TIMEOUT_MS) * time.Millisecond):
+			t.Fatalf("update through node %d timed out", coordinator.GetID())
+		}
+	}
+	kill := func(nodes ...*base.Node) {
+		for _, node := range nodes {
+			node.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_KILL, Data: "100000", SrcID: -1}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	revive := func(nodes ...*base.Node) {
+		for _, node := range nodes {
+			node.GetChannel() <- base.Message{Command: constants.CLIENT_REQ_REVIVE, SrcID: -1}
+		}
+	}
+
+	// the coordinator writes alone, then the other owners write without it: the versions are concurrent
+	kill(second, third)
+	update(home, 1)
+	revive(second, third)
+	kill(home)
+	update(second, 2)
+	revive(home)
+
+	// a read of every replica reconciles the concurrent versions, the next write supersedes both
+	if _, err := base.SetConfig(phy_nodes, "R", 3); err != nil {
+		t.Fatal(err)
+	}
+	reply_ch := make(chan base.Message, 1)
+	home.GetChannel() <- base.Message{Key: key, Command: constants.CLIENT_REQ_READ, SrcID: -1, Client_Ch: reply_ch}
+	select {
+	case read := <-reply_ch:
+		if read.Attrs["hits"].String() != "3" {
+			t.Errorf("read got hits=%s, expected the increments of both versions, 3", read.Attrs["hits"])
+		}
+	case <-time.After(time.Duration(c.CLIENT_GET_TIMEOUT_MS) * time.Millisecond):
+		t.Error("Get timeout reached")
+	}
+	update(home, 1)
+	if obj := third.GetData(base.StorageKey("", key, "")); obj.GetAttrs()["hits"].String() != "4" {
+		t.Errorf("node %d holds %s, expected hits=4", third.GetID(), obj.ToString())
+	}
+}